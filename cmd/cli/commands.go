@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	"github.com/itsrobel/sync/internal/config"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/transport"
+	"github.com/itsrobel/sync/internal/watcher"
+)
+
+type initResult struct {
+	Path   string `json:"path"`
+	Vault  string `json:"vault"`
+	Config string `json:"config"`
+	Remote string `json:"remote"` // created, exists or the reason the server was not reached
+}
+
+func runInit(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("init")
+	vault := fs.String("vault", sql_manager.DefaultVault, "vault the folder syncs with")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: init [-vault name] <path>")
+	}
+	if !sql_manager.ValidVaultID(*vault) {
+		return fmt.Errorf("invalid vault id %q: use lowercase letters, digits, - and _", *vault)
+	}
+
+	path, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	result := initResult{Path: path, Vault: *vault, Config: cfg.Source}
+	if result.Config == "" {
+		result.Config = "sync.yaml"
+	}
+
+	// only the folders of the file are kept, a fresh config starts without
+	// the built in ./content mapping
+	listed, err := config.FileFolders(result.Config)
+	if err != nil {
+		return err
+	}
+	var folders []config.FolderConfig
+	for _, folder := range listed {
+		abs, _ := filepath.Abs(folder.Path)
+		if abs != path && folder.Vault != *vault {
+			folders = append(folders, folder)
+		}
+	}
+	folders = append(folders, config.FolderConfig{Path: path, Vault: *vault})
+	cfg.Client.Folders = folders
+	if err := cfg.Client.Validate(); err != nil {
+		return err
+	}
+
+	result.Remote, err = ensureRemoteVault(&cfg.Client, *vault)
+	if err != nil {
+		return err
+	}
+	if err := config.SaveFolders(result.Config, folders); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return output(*asJSON, result, func() {
+		fmt.Printf("%s is now synced with vault %s (%s)\nconfig written to %s\n", result.Path, result.Vault, result.Remote, result.Config)
+	})
+}
+
+// ensureRemoteVault creates the vault on the server when needed. An
+// unreachable server is reported but not an error, the vault is created on
+// the first sync instead.
+func ensureRemoteVault(cfg *config.ClientConfig, vault string) (string, error) {
+	client, err := newServiceClient(cfg)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := client.ListVaults(ctx, connect.NewRequest(&ft.ActionRequest{}))
+	if connect.CodeOf(err) == connect.CodeUnavailable || connect.CodeOf(err) == connect.CodeDeadlineExceeded {
+		return fmt.Sprintf("server not reached: %v", err), nil
+	} else if err != nil {
+		return "", err
+	}
+	for _, known := range res.Msg.Vaults {
+		if known.Id == vault {
+			return "exists", nil
+		}
+	}
+
+	_, err = client.CreateVault(ctx, connect.NewRequest(&ft.Vault{Id: vault}))
+	if connect.CodeOf(err) == connect.CodeAlreadyExists {
+		return "", fmt.Errorf("vault %s exists but this device's user is not a member", vault)
+	} else if err != nil {
+		return "", err
+	}
+	return "created", nil
+}
+
+// runSync syncs once without watching. The exit status tells scripts how
+// it went, see watcher.SyncResult.ExitCode.
+func runSync(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("sync")
+	timeout := fs.Duration("timeout", 2*time.Minute, "give up when the server did not sync in time")
+	dryRun := fs.Bool("dry-run", false, "only print the plan, same as the plan command")
+	fs.Parse(args)
+	if *dryRun {
+		return showPlan(cfg, *asJSON)
+	}
+
+	wcfg, err := watcherConfig(&cfg.Client)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	result, err := watcher.Sync(ctx, wcfg)
+	if err != nil {
+		return err
+	}
+
+	if err := output(*asJSON, result, func() { printSyncResult(result) }); err != nil {
+		return err
+	}
+	if code := result.ExitCode(); code != watcher.SyncOK {
+		return exitStatus(code)
+	}
+	return nil
+}
+
+func printSyncResult(result *watcher.SyncResult) {
+	for _, vault := range result.Vaults {
+		fmt.Printf("vault %s: %d uploaded, %d downloaded", vault.Vault, len(vault.Uploaded), len(vault.Downloaded))
+		if vault.Pending > 0 {
+			fmt.Printf(", %d still pending", vault.Pending)
+		}
+		fmt.Println()
+		for _, conflict := range vault.Conflicts {
+			fmt.Printf("  conflict on %s, local edit kept as %s\n", conflict.Location, conflict.CopyLocation)
+		}
+		for _, location := range vault.Skipped {
+			fmt.Printf("  skipped %s, it is over the size limit\n", location)
+		}
+		if len(vault.Deferred) > 0 {
+			fmt.Printf("  %d attachments only recorded, download them with fetch\n", len(vault.Deferred))
+		}
+		if vault.Error != "" {
+			fmt.Printf("  error: %s\n", vault.Error)
+		}
+	}
+}
+
+func runWatch(cfg *config.Config, args []string) error {
+	fs, _ := commandFlags("watch")
+	fs.Parse(args)
+
+	fw, err := startWatcher(&cfg.Client)
+	if err != nil {
+		return err
+	}
+	defer fw.Stop()
+
+	// Handle graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	log.Printf("File watcher started. Watching %d folder(s)", len(cfg.Client.Folders))
+	<-sigChan
+	log.Println("Shutting down...")
+	return nil
+}
+
+func runRotateKey(cfg *config.Config, args []string) error {
+	fs, _ := commandFlags("rotate-key")
+	passphraseFile := fs.String("passphrase-file", "", "file holding the new passphrase")
+	fs.Parse(args)
+	if *passphraseFile == "" {
+		return fmt.Errorf("usage: rotate-key -passphrase-file <file>")
+	}
+	newPassphrase, err := readPassphrase(*passphraseFile, "")
+	if err != nil {
+		return fmt.Errorf("failed to read new passphrase: %w", err)
+	}
+
+	fw, err := startWatcher(&cfg.Client)
+	if err != nil {
+		return err
+	}
+	defer fw.Stop()
+
+	for _, folder := range cfg.Client.Folders {
+		if err := fw.RotateKey(folder.Vault, newPassphrase); err != nil {
+			return fmt.Errorf("failed to rotate key of vault %s: %w", folder.Vault, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	return fw.WaitSynced(ctx)
+}
+
+func watcherConfig(cfg *config.ClientConfig) (watcher.Config, error) {
+	folders, err := watcherFolders(cfg)
+	if err != nil {
+		return watcher.Config{}, err
+	}
+	for _, folder := range folders {
+		// Ensure content directory exists
+		if err := os.MkdirAll(folder.Path, 0755); err != nil {
+			return watcher.Config{}, fmt.Errorf("failed to create watch directory: %w", err)
+		}
+	}
+
+	return watcher.Config{
+		DBPath:     cfg.DBPath,
+		ClientName: cfg.Name,
+		ServerAddr: cfg.Server,
+		TLS:        cfg.TLS.Options(),
+		Token:      cfg.Token,
+		Folders:    folders,
+	}, nil
+}
+
+func startWatcher(cfg *config.ClientConfig) (*watcher.FileWatcher, error) {
+	wcfg, err := watcherConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fw, err := watcher.InitFileWatcher(wcfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize file watcher: %w", err)
+	}
+	return fw, nil
+}
+
+func newServiceClient(cfg *config.ClientConfig) (filetransferconnect.FileServiceClient, error) {
+	httpClient, err := transport.NewHTTPClient(cfg.TLS.Options())
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure transport: %w", err)
+	}
+	return filetransferconnect.NewFileServiceClient(
+		httpClient,
+		transport.BaseURL(cfg.Server, cfg.TLS.Enabled),
+		auth.WithToken(cfg.Token),
+	), nil
+}
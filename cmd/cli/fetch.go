@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/itsrobel/sync/internal/config"
+	"github.com/itsrobel/sync/internal/watcher"
+)
+
+// runFetch downloads attachments that a lazy folder, or one with a size
+// limit, only recorded.
+func runFetch(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("fetch")
+	timeout := fs.Duration("timeout", 10*time.Minute, "give up when the downloads did not finish in time")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: fetch <path>...")
+	}
+
+	wcfg, err := watcherConfig(&cfg.Client)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	fetched, err := watcher.Fetch(ctx, wcfg, fs.Args())
+	// whatever was fetched before a failure is on disk, so it is listed
+	printErr := output(*asJSON, fetched, func() {
+		for _, location := range fetched {
+			fmt.Printf("fetched %s\n", location)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return printErr
+}
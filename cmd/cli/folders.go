@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/itsrobel/sync/internal/config"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/watcher"
+)
+
+type folderRules struct {
+	Vault   string   `json:"vault"`
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// runFolders shows or changes which folders of the vaults this device
+// mirrors. Paths are folders on disk inside a synced folder, they do not
+// have to exist.
+func runFolders(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("folders")
+	timeout := fs.Duration("timeout", 10*time.Minute, "give up when the downloads did not finish in time")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return showFolders(cfg, *asJSON)
+	}
+
+	action, paths := fs.Arg(0), fs.Args()[1:]
+	if len(paths) == 0 || (action != "add" && action != "remove" && action != "only") {
+		return fmt.Errorf("usage: folders [add|remove|only <path>...]")
+	}
+	wcfg, err := watcherConfig(&cfg.Client)
+	if err != nil {
+		return err
+	}
+
+	// the paths are grouped per vault, each vault is changed once
+	byVault := map[string][]string{}
+	for _, path := range paths {
+		folder, location, err := watcher.Locate(wcfg.Folders, path)
+		if err != nil {
+			return err
+		}
+		if location, err = sql_manager.CleanSyncFolder(location); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		byVault[folder.Vault] = append(byVault[folder.Vault], location)
+	}
+	vaults := make([]string, 0, len(byVault))
+	for vault := range byVault {
+		vaults = append(vaults, vault)
+	}
+	sort.Strings(vaults)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	results := []*watcher.SelectResult{}
+	var selectErr error
+	for _, vault := range vaults {
+		locations := byVault[vault]
+		result, err := watcher.SelectFolders(ctx, wcfg, vault, func(rules *sql_manager.SyncRules) error {
+			switch action {
+			case "only":
+				rules.Include = locations
+			case "add":
+				for _, location := range locations {
+					if err := rules.Add(location); err != nil {
+						return err
+					}
+				}
+			case "remove":
+				for _, location := range locations {
+					rules.Remove(location)
+				}
+			}
+			return nil
+		})
+		if result != nil {
+			results = append(results, result)
+		}
+		if err != nil {
+			selectErr = fmt.Errorf("vault %s: %w", vault, err)
+			break
+		}
+	}
+	// changes made before a failure are on disk, so they are listed
+	if err := output(*asJSON, results, func() { printSelectResults(results) }); err != nil {
+		return err
+	}
+	return selectErr
+}
+
+func printSelectResults(results []*watcher.SelectResult) {
+	for _, result := range results {
+		fmt.Printf("vault %s: %d downloaded, %d removed", result.Vault, len(result.Downloaded), len(result.Removed))
+		if len(result.Deferred) > 0 {
+			fmt.Printf(", %d attachments only recorded", len(result.Deferred))
+		}
+		fmt.Println()
+		printRules(result.Include, result.Exclude)
+	}
+}
+
+// showFolders prints the rules this device last got from the server, it
+// works offline.
+func showFolders(cfg *config.Config, asJSON bool) error {
+	db, err := openDB(&cfg.Client)
+	if err != nil {
+		return err
+	}
+	results := []folderRules{}
+	for _, folder := range cfg.Client.Folders {
+		rules, err := sql_manager.GetLocalRules(db, folder.Vault)
+		if err != nil {
+			return err
+		}
+		results = append(results, folderRules{Vault: folder.Vault, Include: rules.Include, Exclude: rules.Exclude})
+	}
+	return output(asJSON, results, func() {
+		for _, result := range results {
+			fmt.Printf("vault %s\n", result.Vault)
+			printRules(result.Include, result.Exclude)
+		}
+	})
+}
+
+func printRules(include, exclude []string) {
+	if len(include) == 0 && len(exclude) == 0 {
+		fmt.Println("  every folder is synced")
+		return
+	}
+	if len(include) > 0 {
+		fmt.Printf("  only: %s\n", strings.Join(include, ", "))
+	}
+	if len(exclude) > 0 {
+		fmt.Printf("  not synced: %s\n", strings.Join(exclude, ", "))
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itsrobel/sync/internal/config"
+	"github.com/itsrobel/sync/internal/diff"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/watcher"
+	"gorm.io/gorm"
+)
+
+type versionInfo struct {
+	Number    int       `json:"number"`
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Client    string    `json:"client"`
+	Size      int       `json:"size"`
+	Current   bool      `json:"current"`
+}
+
+// fileHistory resolves a path on disk to its file record and versions,
+// oldest first.
+type fileHistory struct {
+	folder   watcher.Folder
+	location string
+	path     string
+	file     *sql_manager.File
+	versions []sql_manager.FileVersion
+}
+
+func loadHistory(cfg *config.ClientConfig, path string) (*fileHistory, error) {
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := watcherFolders(cfg)
+	if err != nil {
+		return nil, err
+	}
+	folder, location, err := watcher.Locate(folders, path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := sql_manager.FindFileByLocation(db, folder.Vault, location)
+	if err == gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("%s has no recorded versions", location)
+	} else if err != nil {
+		return nil, err
+	}
+	versions, err := sql_manager.GetAllFileVersions(db, folder.Vault, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s has no recorded versions", location)
+	}
+
+	abs, _ := filepath.Abs(path)
+	return &fileHistory{folder: folder, location: location, path: abs, file: file, versions: versions}, nil
+}
+
+// version finds a version by its number in the log or a prefix of its id.
+func (h *fileHistory) version(ref string) (*sql_manager.FileVersion, int, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(h.versions) {
+			return nil, 0, fmt.Errorf("version %d out of range, %s has %d versions", n, h.location, len(h.versions))
+		}
+		return &h.versions[n-1], n, nil
+	}
+
+	found := -1
+	for i, version := range h.versions {
+		if strings.HasPrefix(version.ID, ref) {
+			if found >= 0 {
+				return nil, 0, fmt.Errorf("version %q is ambiguous", ref)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return nil, 0, fmt.Errorf("no version %q of %s", ref, h.location)
+	}
+	return &h.versions[found], found + 1, nil
+}
+
+func (h *fileHistory) info() []versionInfo {
+	infos := make([]versionInfo, len(h.versions))
+	for i, version := range h.versions {
+		client := version.Client
+		if client == "" {
+			client = "local"
+		}
+		infos[i] = versionInfo{
+			Number:    i + 1,
+			ID:        version.ID,
+			Timestamp: version.Timestamp,
+			Client:    client,
+			Size:      len(version.Content),
+			Current:   version.Content == h.file.Content && i == len(h.versions)-1,
+		}
+		if version.Blob {
+			infos[i].Size = int(version.Size)
+			infos[i].Current = version.Hash == h.file.Hash && i == len(h.versions)-1
+		}
+	}
+	return infos
+}
+
+func runLog(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("log")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: log <path>")
+	}
+
+	history, err := loadHistory(&cfg.Client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	infos := history.info()
+	return output(*asJSON, infos, func() {
+		for i := len(infos) - 1; i >= 0; i-- {
+			info := infos[i]
+			marker := ""
+			if info.Current {
+				marker = " (current)"
+			}
+			fmt.Printf("%3d  %s  %s  %-16s %6d bytes%s\n",
+				info.Number, info.ID[:8], info.Timestamp.Local().Format(time.DateTime), info.Client, info.Size, marker)
+		}
+	})
+}
+
+type diffResult struct {
+	Location string `json:"location"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Changed  bool   `json:"changed"`
+	Unified  string `json:"unified"`
+}
+
+// runDiff compares two versions, or a version (the latest by default) with
+// the file on disk.
+func runDiff(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("diff")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 3 {
+		return fmt.Errorf("usage: diff <path> [v1] [v2]")
+	}
+
+	history, err := loadHistory(&cfg.Client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if history.file.Blob {
+		return fmt.Errorf("%s is an attachment, only notes can be diffed", history.location)
+	}
+
+	from := &history.versions[len(history.versions)-1]
+	fromNumber := len(history.versions)
+	if fs.NArg() > 1 {
+		if from, fromNumber, err = history.version(fs.Arg(1)); err != nil {
+			return err
+		}
+	}
+
+	result := diffResult{Location: history.location, From: fmt.Sprintf("%d (%s)", fromNumber, from.ID[:8])}
+	var to string
+	if fs.NArg() > 2 {
+		version, number, err := history.version(fs.Arg(2))
+		if err != nil {
+			return err
+		}
+		to = version.Content
+		result.To = fmt.Sprintf("%d (%s)", number, version.ID[:8])
+	} else {
+		data, err := os.ReadFile(history.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		to = string(data)
+		result.To = "disk"
+	}
+
+	result.Unified = diff.Unified(from.Content, to,
+		history.location+"@"+result.From, history.location+"@"+result.To, 3)
+	result.Changed = result.Unified != ""
+	return output(*asJSON, result, func() { fmt.Print(result.Unified) })
+}
+
+// runRestore writes an older version back to disk. The watcher records it as
+// a new version and uploads it like any other edit.
+func runRestore(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("restore")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: restore <path> <version>")
+	}
+
+	history, err := loadHistory(&cfg.Client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	version, number, err := history.version(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if version.Blob {
+		// attachment content is only kept by the server
+		return fmt.Errorf("%s is an attachment, older versions are not stored locally", history.location)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(history.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(history.path, []byte(version.Content), 0644); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", history.location, err)
+	}
+
+	result := struct {
+		Location string `json:"location"`
+		Version  int    `json:"version"`
+		ID       string `json:"id"`
+	}{history.location, number, version.ID}
+	return output(*asJSON, result, func() {
+		fmt.Printf("restored %s to version %d (%s)\n", history.location, number, version.ID[:8])
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/itsrobel/sync/internal/config"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/watcher"
+	"gorm.io/gorm"
+)
+
+type linkResult struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"` // location, empty when unresolved
+	Name    string `json:"name"`   // as written in the note
+	Heading string `json:"heading,omitempty"`
+	Embed   bool   `json:"embed"`
+	Line    int    `json:"line"`
+}
+
+type fileLinks struct {
+	Location  string       `json:"location"`
+	Outgoing  []linkResult `json:"outgoing"`
+	Backlinks []linkResult `json:"backlinks"`
+}
+
+// runLinks shows the links of a note from the local index, or every
+// unresolved link with -unresolved.
+func runLinks(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("links")
+	unresolved := fs.Bool("unresolved", false, "list links that point at no file in every synced vault")
+	fs.Parse(args)
+
+	db, err := openDB(&cfg.Client)
+	if err != nil {
+		return err
+	}
+
+	if *unresolved {
+		if fs.NArg() != 0 {
+			return fmt.Errorf("usage: links -unresolved")
+		}
+		results := map[string][]linkResult{}
+		for _, folder := range cfg.Client.Folders {
+			infos, err := sql_manager.GetUnresolvedLinks(db, folder.Vault)
+			if err != nil {
+				return err
+			}
+			results[folder.Vault] = linkResults(infos)
+		}
+		return output(*asJSON, results, func() {
+			for _, folder := range cfg.Client.Folders {
+				for _, link := range results[folder.Vault] {
+					fmt.Printf("%s:%s:%d  [[%s]]\n", folder.Vault, link.Source, link.Line, link.Name)
+				}
+			}
+		})
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: links [-unresolved] <path>")
+	}
+	folders, err := watcherFolders(&cfg.Client)
+	if err != nil {
+		return err
+	}
+	folder, location, err := watcher.Locate(folders, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	file, err := sql_manager.FindFileByLocation(db, folder.Vault, location)
+	if err == gorm.ErrRecordNotFound {
+		return fmt.Errorf("%s is not synced yet", location)
+	} else if err != nil {
+		return err
+	}
+
+	outgoing, err := sql_manager.GetOutgoingLinks(db, folder.Vault, file.ID)
+	if err != nil {
+		return err
+	}
+	backlinks, err := sql_manager.GetBacklinks(db, folder.Vault, file.ID)
+	if err != nil {
+		return err
+	}
+	result := fileLinks{Location: location, Outgoing: linkResults(outgoing), Backlinks: linkResults(backlinks)}
+
+	return output(*asJSON, result, func() {
+		fmt.Printf("links in %s:\n", location)
+		for _, link := range result.Outgoing {
+			target := link.Target
+			if target == "" {
+				target = "(unresolved)"
+			}
+			fmt.Printf("  %4d  [[%s]] -> %s\n", link.Line, link.Name, target)
+		}
+		fmt.Printf("linked from:\n")
+		for _, link := range result.Backlinks {
+			fmt.Printf("  %s:%d\n", link.Source, link.Line)
+		}
+	})
+}
+
+func linkResults(infos []sql_manager.LinkInfo) []linkResult {
+	results := make([]linkResult, len(infos))
+	for i, info := range infos {
+		results[i] = linkResult{
+			Source:  info.SourceLocation,
+			Target:  info.TargetLocation,
+			Name:    info.TargetName,
+			Heading: info.Heading,
+			Embed:   info.Embed,
+			Line:    info.Line,
+		}
+	}
+	return results
+}
@@ -1,46 +1,211 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
-	"os/signal"
-	"syscall"
+	"sort"
+	"strings"
 
+	"github.com/itsrobel/sync/internal/config"
 	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
+	"github.com/itsrobel/sync/internal/sql_manager"
 	"github.com/itsrobel/sync/internal/watcher"
+	"gorm.io/gorm"
 )
 
 type FileTransferClient struct {
 	filetransferconnect.FileServiceClient
 }
 
+// command is one cli subcommand. run gets the arguments after the command
+// name and parses its own flags.
+type command struct {
+	usage string
+	help  string
+	run   func(cfg *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"init":       {"init [-vault name] <path>", "bind a folder to a vault on the server", runInit},
+	"plan":       {"plan", "show what a sync would upload, download, delete, rename or conflict", runPlan},
+	"sync":       {"sync [-timeout 2m] [-dry-run]", "sync every folder once without watching, exit 3 on conflicts and 4 when uploads are left", runSync},
+	"watch":      {"watch", "keep syncing until interrupted (default)", runWatch},
+	"status":     {"status", "show pending uploads, conflicts and the last sync", runStatus},
+	"links":      {"links [-unresolved] <path>", "show the links of a note and the notes linking to it", runLinks},
+	"log":        {"log <path>", "list the recorded versions of a file", runLog},
+	"diff":       {"diff <path> [v1] [v2]", "diff two versions, or a version and the file on disk", runDiff},
+	"restore":    {"restore <path> <version>", "write an older version back to disk", runRestore},
+	"folders":    {"folders [add|remove|only <path>...]", "show or change which folders this device mirrors", runFolders},
+	"fetch":      {"fetch [-timeout 10m] <path>...", "download attachments that were only recorded, a folder fetches all below it", runFetch},
+	"conflicts":  {"conflicts", "list conflict copies that still need merging", runConflicts},
+	"search":     {"search [-vault v] [-folder f] [-remote] <query>", "full-text search the local copy, or the server with -remote", runSearch},
+	"query":      {"query [-vault v] [-remote] <query>", "list notes by tag, front matter and date, like tag:project status:done", runQuery},
+	"share":      {"share [-expires 24h] [-password-file f] [-pinned] <vault:location>", "create a share link", runShare},
+	"rotate-key": {"rotate-key -passphrase-file <file>", "re-encrypt every vault with a new passphrase", runRotateKey},
+}
+
+// folderFlags collects repeated -folder path=vault mappings. The first one
+// replaces the folders from the config file instead of adding to them.
+type folderFlags struct {
+	folders *[]config.FolderConfig
+	set     bool
+}
+
+func (f *folderFlags) String() string {
+	if f.folders == nil {
+		return ""
+	}
+	parts := make([]string, len(*f.folders))
+	for i, folder := range *f.folders {
+		parts[i] = folder.Path + "=" + folder.Vault
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *folderFlags) Set(value string) error {
+	path, vault, found := strings.Cut(value, "=")
+	if !found || path == "" || vault == "" {
+		return fmt.Errorf("expected path=vault, got %q", value)
+	}
+	if !f.set {
+		*f.folders = nil
+		f.set = true
+	}
+	*f.folders = append(*f.folders, config.FolderConfig{Path: path, Vault: vault})
+	return nil
+}
+
 func main() {
-	test()
+	cfg, err := config.Load(config.Path(os.Args[1:]))
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	client := &cfg.Client
+	// already read by config.Path, registered so flag.Parse accepts it
+	flag.String("config", "", "path to a yaml or toml config file (or set SYNC_CONFIG)")
+	flag.StringVar(&client.Server, "server", client.Server, "address of the sync server")
+	flag.StringVar(&client.Name, "name", client.Name, "name this client uses as its session id")
+	flag.StringVar(&client.DBPath, "db", client.DBPath, "path of the local sqlite database")
+	flag.StringVar(&client.Token, "token", client.Token, "device token issued by the server (or set SYNC_TOKEN)")
+	flag.BoolVar(&client.TLS.Enabled, "tls", client.TLS.Enabled, "connect to the server over TLS")
+	flag.StringVar(&client.TLS.CA, "tls-ca", client.TLS.CA, "PEM bundle of CAs trusted for the server certificate")
+	flag.Var((*config.StringList)(&client.TLS.Pins), "tls-pin", "comma separated sha256 fingerprints of trusted server certificates")
+	flag.StringVar(&client.PassphraseFile, "passphrase-file", client.PassphraseFile, "file holding the vault passphrase (or set SYNC_PASSPHRASE)")
+	flag.BoolVar(&client.EncryptPaths, "encrypt-paths", client.EncryptPaths, "also encrypt file paths when creating the vault key")
+	flag.Var(&folderFlags{folders: &client.Folders}, "folder", "map a local folder to a vault as path=vault (repeatable)")
+	flag.Usage = usage
+	flag.Parse()
+
+	name, args := "watch", flag.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	// init is what creates a valid client section
+	if name != "init" {
+		if err := client.Validate(); err != nil {
+			log.Fatalf("Invalid config: %v", err)
+		}
+	}
+	err = cmd.run(cfg, args)
+	var status exitStatus
+	if errors.As(err, &status) {
+		os.Exit(int(status))
+	} else if err != nil {
+		log.Fatal(err)
+	}
 }
 
-func test() {
-	// Set up paths
-	dbPath := "./sync-test.db"
-	watchPath := "./content"
-	clientName := "test-2"
+// exitStatus ends the cli with a specific code after the command already
+// printed its output.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
 
-	// Ensure content directory exists
-	if err := os.MkdirAll(watchPath, 0755); err != nil {
-		log.Fatalf("Failed to create watch directory: %v", err)
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [flags] <command> [command flags] [args]\n\ncommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-70s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintln(os.Stderr, "\nevery command accepts -json for machine readable output\n\nflags:")
+	flag.PrintDefaults()
+}
 
-	// Initialize file watcher
-	fw, err := watcher.InitFileWatcher(dbPath, watchPath, clientName)
+// commandFlags returns the flag set for a subcommand with the shared -json flag.
+func commandFlags(name string) (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print machine readable output")
+	return fs, asJSON
+}
+
+// output prints v as JSON or hands over to text for the human readable form.
+func output(asJSON bool, v interface{}, text func()) error {
+	if !asJSON {
+		text()
+		return nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func openDB(cfg *config.ClientConfig) (*gorm.DB, error) {
+	return sql_manager.ConnectSQLite(cfg.DBPath)
+}
+
+// watcherFolders turns the configured folders into watcher folders with the
+// passphrase applied.
+func watcherFolders(cfg *config.ClientConfig) ([]watcher.Folder, error) {
+	passphrase, err := readPassphrase(cfg.PassphraseFile, "SYNC_PASSPHRASE")
 	if err != nil {
-		log.Fatalf("Failed to initialize file watcher: %v", err)
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
 	}
-	defer fw.Stop()
 
-	// Handle graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	folders := make([]watcher.Folder, len(cfg.Folders))
+	for i, folder := range cfg.Folders {
+		maxFileSize, err := config.ParseSize(folder.MaxFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("folder %s: %w", folder.Path, err)
+		}
+		folders[i] = watcher.Folder{
+			Path:            folder.Path,
+			Vault:           folder.Vault,
+			Passphrase:      passphrase,
+			EncryptPaths:    cfg.EncryptPaths,
+			MaxFileSize:     maxFileSize,
+			LazyAttachments: folder.LazyAttachments,
+		}
+	}
+	return folders, nil
+}
 
-	log.Printf("File watcher started. Watching directory: %s", watchPath)
-	<-sigChan
-	log.Println("Shutting down...")
+func readPassphrase(path, env string) (string, error) {
+	if path == "" {
+		if env == "" {
+			return "", nil
+		}
+		return os.Getenv(env), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
 }
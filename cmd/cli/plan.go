@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/itsrobel/sync/internal/config"
+	"github.com/itsrobel/sync/internal/watcher"
+)
+
+func runPlan(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("plan")
+	fs.Parse(args)
+	return showPlan(cfg, *asJSON)
+}
+
+func showPlan(cfg *config.Config, asJSON bool) error {
+	// plans never write, folders that do not exist yet are simply empty
+	wcfg := watcher.Config{
+		DBPath:     cfg.Client.DBPath,
+		ClientName: cfg.Client.Name,
+		ServerAddr: cfg.Client.Server,
+		TLS:        cfg.Client.TLS.Options(),
+		Token:      cfg.Client.Token,
+	}
+	var err error
+	if wcfg.Folders, err = watcherFolders(&cfg.Client); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	plan, err := watcher.Plan(ctx, wcfg)
+	if err != nil {
+		return err
+	}
+	return output(asJSON, plan, func() { printPlan(plan) })
+}
+
+func printPlan(plan *watcher.SyncPlan) {
+	for i, vault := range plan.Vaults {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("vault %s at %s\n", vault.Vault, vault.Path)
+		if vault.CreateVault {
+			fmt.Println("  the vault will be created on the server")
+		}
+		if vault.ReadOnly {
+			fmt.Println("  read-only, local changes will stay queued")
+		}
+		if len(vault.Items) == 0 {
+			fmt.Println("  nothing to do")
+			continue
+		}
+
+		var total int64
+		for _, item := range vault.Items {
+			location := item.Location
+			if item.From != "" {
+				location = item.From + " -> " + item.Location
+			}
+			fmt.Printf("  %-8s %-6s %-50s %8s  %s\n", item.Action, item.Target, location, formatSize(item.Size), item.Reason)
+			total += item.Size
+		}
+		fmt.Printf("  %d change(s), %s\n", len(vault.Items), formatSize(total))
+	}
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/config"
+	"github.com/itsrobel/sync/internal/frontmatter"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/sql_manager"
+)
+
+type queryResult struct {
+	Vault     string              `json:"vault"`
+	Location  string              `json:"location"`
+	Timestamp time.Time           `json:"timestamp"`
+	Tags      []string            `json:"tags"`
+	Fields    map[string][]string `json:"fields"`
+}
+
+// runQuery lists notes from the local database like search does, -remote
+// asks the server instead.
+func runQuery(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("query")
+	vault := fs.String("vault", "", "only query this vault, all synced vaults by default")
+	limit := fs.Int("limit", sql_manager.DefaultQueryLimit, "maximum number of notes per vault")
+	remote := fs.Bool("remote", false, "query the server instead of the local copy")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: query [flags] <query>")
+	}
+	text := strings.Join(fs.Args(), " ")
+	query, err := sql_manager.ParseQuery(text)
+	if err != nil {
+		return err
+	}
+
+	var vaults []string
+	for _, mapped := range cfg.Client.Folders {
+		if *vault == "" || mapped.Vault == *vault {
+			vaults = append(vaults, mapped.Vault)
+		}
+	}
+	if *vault != "" && len(vaults) == 0 {
+		if !*remote {
+			return fmt.Errorf("vault %s is not synced to this machine, use -remote", *vault)
+		}
+		vaults = []string{*vault}
+	}
+
+	results := []queryResult{}
+	for _, vaultID := range vaults {
+		var notes []queryResult
+		var err error
+		if *remote {
+			notes, err = queryRemote(&cfg.Client, vaultID, text, *limit)
+		} else {
+			notes, err = queryLocal(&cfg.Client, vaultID, query, *limit)
+		}
+		if err != nil {
+			return fmt.Errorf("vault %s: %w", vaultID, err)
+		}
+		results = append(results, notes...)
+	}
+
+	return output(*asJSON, results, func() {
+		if len(results) == 0 {
+			fmt.Println("no matching notes")
+		}
+		for _, note := range results {
+			line := note.Vault + ":" + note.Location
+			for _, tag := range note.Tags {
+				line += " #" + tag
+			}
+			fmt.Println(line)
+		}
+	})
+}
+
+func queryLocal(cfg *config.ClientConfig, vaultID string, query *sql_manager.NoteQuery, limit int) ([]queryResult, error) {
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := sql_manager.QueryNotes(db, vaultID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]queryResult, len(notes))
+	for i, note := range notes {
+		results[i] = newQueryResult(vaultID, note.Location, note.Timestamp, note.Tags, note.Fields)
+	}
+	return results, nil
+}
+
+func queryRemote(cfg *config.ClientConfig, vaultID, query string, limit int) ([]queryResult, error) {
+	client, err := newServiceClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := client.QueryNotes(ctx, connect.NewRequest(&ft.NoteQuery{
+		VaultId: vaultID,
+		Query:   query,
+		Limit:   int32(limit),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]queryResult, len(res.Msg.Notes))
+	for i, note := range res.Msg.Notes {
+		fields := make([]frontmatter.Field, len(note.Fields))
+		for j, field := range note.Fields {
+			fields[j] = frontmatter.Field{Key: field.Key, Value: field.Value}
+		}
+		results[i] = newQueryResult(vaultID, note.Location, note.Timestamp.AsTime(), note.Tags, fields)
+	}
+	return results, nil
+}
+
+func newQueryResult(vaultID, location string, timestamp time.Time, tags []string, fields []frontmatter.Field) queryResult {
+	result := queryResult{
+		Vault:     vaultID,
+		Location:  location,
+		Timestamp: timestamp,
+		Tags:      tags,
+		Fields:    make(map[string][]string),
+	}
+	if result.Tags == nil {
+		result.Tags = []string{}
+	}
+	for _, field := range fields {
+		result.Fields[field.Key] = append(result.Fields[field.Key], field.Value)
+	}
+	return result
+}
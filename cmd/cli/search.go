@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/config"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/sql_manager"
+)
+
+type searchResult struct {
+	Vault     string    `json:"vault"`
+	Location  string    `json:"location"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// runSearch searches the local database so it works offline, -remote asks
+// the server instead.
+func runSearch(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("search")
+	vault := fs.String("vault", "", "only search this vault, all synced vaults by default")
+	folder := fs.String("folder", "", "only search below this folder of the vault")
+	limit := fs.Int("limit", sql_manager.DefaultSearchLimit, "maximum number of hits per vault")
+	remote := fs.Bool("remote", false, "search on the server instead of the local copy")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: search [flags] <query>")
+	}
+	query := strings.Join(fs.Args(), " ")
+
+	var vaults []string
+	for _, mapped := range cfg.Client.Folders {
+		if *vault == "" || mapped.Vault == *vault {
+			vaults = append(vaults, mapped.Vault)
+		}
+	}
+	if *vault != "" && len(vaults) == 0 {
+		if !*remote {
+			return fmt.Errorf("vault %s is not synced to this machine, use -remote", *vault)
+		}
+		vaults = []string{*vault}
+	}
+
+	filter := sql_manager.SearchFilter{Folder: *folder, Limit: *limit}
+	results := []searchResult{}
+	for _, vaultID := range vaults {
+		var hits []searchResult
+		var err error
+		if *remote {
+			hits, err = searchRemote(&cfg.Client, vaultID, query, filter)
+		} else {
+			hits, err = searchLocal(&cfg.Client, vaultID, query, filter)
+		}
+		if err != nil {
+			return fmt.Errorf("vault %s: %w", vaultID, err)
+		}
+		results = append(results, hits...)
+	}
+
+	return output(*asJSON, results, func() {
+		if len(results) == 0 {
+			fmt.Println("no matches")
+		}
+		for _, hit := range results {
+			fmt.Printf("%s:%s\n  %s\n", hit.Vault, hit.Location, highlight(hit.Snippet))
+		}
+	})
+}
+
+func searchLocal(cfg *config.ClientConfig, vaultID, query string, filter sql_manager.SearchFilter) ([]searchResult, error) {
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	hits, err := sql_manager.SearchFiles(db, vaultID, query, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]searchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = searchResult{vaultID, hit.Location, hit.Snippet, hit.Rank, hit.Timestamp}
+	}
+	return results, nil
+}
+
+func searchRemote(cfg *config.ClientConfig, vaultID, query string, filter sql_manager.SearchFilter) ([]searchResult, error) {
+	client, err := newServiceClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := client.Search(ctx, connect.NewRequest(&ft.SearchRequest{
+		Query:   query,
+		VaultId: vaultID,
+		Folder:  filter.Folder,
+		Limit:   int32(filter.Limit),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]searchResult, len(res.Msg.Hits))
+	for i, hit := range res.Msg.Hits {
+		results[i] = searchResult{vaultID, hit.Location, hit.Snippet, hit.Rank, hit.Timestamp.AsTime()}
+	}
+	return results, nil
+}
+
+// highlight shows matches in bold on a terminal and folds the snippet onto
+// one line.
+func highlight(snippet string) string {
+	snippet = strings.Join(strings.Fields(snippet), " ")
+	return strings.NewReplacer("<mark>", "\033[1m", "</mark>", "\033[0m").Replace(snippet)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/config"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type shareResult struct {
+	ID        string     `json:"id"`
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// runShare creates a share link for vault:location, a location ending in /
+// (or empty) shares a folder.
+func runShare(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("share")
+	expires := fs.Duration("expires", 0, "lifetime of the share link, 0 never expires")
+	passwordFile := fs.String("password-file", "", "protect the share link with the password in this file")
+	pinned := fs.Bool("pinned", false, "share the current revision instead of following later edits")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: share [flags] <vault:location>")
+	}
+
+	vault, location, found := strings.Cut(fs.Arg(0), ":")
+	if !found {
+		return fmt.Errorf("expected vault:location, got %q", fs.Arg(0))
+	}
+
+	password, err := readPassphrase(*passwordFile, "")
+	if err != nil {
+		return fmt.Errorf("failed to read share password: %w", err)
+	}
+
+	client, err := newServiceClient(&cfg.Client)
+	if err != nil {
+		return err
+	}
+
+	req := &ft.ShareLink{
+		VaultId:  vault,
+		Location: location,
+		Folder:   location == "" || strings.HasSuffix(location, "/"),
+		Password: password,
+		Pinned:   *pinned,
+	}
+	if *expires > 0 {
+		req.ExpiresAt = timestamppb.New(time.Now().Add(*expires))
+	}
+
+	res, err := client.CreateShareLink(context.Background(), connect.NewRequest(req))
+	if err != nil {
+		return fmt.Errorf("failed to create share link: %w", err)
+	}
+
+	result := shareResult{ID: res.Msg.Id, URL: "/s/" + res.Msg.Token}
+	if res.Msg.ExpiresAt != nil {
+		at := res.Msg.ExpiresAt.AsTime()
+		result.ExpiresAt = &at
+	}
+	return output(*asJSON, result, func() { fmt.Println(result.URL) })
+}
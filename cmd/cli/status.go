@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/itsrobel/sync/internal/config"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/watcher"
+	"gorm.io/gorm"
+)
+
+type vaultStatus struct {
+	Vault     string                `json:"vault"`
+	Path      string                `json:"path"`
+	LastSync  *time.Time            `json:"last_sync"`
+	Pending   []pendingUpload       `json:"pending"`
+	Conflicts []conflictInfo        `json:"conflicts"`
+	Changes   []watcher.LocalChange `json:"changes"`
+}
+
+type pendingUpload struct {
+	Location  string    `json:"location"`
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+type conflictInfo struct {
+	Location     string    `json:"location"`
+	CopyLocation string    `json:"copy_location"`
+	RemoteClient string    `json:"remote_client"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func runStatus(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("status")
+	fs.Parse(args)
+
+	report, err := statusReport(&cfg.Client)
+	if err != nil {
+		return err
+	}
+	return output(*asJSON, report, func() { printStatus(report) })
+}
+
+// statusReport reads the state of every folder from the local database and
+// disk, it works without a running watcher or a reachable server.
+func statusReport(cfg *config.ClientConfig) ([]vaultStatus, error) {
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := watcherFolders(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]vaultStatus, 0, len(folders))
+	for _, folder := range folders {
+		path, _ := filepath.Abs(folder.Path)
+		status := vaultStatus{
+			Vault:     folder.Vault,
+			Path:      path,
+			Pending:   []pendingUpload{},
+			Conflicts: []conflictInfo{},
+		}
+
+		lastSync, err := sql_manager.GetSyncState(db, folder.Vault)
+		if err != nil {
+			return nil, err
+		}
+		if !lastSync.IsZero() {
+			status.LastSync = &lastSync
+		}
+
+		pending, err := sql_manager.GetPending(db, folder.Vault)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range pending {
+			status.Pending = append(status.Pending, pendingUpload{
+				Location:  entry.Location,
+				Version:   entry.VersionID,
+				CreatedAt: entry.CreatedAt,
+				Attempts:  entry.Attempts,
+				LastError: entry.LastError,
+			})
+		}
+
+		status.Conflicts, err = openConflicts(db, folder.Vault, path)
+		if err != nil {
+			return nil, err
+		}
+
+		status.Changes, err = watcher.ScanChanges(db, folder)
+		if err != nil {
+			return nil, err
+		}
+		if status.Changes == nil {
+			status.Changes = []watcher.LocalChange{}
+		}
+		report = append(report, status)
+	}
+	return report, nil
+}
+
+// openConflicts lists the conflicts of a vault. Once the conflict copy is
+// deleted the conflict counts as resolved and its record is dropped.
+func openConflicts(db *gorm.DB, vaultID, root string) ([]conflictInfo, error) {
+	conflicts, err := sql_manager.GetConflicts(db, vaultID)
+	if err != nil {
+		return nil, err
+	}
+
+	open := []conflictInfo{}
+	for _, conflict := range conflicts {
+		copyPath := filepath.Join(root, filepath.FromSlash(conflict.CopyLocation))
+		if _, err := os.Stat(copyPath); os.IsNotExist(err) {
+			if err := sql_manager.DeleteConflict(db, conflict.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		open = append(open, conflictInfo{
+			Location:     conflict.Location,
+			CopyLocation: conflict.CopyLocation,
+			RemoteClient: conflict.RemoteClient,
+			CreatedAt:    conflict.CreatedAt,
+		})
+	}
+	return open, nil
+}
+
+func printStatus(report []vaultStatus) {
+	for i, status := range report {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("vault %s at %s\n", status.Vault, status.Path)
+		if status.LastSync != nil {
+			fmt.Printf("  last sync: %s\n", status.LastSync.Local().Format(time.DateTime))
+		} else {
+			fmt.Println("  last sync: never")
+		}
+
+		if len(status.Pending) > 0 {
+			fmt.Printf("  pending uploads:\n")
+			for _, entry := range status.Pending {
+				fmt.Printf("    %s", entry.Location)
+				if entry.LastError != "" {
+					fmt.Printf(" (%d failed attempts: %s)", entry.Attempts, entry.LastError)
+				}
+				fmt.Println()
+			}
+		}
+		if len(status.Changes) > 0 {
+			fmt.Printf("  local changes not recorded yet:\n")
+			for _, change := range status.Changes {
+				fmt.Printf("    %-8s %s\n", change.Kind, change.Location)
+			}
+		}
+		if len(status.Conflicts) > 0 {
+			fmt.Printf("  conflicts:\n")
+			for _, conflict := range status.Conflicts {
+				fmt.Printf("    %s -> %s\n", conflict.Location, conflict.CopyLocation)
+			}
+		}
+		if len(status.Pending)+len(status.Changes)+len(status.Conflicts) == 0 {
+			fmt.Println("  up to date")
+		}
+	}
+}
+
+func runConflicts(cfg *config.Config, args []string) error {
+	fs, asJSON := commandFlags("conflicts")
+	fs.Parse(args)
+
+	db, err := openDB(&cfg.Client)
+	if err != nil {
+		return err
+	}
+	type vaultConflicts struct {
+		Vault     string         `json:"vault"`
+		Path      string         `json:"path"`
+		Conflicts []conflictInfo `json:"conflicts"`
+	}
+
+	var result []vaultConflicts
+	for _, folder := range cfg.Client.Folders {
+		path, _ := filepath.Abs(folder.Path)
+		conflicts, err := openConflicts(db, folder.Vault, path)
+		if err != nil {
+			return err
+		}
+		result = append(result, vaultConflicts{Vault: folder.Vault, Path: path, Conflicts: conflicts})
+	}
+
+	return output(*asJSON, result, func() {
+		total := 0
+		for _, vault := range result {
+			for _, conflict := range vault.Conflicts {
+				fmt.Printf("%s: %s\n  remote version by %s kept at %s, local edit moved to %s\n",
+					vault.Vault, conflict.Location, conflict.RemoteClient, conflict.Location, conflict.CopyLocation)
+				total++
+			}
+		}
+		if total == 0 {
+			fmt.Println("no conflicts")
+		} else {
+			fmt.Println("\nmerge the copy into the file and delete it to resolve a conflict")
+		}
+	})
+}
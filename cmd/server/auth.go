@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"gorm.io/gorm"
+)
+
+// authenticate resolves the caller from its device token. While no users
+// exist the server runs in single-user mode and the user is nil.
+func (s *FileTransferServer) authenticate(header http.Header) (*sql_manager.User, error) {
+	count, err := sql_manager.CountUsers(s.db)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	token := auth.BearerToken(header)
+	if token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("missing device token"))
+	}
+	user, err := sql_manager.FindUserByToken(s.db, auth.HashToken(token))
+	if err == gorm.ErrRecordNotFound {
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid device token"))
+	}
+	return user, err
+}
+
+// userID is the ID of user, empty on servers without users.
+func userID(user *sql_manager.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.ID
+}
+
+// authorize authenticates the caller and checks it holds at least need on
+// the vault.
+func (s *FileTransferServer) authorize(header http.Header, vaultID string, need auth.Role) (*sql_manager.User, auth.Role, error) {
+	user, err := s.authenticate(header)
+	if err != nil {
+		return nil, "", err
+	}
+	role, err := s.authorizeUser(user, vaultID, need)
+	return user, role, err
+}
+
+func (s *FileTransferServer) authorizeUser(user *sql_manager.User, vaultID string, need auth.Role) (auth.Role, error) {
+	if err := s.requireVault(vaultID); err != nil {
+		return "", err
+	}
+	if user == nil {
+		return auth.RoleOwner, nil
+	}
+
+	membership, err := sql_manager.GetMembership(s.db, vaultID, user.ID)
+	if err == gorm.ErrRecordNotFound {
+		return "", connect.NewError(connect.CodePermissionDenied, fmt.Errorf("%s is not a member of vault %s", user.Name, vaultID))
+	} else if err != nil {
+		return "", err
+	}
+
+	role := auth.Role(membership.Role)
+	if !role.Allows(need) {
+		return role, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("%s is %s on vault %s, %s required", user.Name, role, vaultID, need))
+	}
+	return role, nil
+}
+
+func (s *FileTransferServer) GetAccess(
+	ctx context.Context,
+	req *connect.Request[ft.ActionRequest],
+) (*connect.Response[ft.Membership], error) {
+	user, role, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &ft.Membership{VaultId: req.Msg.VaultId, Role: string(role)}
+	if user != nil {
+		access.User = user.Name
+	}
+	return connect.NewResponse(access), nil
+}
+
+// SetMembership lets a vault owner add, change or remove (empty role) members.
+func (s *FileTransferServer) SetMembership(
+	ctx context.Context,
+	req *connect.Request[ft.Membership],
+) (*connect.Response[ft.ActionResponse], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	member, err := sql_manager.FindUserByName(s.db, req.Msg.User)
+	if err == gorm.ErrRecordNotFound {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("user %s not found", req.Msg.User))
+	} else if err != nil {
+		return nil, err
+	}
+
+	var role auth.Role
+	if req.Msg.Role != "" {
+		if role, err = auth.ParseRole(req.Msg.Role); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+	}
+
+	if role != auth.RoleOwner {
+		if err := s.keepAnOwner(req.Msg.VaultId, member.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sql_manager.SetMembership(s.db, req.Msg.VaultId, member.ID, string(role)); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&ft.ActionResponse{
+		Success: true,
+		Message: fmt.Sprintf("%s is now %s on %s", member.Name, orNone(string(role)), req.Msg.VaultId),
+	}), nil
+}
+
+func (s *FileTransferServer) ListMembers(
+	ctx context.Context,
+	req *connect.Request[ft.ActionRequest],
+) (*connect.Response[ft.MemberList], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return nil, err
+	}
+
+	memberships, err := sql_manager.GetVaultMembers(s.db, req.Msg.VaultId)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*ft.Membership, 0, len(memberships))
+	for _, membership := range memberships {
+		user, err := sql_manager.FindUserById(s.db, membership.UserID)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, &ft.Membership{
+			VaultId: membership.VaultID,
+			User:    user.Name,
+			Role:    membership.Role,
+		})
+	}
+	return connect.NewResponse(&ft.MemberList{Members: members}), nil
+}
+
+// CreateDeviceToken issues another token for the calling user, e.g. to set
+// up a new device.
+func (s *FileTransferServer) CreateDeviceToken(
+	ctx context.Context,
+	req *connect.Request[ft.DeviceToken],
+) (*connect.Response[ft.DeviceToken], error) {
+	user, err := s.authenticate(req.Header())
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("server has no users, create one with -add-user"))
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := sql_manager.CreateDeviceToken(s.db, user.ID, req.Msg.Name, hash); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&ft.DeviceToken{Name: req.Msg.Name, Token: token}), nil
+}
+
+// keepAnOwner refuses a change that would leave the vault without an owner.
+func (s *FileTransferServer) keepAnOwner(vaultID, userID string) error {
+	current, err := sql_manager.GetMembership(s.db, vaultID, userID)
+	if err == gorm.ErrRecordNotFound || (err == nil && current.Role != string(auth.RoleOwner)) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var owners int64
+	if err := s.db.Model(&sql_manager.Membership{}).
+		Where("vault_id = ? AND role = ?", vaultID, auth.RoleOwner).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("vault %s needs at least one owner", vaultID))
+	}
+	return nil
+}
+
+// runAdminCommands handles the -add-user and -grant flags, which bootstrap
+// accounts before any client can authenticate.
+func runAdminCommands(db *gorm.DB) bool {
+	ran := false
+
+	if *addUser != "" {
+		ran = true
+		user, err := sql_manager.CreateUser(db, *addUser)
+		if err != nil {
+			log.Fatalf("Failed to create user: %v", err)
+		}
+		token, hash, err := auth.NewToken()
+		if err != nil {
+			log.Fatalf("Failed to create token: %v", err)
+		}
+		if err := sql_manager.CreateDeviceToken(db, user.ID, "initial", hash); err != nil {
+			log.Fatalf("Failed to store token: %v", err)
+		}
+		fmt.Printf("created user %s\ndevice token: %s\n", user.Name, token)
+	}
+
+	if *grant != "" {
+		ran = true
+		parts := strings.Split(*grant, ":")
+		if len(parts) != 3 {
+			log.Fatalf("Expected -grant user:vault:role, got %q", *grant)
+		}
+		user, err := sql_manager.FindUserByName(db, parts[0])
+		if err != nil {
+			log.Fatalf("Failed to find user %s: %v", parts[0], err)
+		}
+		if _, err := sql_manager.FindVault(db, parts[1]); err != nil {
+			log.Fatalf("Failed to find vault %s: %v", parts[1], err)
+		}
+		role, err := auth.ParseRole(parts[2])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := sql_manager.SetMembership(db, parts[1], user.ID, string(role)); err != nil {
+			log.Fatalf("Failed to grant role: %v", err)
+		}
+		fmt.Printf("%s is now %s on %s\n", user.Name, role, parts[1])
+	}
+
+	return ran
+}
+
+func orNone(role string) string {
+	if role == "" {
+		return "removed"
+	}
+	return role
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// member creates a user with a device token and the role on the default
+// vault, no role leaves the user outside the vault. It returns the token.
+func member(t *testing.T, s *FileTransferServer, name string, role auth.Role) string {
+	t.Helper()
+	user, err := sql_manager.CreateUser(s.db, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sql_manager.CreateDeviceToken(s.db, user.ID, name+"'s laptop", hash); err != nil {
+		t.Fatal(err)
+	}
+	if role != "" {
+		if err := sql_manager.SetMembership(s.db, sql_manager.DefaultVault, user.ID, string(role)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return token
+}
+
+func TestAccessControl(t *testing.T) {
+	s, url := testServer(t)
+	fileID, base := seed(t, s, "a.md", "one")
+	owner := member(t, s, "alice", auth.RoleOwner)
+	reader := member(t, s, "bob", auth.RoleReader)
+	outsider := member(t, s, "carol", "")
+
+	rpc := func(token string) filetransferconnect.FileServiceClient {
+		var options []connect.ClientOption
+		if token != "" {
+			options = append(options, auth.WithToken(token))
+		}
+		return filetransferconnect.NewFileServiceClient(http.DefaultClient, url, options...)
+	}
+	list := func(token string) error {
+		_, err := rpc(token).RetrieveListOfFiles(context.Background(), connect.NewRequest(&ft.ActionRequest{VaultId: sql_manager.DefaultVault}))
+		return err
+	}
+	save := func(token string) error {
+		_, err := rpc(token).SaveEdit(context.Background(), connect.NewRequest(&ft.EditRequest{
+			VaultId:       sql_manager.DefaultVault,
+			FileId:        fileID,
+			BaseVersionId: base,
+			Content:       "two",
+			Session:       "browser",
+		}))
+		return err
+	}
+
+	code := func(err error) connect.Code {
+		if err == nil {
+			return 0
+		}
+		return connect.CodeOf(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		read  connect.Code
+		write connect.Code
+	}{
+		{"no token", "", connect.CodeUnauthenticated, connect.CodeUnauthenticated},
+		{"unknown token", "not-a-token", connect.CodeUnauthenticated, connect.CodeUnauthenticated},
+		{"outside the vault", outsider, connect.CodePermissionDenied, connect.CodePermissionDenied},
+		{"reader", reader, 0, connect.CodePermissionDenied},
+		{"owner", owner, 0, 0},
+	} {
+		if err := list(tc.token); code(err) != tc.read {
+			t.Errorf("%s listing files: %v", tc.name, err)
+		}
+		if err := save(tc.token); code(err) != tc.write {
+			t.Errorf("%s saving an edit: %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/blobs"
+	"github.com/itsrobel/sync/internal/e2e"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fileLimit is the largest file a vault accepts in bytes, 0 when unlimited.
+func (s *FileTransferServer) fileLimit(vaultID string) int64 {
+	if limit, ok := s.vaultFileSize[vaultID]; ok {
+		return limit
+	}
+	return s.maxFileSize
+}
+
+// uploadLimit checks the size an upload announces and returns how many bytes
+// may arrive. Encrypted content is a little larger than the file it holds.
+func (s *FileTransferServer) uploadLimit(data *ft.FileVersionData) (int64, error) {
+	limit := s.fileLimit(data.VaultId)
+	if limit == 0 {
+		return 0, nil
+	}
+	if data.TotalSize > limit {
+		return 0, tooLarge(data.Location, limit)
+	}
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, data.VaultId); err == nil {
+		return e2e.SealedSize(limit), nil
+	}
+	return limit, nil
+}
+
+func tooLarge(location string, limit int64) error {
+	return connect.NewError(connect.CodeResourceExhausted, fmt.Errorf("%s is larger than the %d bytes this vault accepts", location, limit))
+}
+
+func versionExists(data *ft.FileVersionData) error {
+	return connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("version %s of %s is already stored", data.Id, data.Location))
+}
+
+// receiveBlob streams an attachment into the blob store, first is the chunk
+// that was already read. Outside of encrypted vaults the content has to match
+// the checksum it came with.
+func (s *FileTransferServer) receiveBlob(first *ft.FileVersionData, stream *connect.ClientStream[ft.FileVersionData], limit int64) error {
+	if s.blobs == nil {
+		return connect.NewError(connect.CodeFailedPrecondition, errors.New("this server does not store attachments"))
+	}
+	// the content is not needed past this point, keep the metadata only
+	var reader io.Reader = &uploadReader{stream: stream, buf: first.Content}
+	first.Content = nil
+	var checked *checkedReader
+	// the checksum of an encrypted vault is keyed, only clients can verify it
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, first.VaultId); err != nil {
+		checked = &checkedReader{r: reader, sum: sha256.New(), want: first.Hash}
+		reader = checked
+	}
+
+	n, err := s.blobs.Write(first.VaultId, first.Id, reader, limit)
+	if errors.Is(err, blobs.ErrTooLarge) {
+		return tooLarge(first.Location, limit)
+	} else if errors.Is(err, errChecksum) {
+		return connect.NewError(connect.CodeDataLoss, fmt.Errorf("%s: %w", first.Location, err))
+	} else if err != nil {
+		return fmt.Errorf("failed to store %s: %w", first.Location, err)
+	}
+	if checked != nil {
+		first.Hash = checked.got
+		first.TotalSize = n
+	}
+	return nil
+}
+
+var errChecksum = errors.New("content does not match its checksum")
+
+// checkedReader hashes what passes through and fails at the end, instead of
+// returning io.EOF, when the sum differs from want. An empty want takes any
+// content.
+type checkedReader struct {
+	r    io.Reader
+	sum  hash.Hash
+	want string
+	got  string
+}
+
+func (c *checkedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.sum.Write(p[:n])
+	if err == io.EOF {
+		c.got = hex.EncodeToString(c.sum.Sum(nil))
+		if c.want != "" && c.got != c.want {
+			return n, errChecksum
+		}
+	}
+	return n, err
+}
+
+// copyBlob stores the content of one version of an attachment again under
+// another version.
+func (s *FileTransferServer) copyBlob(vaultID, fromVersion, toVersion string) error {
+	if s.blobs == nil {
+		return connect.NewError(connect.CodeFailedPrecondition, errors.New("this server does not store attachments"))
+	}
+	content, err := s.blobs.Open(vaultID, fromVersion)
+	if err != nil {
+		return fmt.Errorf("failed to open version %s: %w", fromVersion, err)
+	}
+	defer content.Close()
+	_, err = s.blobs.Write(vaultID, toVersion, content, 0)
+	return err
+}
+
+// uploadReader turns the chunks of an upload into a reader.
+type uploadReader struct {
+	stream *connect.ClientStream[ft.FileVersionData]
+	buf    []byte
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	for len(u.buf) == 0 {
+		if !u.stream.Receive() {
+			if err := u.stream.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		u.buf = u.stream.Msg().Content
+	}
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+// sendBlob streams an attachment from the blob store in ChunkSize pieces,
+// the way DownloadFile sends notes.
+func (s *FileTransferServer) sendBlob(file *sql_manager.File, version *sql_manager.FileVersion, stream *connect.ServerStream[ft.FileVersionData]) error {
+	if s.blobs == nil {
+		return connect.NewError(connect.CodeFailedPrecondition, errors.New("this server does not store attachments"))
+	}
+	content, err := s.blobs.Open(file.VaultID, version.ID)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file.Location, err)
+	}
+	defer content.Close()
+
+	buffer := make([]byte, sql_manager.ChunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(content, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		// an empty file still sends its metadata once
+		if n > 0 || offset == 0 {
+			if err := stream.Send(&ft.FileVersionData{
+				Id:        version.ID,
+				Timestamp: timestamppb.New(version.Timestamp),
+				Content:   buffer[:n],
+				Location:  file.Location,
+				FileId:    file.ID,
+				VaultId:   file.VaultID,
+				Client:    version.Client,
+				Offset:    offset,
+				TotalSize: version.Size,
+				Blob:      true,
+				Hash:      version.Hash,
+			}); err != nil {
+				return err
+			}
+		}
+		offset += int64(n)
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// readBlob loads an attachment into memory for the few callers that need all
+// of it at once, like share links. A version without an id stands for the
+// head of the file.
+func (s *FileTransferServer) readBlob(version *sql_manager.FileVersion) (string, error) {
+	content, err := s.openBlob(version)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	return string(data), err
+}
+
+// openBlob is readBlob for callers that stream the content.
+func (s *FileTransferServer) openBlob(version *sql_manager.FileVersion) (io.ReadCloser, error) {
+	if s.blobs == nil {
+		return nil, errors.New("this server does not store attachments")
+	}
+	versionID := version.ID
+	if versionID == "" {
+		file, err := sql_manager.FindFileById(s.db, version.VaultID, version.FileID)
+		if err != nil {
+			return nil, err
+		}
+		head, err := sql_manager.GetHeadVersion(s.db, file)
+		if err != nil {
+			return nil, err
+		}
+		versionID = head.ID
+	}
+	return s.blobs.Open(version.VaultID, versionID)
+}
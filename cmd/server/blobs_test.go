@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func upload(url string, data *ft.FileVersionData) error {
+	rpc := filetransferconnect.NewFileServiceClient(http.DefaultClient, url)
+	stream := rpc.SendFileToServer(context.Background())
+	// a refused upload reports why when it is closed
+	stream.Send(data)
+	_, err := stream.CloseAndReceive()
+	return err
+}
+
+func TestUploadTwice(t *testing.T) {
+	_, url := testServer(t)
+	sum := sha256.Sum256([]byte("png"))
+	for _, data := range []*ft.FileVersionData{
+		{Location: "a.md", Content: []byte("note")},
+		{Location: "a.png", Content: []byte("png"), Blob: true, Hash: hex.EncodeToString(sum[:])},
+	} {
+		data.Id, data.FileId, data.VaultId = uuid.NewString(), uuid.NewString(), sql_manager.DefaultVault
+		data.TotalSize, data.Timestamp = int64(len(data.Content)), timestamppb.Now()
+		if err := upload(url, proto.Clone(data).(*ft.FileVersionData)); err != nil {
+			t.Fatalf("%s: %v", data.Location, err)
+		}
+		// a retry whose first attempt got through
+		if err := upload(url, proto.Clone(data).(*ft.FileVersionData)); connect.CodeOf(err) != connect.CodeAlreadyExists {
+			t.Errorf("%s uploaded again: %v, want CodeAlreadyExists", data.Location, err)
+		}
+	}
+}
+
+func TestRefusedAttachmentIsRemoved(t *testing.T) {
+	s, _ := testServer(t)
+	data := &ft.FileVersionData{
+		Id:        uuid.NewString(),
+		VaultId:   sql_manager.DefaultVault,
+		FileId:    uuid.NewString(),
+		Location:  "a.png",
+		Blob:      true,
+		TotalSize: 3,
+		Timestamp: timestamppb.Now(),
+	}
+	if _, err := s.blobs.Write(data.VaultId, data.Id, strings.NewReader("png"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.storeVersion(data, uuid.NewString()); connect.CodeOf(err) != connect.CodeAborted {
+		t.Fatalf("storing on a base that is gone: %v", err)
+	}
+	if content, err := s.blobs.Open(data.VaultId, data.Id); err == nil {
+		content.Close()
+		t.Error("the content of the refused version was kept")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+	"github.com/itsrobel/sync/internal/auth"
+	"github.com/itsrobel/sync/internal/drawing"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// webClientPrefix marks versions saved from the web app, the rest of the
+// client name is the browser session.
+const webClientPrefix = "web/"
+
+// SaveEdit stores a note or drawing edited in the web app as a new version.
+// The edit is refused with CodeAborted when another version was stored after
+// the one it started from, so edits made on devices in the meantime are not
+// lost, drawings are merged with that version instead. The web app cannot
+// encrypt, vaults with a key only take edits from devices.
+func (s *FileTransferServer) SaveEdit(
+	ctx context.Context,
+	req *connect.Request[ft.EditRequest],
+) (*connect.Response[ft.FileVersionData], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+	if req.Msg.BaseVersionId == "" || req.Msg.Session == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("base version and session are required"))
+	}
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, req.Msg.VaultId); err == nil {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("vault %s is encrypted, it can only be edited on a device", req.Msg.VaultId))
+	}
+	content := req.Msg.Content
+	base := req.Msg.BaseVersionId
+	file, err := s.editableFile(req.Msg.VaultId, req.Msg.FileId, base)
+	if connect.CodeOf(err) == connect.CodeAborted {
+		// the merge is based on the head it was made with
+		file, content, err = s.mergeDrawing(req.Msg, err)
+		if err == nil {
+			var head *sql_manager.FileVersion
+			if head, err = sql_manager.GetHeadVersion(s.db, file); err == nil {
+				base = head.ID
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file.Blob {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("%s is an attachment, only notes can be edited", file.Location))
+	}
+	if limit := s.fileLimit(file.VaultID); limit > 0 && int64(len(content)) > limit {
+		return nil, tooLarge(file.Location, limit)
+	}
+
+	version := &ft.FileVersionData{
+		Id:        uuid.NewString(),
+		Timestamp: timestamppb.Now(),
+		Content:   []byte(content),
+		Location:  file.Location,
+		FileId:    file.ID,
+		Client:    webClientPrefix + req.Msg.Session,
+		TotalSize: int64(len(content)),
+		VaultId:   file.VaultID,
+	}
+	if err := s.storeVersion(version, base); err != nil {
+		return nil, err
+	}
+	version.Content = nil
+	return connect.NewResponse(version), nil
+}
+
+// mergeDrawing merges an edit of a drawing into the version stored after the
+// one it started from, the elements changed on both sides are combined. Other
+// files and drawings that fail to merge keep the conflict.
+func (s *FileTransferServer) mergeDrawing(edit *ft.EditRequest, conflict error) (*sql_manager.File, string, error) {
+	file, err := sql_manager.FindFileById(s.db, edit.VaultId, edit.FileId)
+	if err != nil {
+		return nil, "", err
+	}
+	if !drawing.IsDrawing(file.Location) {
+		return nil, "", conflict
+	}
+	merged, err := drawing.Merge(file.Location, edit.Content, file.Content)
+	if err != nil {
+		log.Printf("Failed to merge an edit of %s: %v", file.Location, err)
+		return nil, "", conflict
+	}
+	return file, merged, nil
+}
+
+// editableFile finds the file a web edit or restore applies to and checks
+// that nothing was stored since base. storeVersion checks it again when the
+// edit is stored.
+func (s *FileTransferServer) editableFile(vaultID, fileID, base string) (*sql_manager.File, error) {
+	file, err := sql_manager.FindFileById(s.db, vaultID, fileID)
+	if err == gorm.ErrRecordNotFound || (err == nil && !file.Active) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("file %s not found", fileID))
+	} else if err != nil {
+		return nil, err
+	}
+	head, err := sql_manager.GetHeadVersion(s.db, file)
+	if err != nil {
+		return nil, err
+	}
+	if head.ID != base {
+		return nil, connect.NewError(connect.CodeAborted, fmt.Errorf("%s was changed by %s at %s since it was loaded",
+			file.Location, head.Client, head.Timestamp.Format(time.RFC3339)))
+	}
+	return file, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// seed stores a note in the default vault as a device would and returns the
+// file and version ids.
+func seed(t *testing.T, s *FileTransferServer, location, content string) (string, string) {
+	t.Helper()
+	data := &ft.FileVersionData{
+		Id:        uuid.NewString(),
+		VaultId:   sql_manager.DefaultVault,
+		FileId:    uuid.NewString(),
+		Location:  location,
+		Content:   []byte(content),
+		TotalSize: int64(len(content)),
+		Timestamp: timestamppb.Now(),
+		Client:    "laptop",
+	}
+	if err := s.storeVersion(data, ""); err != nil {
+		t.Fatal(err)
+	}
+	return data.FileId, data.Id
+}
+
+func edit(s *FileTransferServer, fileID, base, content string) (*ft.FileVersionData, error) {
+	res, err := s.SaveEdit(context.Background(), connect.NewRequest(&ft.EditRequest{
+		VaultId:       sql_manager.DefaultVault,
+		FileId:        fileID,
+		BaseVersionId: base,
+		Content:       content,
+		Session:       "browser",
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return res.Msg, nil
+}
+
+func TestSaveEditComparesHead(t *testing.T) {
+	s, _ := testServer(t)
+	fileID, first := seed(t, s, "a.md", "one")
+
+	second, err := edit(s, fileID, first, "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := edit(s, fileID, first, "stale"); connect.CodeOf(err) != connect.CodeAborted {
+		t.Errorf("edit of a replaced version: %v, want CodeAborted", err)
+	}
+
+	// files stored before heads were recorded compare their newest version
+	if err := s.db.Exec("UPDATE files SET head_version_id = ''").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := edit(s, fileID, second.Id, "three"); err != nil {
+		t.Errorf("edit of a file without a head: %v", err)
+	}
+	file, err := sql_manager.FindFileById(s.db, sql_manager.DefaultVault, fileID)
+	if err != nil || file.Content != "three" {
+		t.Errorf("file after the edits = %v, %v", file, err)
+	}
+}
+
+func TestSaveEditTooLarge(t *testing.T) {
+	s, _ := testServer(t)
+	fileID, first := seed(t, s, "notes/a.md", "one")
+	s.maxFileSize = 4
+
+	_, err := edit(s, fileID, first, "longer than four bytes")
+	if connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Fatalf("oversized edit: %v, want CodeResourceExhausted", err)
+	}
+	if !strings.Contains(err.Error(), "notes/a.md") {
+		t.Errorf("error %q does not name the file", err)
+	}
+}
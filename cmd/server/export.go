@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// ExportArchive streams a folder of a vault, or all of it, as a zip archive
+// with paths relative to the folder. With At set the files are the ones that
+// were in the folder then, as they were then, wherever they are now.
+func (s *FileTransferServer) ExportArchive(
+	ctx context.Context,
+	req *connect.Request[ft.ExportRequest],
+	stream *connect.ServerStream[ft.ArchiveChunk],
+) error {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return err
+	}
+	// the server only holds sealed content of encrypted vaults
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, req.Msg.VaultId); err == nil {
+		return connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("vault %s is end-to-end encrypted, export it from a device", req.Msg.VaultId))
+	}
+
+	folder := ""
+	if strings.Trim(req.Msg.Folder, "/ ") != "" {
+		clean, err := sql_manager.CleanSyncFolder(req.Msg.Folder)
+		if err != nil {
+			return connect.NewError(connect.CodeInvalidArgument, err)
+		}
+		folder = clean + "/"
+	}
+	inFolder := func(location string) bool { return strings.HasPrefix(location, folder) }
+
+	var files []sql_manager.FileVersion
+	var err error
+	if req.Msg.At != nil {
+		files, err = sql_manager.GetVersionsAt(s.db, req.Msg.VaultId, req.Msg.At.AsTime(), inFolder)
+	} else {
+		files, err = sql_manager.GetActiveFiles(s.db, req.Msg.VaultId, inFolder)
+	}
+	if err != nil {
+		return err
+	}
+	if folder != "" && len(files) == 0 {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("folder %s has no files", strings.TrimSuffix(folder, "/")))
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Location < files[j].Location })
+
+	out := bufio.NewWriterSize(&chunkWriter{stream: stream}, sql_manager.ChunkSize)
+	archive := zip.NewWriter(out)
+	for i := range files {
+		if err := s.addToArchive(archive, &files[i], strings.TrimPrefix(files[i].Location, folder)); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func (s *FileTransferServer) addToArchive(archive *zip.Writer, version *sql_manager.FileVersion, name string) error {
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: version.Timestamp,
+	})
+	if err != nil {
+		return err
+	}
+	if !version.Blob {
+		_, err := io.WriteString(entry, version.Content)
+		return err
+	}
+	content, err := s.openBlob(version)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", version.Location, err)
+	}
+	defer content.Close()
+	_, err = io.Copy(entry, content)
+	return err
+}
+
+// chunkWriter sends what is written to it as archive chunks.
+type chunkWriter struct {
+	stream *connect.ServerStream[ft.ArchiveChunk]
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if err := w.stream.Send(&ft.ArchiveChunk{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// ListVersions returns the history of a file, oldest first and the head
+// last, even when a client's clock dated it earlier. Content is left out,
+// DownloadFile sends it for a single version.
+func (s *FileTransferServer) ListVersions(
+	ctx context.Context,
+	req *connect.Request[ft.FileRequest],
+) (*connect.Response[ft.VersionList], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	file, err := sql_manager.FindFileById(s.db, req.Msg.VaultId, req.Msg.FileId)
+	if err == gorm.ErrRecordNotFound {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("file %s not found", req.Msg.FileId))
+	} else if err != nil {
+		return nil, err
+	}
+
+	versions, err := sql_manager.GetAllFileVersions(s.db, file.VaultID, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i, version := range versions {
+		if version.ID == file.HeadVersionID {
+			versions = append(append(versions[:i:i], versions[i+1:]...), version)
+			break
+		}
+	}
+	list := make([]*ft.FileVersionData, len(versions))
+	for i, version := range versions {
+		size := version.Size
+		if !version.Blob {
+			size = int64(len(version.Content))
+		}
+		list[i] = &ft.FileVersionData{
+			Id:        version.ID,
+			Timestamp: timestamppb.New(version.Timestamp),
+			Location:  version.Location,
+			FileId:    file.ID,
+			Client:    version.Client,
+			TotalSize: size,
+			VaultId:   file.VaultID,
+			Blob:      version.Blob,
+			Hash:      version.Hash,
+		}
+	}
+	return connect.NewResponse(&ft.VersionList{Versions: list}), nil
+}
+
+// findVersion looks up a version of file, versions of other files are not
+// found.
+func (s *FileTransferServer) findVersion(file *sql_manager.File, versionID string) (*sql_manager.FileVersion, error) {
+	version, err := sql_manager.GetVersionById(s.db, versionID)
+	if err == gorm.ErrRecordNotFound || (err == nil && (version.VaultID != file.VaultID || version.FileID != file.ID)) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("version %s of %s not found", versionID, file.Location))
+	}
+	return version, err
+}
+
+// RestoreVersion copies an older version into a new latest one, the history
+// before it is kept. The copy is made on the server, so encrypted vaults can
+// be restored as well. Like SaveEdit it is refused with CodeAborted when the
+// file changed after base.
+func (s *FileTransferServer) RestoreVersion(
+	ctx context.Context,
+	req *connect.Request[ft.RestoreRequest],
+) (*connect.Response[ft.FileVersionData], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+	if req.Msg.VersionId == "" || req.Msg.BaseVersionId == "" || req.Msg.Session == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("version, base version and session are required"))
+	}
+
+	file, err := s.editableFile(req.Msg.VaultId, req.Msg.FileId, req.Msg.BaseVersionId)
+	if err != nil {
+		return nil, err
+	}
+	old, err := s.findVersion(file, req.Msg.VersionId)
+	if err != nil {
+		return nil, err
+	}
+	if old.ID == req.Msg.BaseVersionId {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("version %s is already the latest", old.ID))
+	}
+
+	version := &ft.FileVersionData{
+		Id:        uuid.NewString(),
+		Timestamp: timestamppb.Now(),
+		Content:   []byte(old.Content),
+		Location:  file.Location,
+		FileId:    file.ID,
+		Client:    webClientPrefix + req.Msg.Session,
+		TotalSize: int64(len(old.Content)),
+		VaultId:   file.VaultID,
+		Blob:      old.Blob,
+		Hash:      old.Hash,
+	}
+	if old.Blob {
+		if err := s.copyBlob(file.VaultID, old.ID, version.Id); err != nil {
+			return nil, err
+		}
+		version.TotalSize = old.Size
+	}
+	if err := s.storeVersion(version, req.Msg.BaseVersionId); err != nil {
+		return nil, err
+	}
+	version.Content = nil
+	return connect.NewResponse(version), nil
+}
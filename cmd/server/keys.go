@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"gorm.io/gorm"
+)
+
+// GetKeyInfo returns the parameters clients need to derive the vault key.
+// NotFound means the vault is not encrypted yet.
+func (s *FileTransferServer) GetKeyInfo(
+	ctx context.Context,
+	req *connect.Request[ft.ActionRequest],
+) (*connect.Response[ft.KeyInfo], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return nil, err
+	}
+
+	key, err := sql_manager.GetCurrentVaultKey(s.db, req.Msg.VaultId)
+	if err == gorm.ErrRecordNotFound {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("vault has no encryption key"))
+	} else if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(key.Info()), nil
+}
+
+// SetKeyInfo publishes a new key version, either the first one or a rotation.
+func (s *FileTransferServer) SetKeyInfo(
+	ctx context.Context,
+	req *connect.Request[ft.KeyInfo],
+) (*connect.Response[ft.ActionResponse], error) {
+	if len(req.Msg.Salt) == 0 || len(req.Msg.Check) == 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("key info needs a salt and a check blob"))
+	}
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := sql_manager.CreateVaultKey(s.db, sql_manager.VaultKeyFromInfo(req.Msg)); err != nil {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+
+	return connect.NewResponse(&ft.ActionResponse{
+		Success: true,
+		Message: fmt.Sprintf("key version %d stored", req.Msg.Version),
+	}), nil
+}
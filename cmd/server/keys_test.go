@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itsrobel/sync/internal/e2e"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+)
+
+func TestNewKeySealsExistingFiles(t *testing.T) {
+	s, url := testServer(t)
+	laptop := client(t, url, "laptop", filepath.Join(t.TempDir(), "laptop"))
+	writeFile(t, filepath.Join(laptop.Folders[0].Path, "a.md"), "written before the passphrase")
+	runSync(t, laptop)
+
+	laptop.Folders[0].Passphrase = "correct horse"
+	if r := runSync(t, laptop); len(r.Uploaded) != 1 || r.Error != "" {
+		t.Fatalf("adding a passphrase: %+v", r)
+	}
+	files, err := sql_manager.GetAllFiles(s.db, sql_manager.DefaultVault)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("server files %v, %v", files, err)
+	}
+	if !e2e.IsEncrypted(files[0].Content) {
+		t.Fatalf("the server still holds %q", files[0].Content)
+	}
+
+	phone := client(t, url, "phone", filepath.Join(t.TempDir(), "phone"))
+	phone.Folders[0].Passphrase = "correct horse"
+	if r := runSync(t, phone); len(r.Downloaded) != 1 || r.Error != "" {
+		t.Fatalf("phone: %+v", r)
+	}
+	if data, _ := os.ReadFile(filepath.Join(phone.Folders[0].Path, "a.md")); string(data) != "written before the passphrase" {
+		t.Errorf("phone has %q", data)
+	}
+}
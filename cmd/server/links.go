@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"gorm.io/gorm"
+)
+
+func (s *FileTransferServer) GetBacklinks(
+	ctx context.Context,
+	req *connect.Request[ft.LinkRequest],
+) (*connect.Response[ft.LinkList], error) {
+	file, err := s.linkedFile(req)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := sql_manager.GetBacklinks(s.db, file.VaultID, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(linkListMessage(infos)), nil
+}
+
+func (s *FileTransferServer) GetOutgoingLinks(
+	ctx context.Context,
+	req *connect.Request[ft.LinkRequest],
+) (*connect.Response[ft.LinkList], error) {
+	file, err := s.linkedFile(req)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := sql_manager.GetOutgoingLinks(s.db, file.VaultID, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(linkListMessage(infos)), nil
+}
+
+// GetUnresolvedLinks reports links in a vault that point at nothing.
+func (s *FileTransferServer) GetUnresolvedLinks(
+	ctx context.Context,
+	req *connect.Request[ft.ActionRequest],
+) (*connect.Response[ft.LinkList], error) {
+	if err := s.authorizeLinks(req.Header(), req.Msg.VaultId); err != nil {
+		return nil, err
+	}
+	infos, err := sql_manager.GetUnresolvedLinks(s.db, req.Msg.VaultId)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(linkListMessage(infos)), nil
+}
+
+// GetGraph returns the notes of a vault and the links between them, narrowed
+// to a folder and a tag when the request names them.
+func (s *FileTransferServer) GetGraph(
+	ctx context.Context,
+	req *connect.Request[ft.GraphRequest],
+) (*connect.Response[ft.Graph], error) {
+	if err := s.authorizeLinks(req.Header(), req.Msg.VaultId); err != nil {
+		return nil, err
+	}
+	nodes, edges, err := sql_manager.GetGraph(s.db, req.Msg.VaultId, req.Msg.Folder, req.Msg.Tag)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := sql_manager.GetVaultTags(s.db, req.Msg.VaultId)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &ft.Graph{
+		Nodes: make([]*ft.GraphNode, len(nodes)),
+		Edges: make([]*ft.GraphEdge, len(edges)),
+		Tags:  tags,
+	}
+	for i, node := range nodes {
+		graph.Nodes[i] = &ft.GraphNode{
+			FileId:    node.FileID,
+			Location:  node.Location,
+			Tags:      node.Tags,
+			Links:     int32(node.Links),
+			Backlinks: int32(node.Backlinks),
+		}
+	}
+	for i, edge := range edges {
+		graph.Edges[i] = &ft.GraphEdge{SourceId: edge.SourceID, TargetId: edge.TargetID, Count: int32(edge.Count)}
+	}
+	return connect.NewResponse(graph), nil
+}
+
+// linkedFile checks access and finds the file a link request is about.
+func (s *FileTransferServer) linkedFile(req *connect.Request[ft.LinkRequest]) (*sql_manager.File, error) {
+	if err := s.authorizeLinks(req.Header(), req.Msg.VaultId); err != nil {
+		return nil, err
+	}
+
+	var file *sql_manager.File
+	var err error
+	switch {
+	case req.Msg.FileId != "":
+		file, err = sql_manager.FindFileById(s.db, req.Msg.VaultId, req.Msg.FileId)
+	case req.Msg.Location != "":
+		file, err = sql_manager.FindFileByLocation(s.db, req.Msg.VaultId, req.Msg.Location)
+	default:
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("file_id or location is required"))
+	}
+	if err == gorm.ErrRecordNotFound || (err == nil && !file.Active) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("file not found"))
+	}
+	return file, err
+}
+
+// authorizeLinks refuses encrypted vaults, their links are never indexed on
+// the server.
+func (s *FileTransferServer) authorizeLinks(header http.Header, vaultID string) error {
+	if _, _, err := s.authorize(header, vaultID, auth.RoleReader); err != nil {
+		return err
+	}
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, vaultID); err == nil {
+		return connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("vault %s is end-to-end encrypted, links are only indexed on clients", vaultID))
+	}
+	return nil
+}
+
+func linkListMessage(infos []sql_manager.LinkInfo) *ft.LinkList {
+	list := &ft.LinkList{Links: make([]*ft.NoteLink, len(infos))}
+	for i, info := range infos {
+		list.Links[i] = &ft.NoteLink{
+			SourceId:       info.SourceID,
+			SourceLocation: info.SourceLocation,
+			TargetId:       info.TargetID,
+			TargetLocation: info.TargetLocation,
+			TargetName:     info.TargetName,
+			Heading:        info.Heading,
+			Alias:          info.Alias,
+			Embed:          info.Embed,
+			Line:           int32(info.Line),
+		}
+		if info.TargetLocation == "" {
+			list.Links[i].TargetId = ""
+		}
+	}
+	return list
+}
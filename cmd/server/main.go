@@ -2,18 +2,26 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
 	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	"github.com/itsrobel/sync/internal/blobs"
+	"github.com/itsrobel/sync/internal/config"
 	ft "github.com/itsrobel/sync/internal/services/filetransfer"
 	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
 	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/transport"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"gorm.io/gorm"
 )
 
@@ -22,115 +30,337 @@ type FileTransferServer struct {
 	sessions map[string]*SessionState
 	mu       sync.RWMutex
 	db       *gorm.DB
+	// rewriteLinks updates [[links]] in other notes when a note is renamed
+	rewriteLinks bool
+	// blobs holds attachment content, uploads of attachments fail without it
+	blobs *blobs.Store
+	// maxFileSize applies to vaults without their own entry in vaultFileSize,
+	// 0 means unlimited
+	maxFileSize   int64
+	vaultFileSize map[string]int64
+	// watchers are the open WatchChanges calls, guarded by mu
+	watchers map[*vaultWatch]struct{}
 }
 
 type SessionState struct {
 	controlStream *connect.BidiStream[ft.ControlMessage, ft.ControlMessage]
+	user          *sql_manager.User // nil in single-user mode
 	isPaused      bool
+	vaults        map[string]bool // vaults the session sent READY for
+	rules         map[string]sql_manager.SyncRules
+	// catchUps holds when the last catch-up of each vault started, the
+	// session's cursor moves there once the client answers RECEIVED
+	catchUps map[string]time.Time
+	sendMu   sync.Mutex
+}
+
+func (ss *SessionState) send(msg *ft.ControlMessage) error {
+	ss.sendMu.Lock()
+	defer ss.sendMu.Unlock()
+	return ss.controlStream.Send(msg)
 }
 
 func NewFileTransferServer(db *gorm.DB) *FileTransferServer {
 	return &FileTransferServer{
 		sessions: make(map[string]*SessionState),
+		watchers: make(map[*vaultWatch]struct{}),
 		db:       db,
 	}
 }
 
 func (s *FileTransferServer) SendFileToServer(ctx context.Context, stream *connect.ClientStream[ft.FileVersionData]) (*connect.Response[ft.ActionResponse], error) {
-	success := true
-	message := "OK"
-	var fileData *ft.FileVersionData
-
-	log.Println("Request headers:", stream.RequestHeader())
-	for stream.Receive() {
-		fileData = stream.Msg()
-		log.Println("Processing chunk for file:", fileData.Id, fileData.Location)
-	}
-
-	if err := stream.Err(); err != nil {
-		log.Println("Stream error:", err)
-		success = false
-	}
-
-	if fileData == nil {
+	// every chunk repeats the metadata, the first one is enough to check
+	// access and size before anything is stored
+	if !stream.Receive() {
+		if err := stream.Err(); err != nil {
+			return nil, err
+		}
 		return connect.NewResponse(&ft.ActionResponse{
 			Success: false,
 			Message: "No data received",
 		}), fmt.Errorf("no data received")
 	}
+	fileData := stream.Msg()
+	log.Println("Processing upload for file:", fileData.Id, fileData.Location)
 
-	res := connect.NewResponse(&ft.ActionResponse{Success: success, Message: message})
+	if _, _, err := s.authorize(stream.RequestHeader(), fileData.VaultId, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+	limit, err := s.uploadLimit(fileData)
+	if err != nil {
+		return nil, err
+	}
+	// stored versions are never written again, a retry learns its earlier
+	// attempt got through
+	if exists, err := sql_manager.VersionExists(s.db, fileData.Id); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, versionExists(fileData)
+	}
+
+	if fileData.Blob {
+		if err := s.receiveBlob(fileData, stream, limit); err != nil {
+			return nil, err
+		}
+	} else {
+		content := fileData.Content
+		for stream.Receive() {
+			content = append(content, stream.Msg().Content...)
+			if limit > 0 && int64(len(content)) > limit {
+				return nil, tooLarge(fileData.Location, limit)
+			}
+		}
+		if err := stream.Err(); err != nil {
+			return nil, err
+		}
+		fileData.Content = content
+		fileData.TotalSize = int64(len(content))
+	}
+
+	res := connect.NewResponse(&ft.ActionResponse{Success: true, Message: "OK"})
 	res.Header().Set("Transfer-Version", "v1")
 
-	if err := sql_manager.CreateFileVersionServer(s.db, fileData); err != nil {
+	if err := s.storeVersion(fileData, ""); err != nil {
 		return connect.NewResponse(&ft.ActionResponse{
 			Success: false,
 			Message: err.Error(),
 		}), err
 	}
+	return res, nil
+}
 
-	if err := sql_manager.UpdateFileServer(s.db, &sql_manager.File{
-		FileBase: sql_manager.FileBase{ID: fileData.FileId},
-		Location: fileData.Location,
-		Content:  string(fileData.Content),
-		Active:   true,
-	}); err != nil {
-		return connect.NewResponse(&ft.ActionResponse{
-			Success: false,
-			Message: err.Error(),
-		}), err
+// storeVersion records a complete version as the file's head, updates what
+// is derived from it and notifies the other sessions. With base set the head
+// has to still be base, web edits are refused with CodeAborted otherwise. A
+// version that is already stored is refused with CodeAlreadyExists. The
+// content of an attachment is in the blob store already and removed again
+// when the version is not stored.
+func (s *FileTransferServer) storeVersion(fileData *ft.FileVersionData, base string) error {
+	// the file keeps its ID when a client moves it
+	previous, err := sql_manager.FindFileById(s.db, fileData.VaultId, fileData.FileId)
+	renamed := err == nil && previous.Active && previous.Location != fileData.Location
+
+	stored, err := sql_manager.StoreVersionServer(s.db, fileData, base)
+	if err != nil && fileData.Blob {
+		// the content was stored first, without a version pointing at it
+		// it would never be read
+		if err := s.blobs.Remove(fileData.VaultId, fileData.Id); err != nil {
+			log.Printf("Failed to remove the content of %s: %v", fileData.Location, err)
+		}
+	}
+	if errors.Is(err, sql_manager.ErrHeadMoved) {
+		return connect.NewError(connect.CodeAborted, fmt.Errorf("%s was changed since it was loaded", fileData.Location))
+	} else if err != nil {
+		return err
+	} else if !stored {
+		return versionExists(fileData)
 	}
 
-	return res, nil
+	if renamed {
+		if err := sql_manager.CreateTombstone(s.db, fileData.VaultId, fileData.FileId, previous.Location, fileData.Location); err != nil {
+			log.Printf("Failed to record rename of %s: %v", previous.Location, err)
+		}
+	}
+
+	// the link graph and metadata are derived data, a failure must not fail the upload
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, fileData.VaultId); err != nil {
+		if err := sql_manager.IndexLinks(s.db, fileData.VaultId, fileData.FileId); err != nil {
+			log.Printf("Failed to index links of %s: %v", fileData.Location, err)
+		}
+		if err := sql_manager.IndexMeta(s.db, fileData.VaultId, fileData.FileId); err != nil {
+			log.Printf("Failed to index metadata of %s: %v", fileData.Location, err)
+		}
+		if renamed && s.rewriteLinks {
+			s.rewriteLinksTo(fileData.VaultId, fileData.FileId, previous.Location)
+		}
+	}
+
+	s.broadcast(fileData.Client, &ft.ControlMessage{
+		Type:     ft.ControlMessage_NEW_FILE,
+		Filename: fileData.Location,
+		FileId:   fileData.FileId,
+		VaultId:  fileData.VaultId,
+	})
+	return nil
 }
 
+// DownloadFile streams the latest content of a file, or the version asked
+// for, in ChunkSize pieces. The content is sent as stored, so encrypted
+// vaults stay encrypted.
+func (s *FileTransferServer) DownloadFile(
+	ctx context.Context,
+	req *connect.Request[ft.FileRequest],
+	stream *connect.ServerStream[ft.FileVersionData],
+) error {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return err
+	}
+
+	file, err := sql_manager.FindFileById(s.db, req.Msg.VaultId, req.Msg.FileId)
+	if err == gorm.ErrRecordNotFound {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("file %s not found", req.Msg.FileId))
+	} else if err != nil {
+		return err
+	}
+
+	content := file.Content
+	version, err := sql_manager.GetHeadVersion(s.db, file)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if req.Msg.VersionId != "" {
+		if version, err = s.findVersion(file, req.Msg.VersionId); err != nil {
+			return err
+		}
+		content = version.Content
+	}
+
+	if file.Blob || version.Blob {
+		return s.sendBlob(file, version, stream)
+	}
+
+	buffer := []byte(content)
+	total := int64(len(buffer))
+	offset := 0
+	for {
+		end := offset + sql_manager.ChunkSize
+		if end > len(buffer) {
+			end = len(buffer)
+		}
+		if err := stream.Send(&ft.FileVersionData{
+			Id:        version.ID,
+			Timestamp: timestamppb.New(version.Timestamp),
+			Content:   buffer[offset:end],
+			Location:  file.Location,
+			FileId:    file.ID,
+			VaultId:   file.VaultID,
+			Client:    version.Client,
+			Offset:    int64(offset),
+			TotalSize: total,
+		}); err != nil {
+			return err
+		}
+		offset = end
+		if offset >= len(buffer) {
+			return nil
+		}
+	}
+}
+
+var (
+	addUser = flag.String("add-user", "", "create a user, print its first device token and exit")
+	grant   = flag.String("grant", "", "grant a role as user:vault:role (owner, editor or reader) and exit")
+)
+
 func main() {
-	db, err := sql_manager.ConnectPostgres()
+	cfg, err := config.Load(config.Path(os.Args[1:]))
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	server := &cfg.Server
+	// already read by config.Path, registered so flag.Parse accepts it
+	flag.String("config", "", "path to a yaml or toml config file (or set SYNC_CONFIG)")
+	flag.StringVar(&server.Addr, "addr", server.Addr, "address the server listens on")
+	flag.StringVar(&server.DatabaseURL, "database-url", server.DatabaseURL, "postgres connection string")
+	flag.StringVar(&server.TLS.Cert, "tls-cert", server.TLS.Cert, "PEM certificate used to serve TLS")
+	flag.StringVar(&server.TLS.Key, "tls-key", server.TLS.Key, "PEM private key used to serve TLS")
+	flag.BoolVar(&server.TLS.SelfSigned, "tls-self-signed", server.TLS.SelfSigned, "generate a self-signed certificate when none exists")
+	flag.Var((*config.StringList)(&server.TLS.Hosts), "tls-hosts", "comma separated hosts written into a self-signed certificate")
+	flag.BoolVar(&server.RewriteLinks, "rewrite-links", server.RewriteLinks, "rewrite links in other notes when a note is renamed")
+	flag.StringVar(&server.ContentDir, "content-dir", server.ContentDir, "directory attachments are stored in")
+	flag.StringVar(&server.MaxFileSize, "max-file-size", server.MaxFileSize, "largest file a vault accepts, like 100MB, empty for no limit")
+	flag.Parse()
+
+	if err := server.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	db, err := sql_manager.ConnectPostgres(server.DatabaseURL)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	if runAdminCommands(db) {
+		return
+	}
+	if count, err := sql_manager.CountUsers(db); err == nil && count == 0 {
+		log.Println("No users configured, running in single-user mode without authentication")
+	}
+
 	// sql_manager.DeleteAllFiles(db)
 	// sql_manager.DeleteAllFileVersions(db)
 	filetransfer := NewFileTransferServer(db)
+	filetransfer.rewriteLinks = server.RewriteLinks
+	filetransfer.maxFileSize, filetransfer.vaultFileSize = server.FileLimits()
+	if filetransfer.blobs, err = blobs.NewStore(server.ContentDir); err != nil {
+		log.Fatal(err)
+	}
 
 	mux := http.NewServeMux()
 	path, handler := filetransferconnect.NewFileServiceHandler(filetransfer)
 	mux.Handle(path, handler)
 
-	server := &http.Server{
-		Addr: "localhost:50051",
-		Handler: h2c.NewHandler(mux, &http2.Server{
-			MaxConcurrentStreams: 250,
-			MaxReadFrameSize:     16384,
-			IdleTimeout:          10 * time.Second,
-		}),
+	h2Server := &http2.Server{
+		MaxConcurrentStreams: 250,
+		MaxReadFrameSize:     16384,
+		IdleTimeout:          10 * time.Second,
+	}
+
+	tlsOpts := server.TLSOptions()
+	if !tlsOpts.Enabled() {
+		httpServer := &http.Server{
+			Addr:    server.Addr,
+			Handler: h2c.NewHandler(mux, h2Server),
+		}
+
+		log.Printf("Server started on %s", server.Addr)
+		if err := httpServer.ListenAndServe(); err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+		return
 	}
 
-	log.Println("Server started on port 50051")
-	if err := server.ListenAndServe(); err != nil {
+	tlsConfig, err := transport.ServerConfig(tlsOpts)
+	if err != nil {
+		log.Fatalf("Failed to configure tls: %v", err)
+	}
+
+	httpServer := &http.Server{
+		Addr:      server.Addr,
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+	if err := http2.ConfigureServer(httpServer, h2Server); err != nil {
+		log.Fatalf("Failed to configure http2: %v", err)
+	}
+
+	log.Printf("Server started on %s (tls)", server.Addr)
+	if err := httpServer.ListenAndServeTLS("", ""); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
 
 // NOTE: if it is the clients first connection there is no sessionID to search for
-func (s *FileTransferServer) updateClientTimestamp(sessionID string) error {
+func (s *FileTransferServer) updateClientTimestamp(sessionID, vaultID string, at time.Time) error {
 	return s.db.Model(&sql_manager.ClientSession{}).
-		Where("session_id = ?", sessionID).
+		Where("session_id = ? AND vault_id = ?", sessionID, vaultID).
 		Updates(map[string]interface{}{
-			"last_sync_time": time.Now(),
+			"last_sync_time": at,
 			"is_active":      true,
 		}).Error
 }
 
-func (s *FileTransferServer) getLastSyncTime(sessionID string) (time.Time, error) {
+func (s *FileTransferServer) getLastSyncTime(sessionID, vaultID string, user *sql_manager.User) (time.Time, error) {
 	var session sql_manager.ClientSession
-	err := s.db.Where("session_id = ?", sessionID).First(&session).Error
+	err := s.db.Where("session_id = ? AND vault_id = ?", sessionID, vaultID).First(&session).Error
 	if err == gorm.ErrRecordNotFound {
+		// the cursor starts at the beginning until the first catch-up is
+		// confirmed
 		session = sql_manager.ClientSession{
-			SessionID:    sessionID,
-			LastSyncTime: time.Now(),
-			IsActive:     true,
+			SessionID: sessionID,
+			VaultID:   vaultID,
+			UserID:    userID(user),
+			IsActive:  true,
 		}
 		if err := s.db.Create(&session).Error; err != nil {
 			return time.Time{}, fmt.Errorf("failed to create session: %v", err)
@@ -146,7 +376,7 @@ func (s *FileTransferServer) Greet(
 ) (*connect.Response[ft.GreetResponse], error) {
 	fmt.Println("response message: ", req.Msg.Name)
 
-	docs, err := sql_manager.GetAllFiles(s.db)
+	docs, err := sql_manager.GetAllFiles(s.db, sql_manager.DefaultVault)
 	if err != nil {
 		return nil, err
 	}
@@ -166,19 +396,18 @@ func (s *FileTransferServer) RetrieveListOfFiles(
 	ctx context.Context,
 	req *connect.Request[ft.ActionRequest],
 ) (*connect.Response[ft.FileList], error) {
-	docs, err := sql_manager.GetAllFiles(s.db)
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return nil, err
+	}
+
+	docs, err := sql_manager.GetAllFiles(s.db, req.Msg.VaultId)
 	if err != nil {
 		return nil, err
 	}
 
 	files := make([]*ft.File, len(docs))
-	for idx, doc := range docs {
-		files[idx] = &ft.File{
-			ID:       doc.ID,
-			Active:   doc.Active,
-			Location: doc.Location,
-			Content:  doc.Content,
-		}
+	for idx := range docs {
+		files[idx] = fileMessage(&docs[idx])
 	}
 	log.Println(files)
 
@@ -187,54 +416,224 @@ func (s *FileTransferServer) RetrieveListOfFiles(
 	}), nil
 }
 
+// FindFile returns one active file by its id or location, with the content
+// of notes, so callers need not list the whole vault.
+func (s *FileTransferServer) FindFile(
+	ctx context.Context,
+	req *connect.Request[ft.FileLookup],
+) (*connect.Response[ft.File], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return nil, err
+	}
+
+	var file *sql_manager.File
+	var err error
+	switch {
+	case req.Msg.FileId != "":
+		file, err = sql_manager.FindFileById(s.db, req.Msg.VaultId, req.Msg.FileId)
+	case req.Msg.Location != "":
+		file, err = sql_manager.FindActiveFileByLocation(s.db, req.Msg.VaultId, req.Msg.Location)
+	default:
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("file_id or location is required"))
+	}
+	if err == gorm.ErrRecordNotFound || (err == nil && !file.Active) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("file not found"))
+	} else if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(fileMessage(file)), nil
+}
+
+func fileMessage(doc *sql_manager.File) *ft.File {
+	return &ft.File{
+		ID:         doc.ID,
+		Active:     doc.Active,
+		Location:   doc.Location,
+		Content:    doc.Content,
+		VaultId:    doc.VaultID,
+		Blob:       doc.Blob,
+		Size:       doc.Size,
+		Hash:       doc.Hash,
+		ModifiedAt: timestamppb.New(doc.Timestamp),
+	}
+}
+
 func (s *FileTransferServer) ControlStream(
 	ctx context.Context,
 	stream *connect.BidiStream[ft.ControlMessage, ft.ControlMessage],
 ) error {
+	user, err := s.authenticate(stream.RequestHeader())
+	if err != nil {
+		return err
+	}
+
 	msg, err := stream.Receive()
 	if err != nil {
 		return err
 	}
 
 	sessionID := msg.SessionId
+	if err := s.claimSession(sessionID, user); err != nil {
+		return err
+	}
+	session := &SessionState{
+		controlStream: stream,
+		user:          user,
+		vaults:        make(map[string]bool),
+		rules:         make(map[string]sql_manager.SyncRules),
+		catchUps:      make(map[string]time.Time),
+	}
 
-	if err := stream.Send(&ft.ControlMessage{
+	// a reconnect of the same user replaces its old stream
+	s.mu.Lock()
+	if old := s.sessions[sessionID]; old != nil && userID(old.user) != userID(user) {
+		s.mu.Unlock()
+		return connect.NewError(connect.CodePermissionDenied, sql_manager.ErrSessionTaken)
+	}
+	s.sessions[sessionID] = session
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if s.sessions[sessionID] == session {
+			delete(s.sessions, sessionID)
+		}
+		s.mu.Unlock()
+	}()
+
+	for {
+		switch msg.Type {
+		case ft.ControlMessage_READY:
+			if err := s.subscribe(session, sessionID, msg.VaultId); err != nil {
+				return err
+			}
+		case ft.ControlMessage_RECEIVED:
+			if err := s.confirmCatchUp(session, sessionID, msg.VaultId); err != nil {
+				return err
+			}
+		case ft.ControlMessage_PAUSE, ft.ControlMessage_RESUME:
+			s.mu.Lock()
+			session.isPaused = msg.Type == ft.ControlMessage_PAUSE
+			s.mu.Unlock()
+		}
+
+		msg, err = stream.Receive()
+		log.Println(msg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// subscribe answers a READY for one vault and sends the session every file
+// that changed in it since the session last synced that vault, followed by
+// SYNCED. Files outside the session's sync rules are left out. The cursor is
+// only moved by confirmCatchUp, so files a client failed to download are
+// offered again on its next catch-up.
+func (s *FileTransferServer) subscribe(session *SessionState, sessionID, vaultID string) error {
+	if vaultID == "" {
+		vaultID = sql_manager.DefaultVault
+	}
+	if _, err := s.authorizeUser(session.user, vaultID, auth.RoleReader); err != nil {
+		return err
+	}
+
+	if err := session.send(&ft.ControlMessage{
 		SessionId: sessionID,
 		Type:      ft.ControlMessage_READY,
+		VaultId:   vaultID,
 	}); err != nil {
 		return err
 	}
 
-	lastSync, _ := s.getLastSyncTime(sessionID)
+	rules, err := sql_manager.GetSessionRules(s.db, sessionID, vaultID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	session.vaults[vaultID] = true
+	session.rules[vaultID] = rules
+	s.mu.Unlock()
+
+	lastSync, _ := s.getLastSyncTime(sessionID, vaultID, session.user)
 	// if err != nil {
 	// 	return err
 	// }
-	log.Printf("Last sync time: %s, client: %s", lastSync, sessionID)
+	log.Printf("Last sync time: %s, client: %s, vault: %s", lastSync, sessionID, vaultID)
 
+	// changes stored while the files are sent are broadcast, and offered
+	// again by the next catch-up
+	started := time.Now()
 	var files []sql_manager.File
-	if err := s.db.Where("timestamp > ? AND active = ?", lastSync, true).Find(&files).Error; err != nil {
+	if err := s.db.Where("vault_id = ? AND timestamp > ? AND active = ?", vaultID, lastSync, true).Find(&files).Error; err != nil {
 		return err
 	}
 
 	for _, file := range files {
-		if err := stream.Send(&ft.ControlMessage{
+		if !rules.Syncs(file.Location) {
+			continue
+		}
+		if err := session.send(&ft.ControlMessage{
 			SessionId: sessionID,
 			Type:      ft.ControlMessage_NEW_FILE,
 			Filename:  file.Location,
+			FileId:    file.ID,
+			VaultId:   vaultID,
 		}); err != nil {
 			return err
 		}
 	}
 
-	if err := s.updateClientTimestamp(sessionID); err != nil {
-		return err
+	s.mu.Lock()
+	session.catchUps[vaultID] = started
+	s.mu.Unlock()
+	return session.send(&ft.ControlMessage{
+		SessionId: sessionID,
+		Type:      ft.ControlMessage_SYNCED,
+		VaultId:   vaultID,
+	})
+}
+
+// confirmCatchUp moves the session's cursor of a vault to the start of the
+// catch-up the client just finished downloading.
+func (s *FileTransferServer) confirmCatchUp(session *SessionState, sessionID, vaultID string) error {
+	if vaultID == "" {
+		vaultID = sql_manager.DefaultVault
+	}
+	s.mu.Lock()
+	started, ok := session.catchUps[vaultID]
+	delete(session.catchUps, vaultID)
+	s.mu.Unlock()
+	if !ok {
+		return nil
 	}
+	return s.updateClientTimestamp(sessionID, vaultID, started)
+}
 
-	for {
-		msg, err := stream.Receive()
-		log.Println(msg)
-		if err != nil {
-			return err
+// broadcast notifies every session subscribed to the message's vault except
+// the one the change came from and those whose rules leave the file out.
+// Sessions that fail to receive are picked up by catch-up on reconnect.
+// WatchChanges callers get every change to the vault.
+func (s *FileTransferServer) broadcast(from string, msg *ft.ControlMessage) {
+	// the sends happen outside the lock, a slow session must not hold up
+	// uploads and the other sessions
+	s.mu.RLock()
+	recipients := make(map[string]*SessionState)
+	for sessionID, session := range s.sessions {
+		if sessionID == from || session.isPaused || !session.vaults[msg.VaultId] {
+			continue
+		}
+		if !session.rules[msg.VaultId].Syncs(msg.Filename) {
+			continue
+		}
+		recipients[sessionID] = session
+	}
+	s.notifyWatchers(from, msg)
+	s.mu.RUnlock()
+
+	for sessionID, session := range recipients {
+		msg.SessionId = sessionID
+		if err := session.send(msg); err != nil {
+			log.Printf("Failed to notify session %s: %v", sessionID, err)
 		}
 	}
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// QueryNotes lists the notes of a vault by tags, front matter and
+// modification time, so dashboards get an overview without downloading the
+// vault. Encrypted vaults are refused like in Search.
+func (s *FileTransferServer) QueryNotes(
+	ctx context.Context,
+	req *connect.Request[ft.NoteQuery],
+) (*connect.Response[ft.NoteList], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	if req.Msg.Query == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("query is required"))
+	}
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, req.Msg.VaultId); err == nil {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("vault %s is end-to-end encrypted and can only be queried on a client", req.Msg.VaultId))
+	}
+	query, err := sql_manager.ParseQuery(req.Msg.Query)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	limit := int(req.Msg.Limit)
+	if limit > sql_manager.MaxQueryLimit {
+		limit = sql_manager.MaxQueryLimit
+	}
+	notes, err := sql_manager.QueryNotes(s.db, req.Msg.VaultId, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ft.NoteList{Notes: make([]*ft.Note, len(notes))}
+	for i, note := range notes {
+		res.Notes[i] = noteMessage(note)
+	}
+	return connect.NewResponse(res), nil
+}
+
+func noteMessage(note sql_manager.NoteInfo) *ft.Note {
+	msg := &ft.Note{
+		FileId:    note.FileID,
+		Location:  note.Location,
+		Timestamp: timestamppb.New(note.Timestamp),
+		Tags:      note.Tags,
+	}
+	for _, field := range note.Fields {
+		msg.Fields = append(msg.Fields, &ft.NoteField{Key: field.Key, Value: field.Value})
+	}
+	return msg
+}
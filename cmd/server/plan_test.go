@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/watcher"
+)
+
+func TestPlan(t *testing.T) {
+	s, url := testServer(t)
+	laptop := client(t, url, "laptop", filepath.Join(t.TempDir(), "laptop"))
+	dir := laptop.Folders[0].Path
+	writeFile(t, filepath.Join(dir, "a.md"), "moved")
+	writeFile(t, filepath.Join(dir, "b.md"), "deleted")
+	runSync(t, laptop)
+	moved, err := sql_manager.FindFileByLocation(s.db, sql_manager.DefaultVault, "a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "a.md"), filepath.Join(dir, "notes/a.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "c.md"), "new")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	plan, err := watcher.Plan(ctx, laptop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []watcher.PlanItem{
+		{Action: watcher.PlanDelete, Target: "server", Location: "b.md"},
+		{Action: watcher.PlanUpload, Target: "server", Location: "c.md"},
+		{Action: watcher.PlanRename, Target: "server", Location: "notes/a.md", From: "a.md"},
+	}
+	items := plan.Vaults[0].Items
+	if len(items) != len(want) {
+		t.Fatalf("plan %+v, want %+v", items, want)
+	}
+	for i, item := range items {
+		if item.Action != want[i].Action || item.Target != want[i].Target || item.Location != want[i].Location || item.From != want[i].From {
+			t.Errorf("item %d = %+v, want %+v", i, item, want[i])
+		}
+	}
+
+	// sync does what was planned
+	runSync(t, laptop)
+	file, err := sql_manager.FindFileById(s.db, sql_manager.DefaultVault, moved.ID)
+	if err != nil || file.Location != "notes/a.md" {
+		t.Errorf("moved file on the server = %v, %v", file, err)
+	}
+	if _, err := sql_manager.FindFileByLocation(s.db, sql_manager.DefaultVault, "b.md"); err != nil {
+		t.Errorf("the server lost the deleted file: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// systemClient is the client name on versions the server writes itself.
+const systemClient = "system"
+
+// rewriteLinksTo fixes links to a renamed file in the notes of its vault and
+// announces the rewritten notes to every session. Clients with a pending edit
+// of such a note keep their edit as a conflict copy like for any other
+// remote change.
+func (s *FileTransferServer) rewriteLinksTo(vaultID, fileID, from string) {
+	changed, err := sql_manager.RewriteLinks(s.db, vaultID, fileID, from, systemClient)
+	if err != nil {
+		log.Printf("Failed to rewrite links to %s: %v", fileID, err)
+		return
+	}
+
+	for _, file := range changed {
+		log.Printf("Rewrote links in %s", file.Location)
+		s.broadcast(systemClient, &ft.ControlMessage{
+			Type:     ft.ControlMessage_NEW_FILE,
+			Filename: file.Location,
+			FileId:   file.ID,
+			VaultId:  vaultID,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxSearchLimit caps how many hits one request can ask for
+const maxSearchLimit = 100
+
+// Search runs a full-text query over a vault. Encrypted vaults are refused,
+// the server only holds ciphertext, clients search their local copy instead.
+func (s *FileTransferServer) Search(
+	ctx context.Context,
+	req *connect.Request[ft.SearchRequest],
+) (*connect.Response[ft.SearchResults], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader); err != nil {
+		return nil, err
+	}
+	if req.Msg.Query == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("query is required"))
+	}
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, req.Msg.VaultId); err == nil {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("vault %s is end-to-end encrypted and can only be searched on a client", req.Msg.VaultId))
+	}
+
+	filter := sql_manager.SearchFilter{
+		Folder: req.Msg.Folder,
+		Tag:    req.Msg.Tag,
+		Limit:  int(req.Msg.Limit),
+	}
+	if filter.Limit > maxSearchLimit {
+		filter.Limit = maxSearchLimit
+	}
+	if req.Msg.ModifiedAfter != nil {
+		filter.ModifiedAfter = req.Msg.ModifiedAfter.AsTime()
+	}
+	if req.Msg.ModifiedBefore != nil {
+		filter.ModifiedBefore = req.Msg.ModifiedBefore.AsTime()
+	}
+
+	hits, err := sql_manager.SearchFiles(s.db, req.Msg.VaultId, req.Msg.Query, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ft.SearchResults{Hits: make([]*ft.SearchHit, len(hits))}
+	for i, hit := range hits {
+		res.Hits[i] = searchHitMessage(hit)
+	}
+	return connect.NewResponse(res), nil
+}
+
+func searchHitMessage(hit sql_manager.SearchHit) *ft.SearchHit {
+	return &ft.SearchHit{
+		FileId:    hit.FileID,
+		Location:  hit.Location,
+		Snippet:   hit.Snippet,
+		Rank:      hit.Rank,
+		Timestamp: timestamppb.New(hit.Timestamp),
+	}
+}
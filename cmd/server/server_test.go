@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itsrobel/sync/internal/blobs"
+	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/watcher"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testServer runs the sync server over h2c on a SQLite database. It has no
+// users, so every caller is the owner until a test creates one.
+func testServer(t *testing.T) (*FileTransferServer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "server.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sql_manager.MigrateServer(db); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewFileTransferServer(db)
+	if s.blobs, err = blobs.NewStore(filepath.Join(dir, "attachments")); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(filetransferconnect.NewFileServiceHandler(s))
+	srv := httptest.NewServer(h2c.NewHandler(mux, &http2.Server{}))
+	t.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+// client is a device syncing dir into the default vault.
+func client(t *testing.T, url, name, dir string) watcher.Config {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return watcher.Config{
+		DBPath:     filepath.Join(t.TempDir(), name+".db"),
+		ClientName: name,
+		ServerAddr: url,
+		Folders:    []watcher.Folder{{Path: dir, Vault: sql_manager.DefaultVault}},
+	}
+}
+
+func syncResult(t *testing.T, cfg watcher.Config) (*watcher.SyncResult, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	return watcher.Sync(ctx, cfg)
+}
+
+// runSync runs one pass and returns the result of the default vault.
+func runSync(t *testing.T, cfg watcher.Config) *watcher.VaultSyncResult {
+	t.Helper()
+	result, err := syncResult(t, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &result.Vaults[0]
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
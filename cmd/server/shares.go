@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// CreateShareLink mints a link for a file or folder. Encrypted vaults cannot
+// be shared since the server only holds ciphertext.
+func (s *FileTransferServer) CreateShareLink(
+	ctx context.Context,
+	req *connect.Request[ft.ShareLink],
+) (*connect.Response[ft.ShareLink], error) {
+	user, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := sql_manager.GetCurrentVaultKey(s.db, req.Msg.VaultId); err == nil {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("vault %s is end-to-end encrypted and cannot be shared", req.Msg.VaultId))
+	}
+
+	link := &sql_manager.ShareLink{
+		VaultID:  req.Msg.VaultId,
+		Location: strings.Trim(req.Msg.Location, "/"),
+		Folder:   req.Msg.Folder,
+	}
+	if !link.Folder {
+		if _, err := sql_manager.FindFileByLocation(s.db, link.VaultID, link.Location); err == gorm.ErrRecordNotFound {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("file %s not found", link.Location))
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if req.Msg.ExpiresAt != nil {
+		expires := req.Msg.ExpiresAt.AsTime()
+		if expires.Before(time.Now()) {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("expiry is in the past"))
+		}
+		link.ExpiresAt = &expires
+	}
+	if req.Msg.Password != "" {
+		if link.PasswordHash, err = auth.HashPassword(req.Msg.Password); err != nil {
+			return nil, err
+		}
+	}
+	if req.Msg.Pinned {
+		now := time.Now()
+		link.PinnedAt = &now
+	}
+	if user != nil {
+		link.CreatedBy = user.ID
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	link.TokenHash = hash
+	if err := sql_manager.CreateShareLink(s.db, link); err != nil {
+		return nil, err
+	}
+
+	msg := shareLinkMessage(link)
+	msg.Token = token
+	return connect.NewResponse(msg), nil
+}
+
+func (s *FileTransferServer) ListShareLinks(
+	ctx context.Context,
+	req *connect.Request[ft.ActionRequest],
+) (*connect.Response[ft.ShareLinkList], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	links, err := sql_manager.GetShareLinks(s.db, req.Msg.VaultId)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*ft.ShareLink, len(links))
+	for idx := range links {
+		list[idx] = shareLinkMessage(&links[idx])
+	}
+	return connect.NewResponse(&ft.ShareLinkList{Links: list}), nil
+}
+
+func (s *FileTransferServer) RevokeShareLink(
+	ctx context.Context,
+	req *connect.Request[ft.ShareLink],
+) (*connect.Response[ft.ActionResponse], error) {
+	if _, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	if err := sql_manager.DeleteShareLink(s.db, req.Msg.VaultId, req.Msg.Id); err == gorm.ErrRecordNotFound {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("share link %s not found", req.Msg.Id))
+	} else if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&ft.ActionResponse{Success: true, Message: "share link revoked"}), nil
+}
+
+// OpenShareLink is the only RPC that needs no device token, the share token
+// (and password, if set) is the credential.
+func (s *FileTransferServer) OpenShareLink(
+	ctx context.Context,
+	req *connect.Request[ft.ShareRequest],
+) (*connect.Response[ft.SharedContent], error) {
+	link, err := sql_manager.FindShareLink(s.db, auth.HashToken(req.Msg.Token))
+	if err == gorm.ErrRecordNotFound {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("share link not found or expired"))
+	} else if err != nil {
+		return nil, err
+	}
+	if link.PasswordHash != "" && !auth.CheckPassword(link.PasswordHash, req.Msg.Password) {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("share link password required"))
+	}
+
+	files, err := sql_manager.GetSharedFiles(s.db, link)
+	if err != nil {
+		return nil, err
+	}
+
+	location := req.Msg.Location
+	if !link.Folder {
+		location = link.Location
+	}
+	content := &ft.SharedContent{Link: shareLinkMessage(link)}
+	if location == "" {
+		for _, file := range files {
+			content.Locations = append(content.Locations, file.Location)
+		}
+		sort.Strings(content.Locations)
+		return connect.NewResponse(content), nil
+	}
+
+	for _, file := range files {
+		if file.Location == location {
+			if file.Blob {
+				if file.Content, err = s.readBlob(&file); err != nil {
+					return nil, err
+				}
+			}
+			content.File = &ft.SharedFile{
+				Location:  file.Location,
+				Content:   file.Content,
+				Timestamp: timestamppb.New(file.Timestamp),
+			}
+			return connect.NewResponse(content), nil
+		}
+	}
+	return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("%s is not part of this share", location))
+}
+
+func shareLinkMessage(link *sql_manager.ShareLink) *ft.ShareLink {
+	msg := &ft.ShareLink{
+		Id:          link.ID,
+		VaultId:     link.VaultID,
+		Location:    link.Location,
+		Folder:      link.Folder,
+		HasPassword: link.PasswordHash != "",
+		Pinned:      link.PinnedAt != nil,
+		CreatedAt:   timestamppb.New(link.CreatedAt),
+	}
+	if link.ExpiresAt != nil {
+		msg.ExpiresAt = timestamppb.New(*link.ExpiresAt)
+	}
+	if link.PinnedAt != nil {
+		msg.PinnedAt = timestamppb.New(*link.PinnedAt)
+	}
+	return msg
+}
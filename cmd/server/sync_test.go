@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/watcher"
+)
+
+func TestSync(t *testing.T) {
+	_, url := testServer(t)
+	laptop := client(t, url, "laptop", filepath.Join(t.TempDir(), "laptop"))
+	phone := client(t, url, "phone", filepath.Join(t.TempDir(), "phone"))
+	writeFile(t, filepath.Join(laptop.Folders[0].Path, "notes/a.md"), "from the laptop")
+
+	if r := runSync(t, laptop); len(r.Uploaded) != 1 || r.Error != "" {
+		t.Fatalf("laptop: %+v", r)
+	}
+	r := runSync(t, phone)
+	if len(r.Downloaded) != 1 || r.Error != "" {
+		t.Fatalf("phone: %+v", r)
+	}
+	if data, _ := os.ReadFile(filepath.Join(phone.Folders[0].Path, "notes/a.md")); string(data) != "from the laptop" {
+		t.Errorf("phone has %q", data)
+	}
+	if r := runSync(t, phone); len(r.Downloaded) != 0 || len(r.Uploaded) != 0 {
+		t.Errorf("a second pass changed something: %+v", r)
+	}
+}
+
+func TestSyncRetriesFailedDownloads(t *testing.T) {
+	s, url := testServer(t)
+	laptop := client(t, url, "laptop", filepath.Join(t.TempDir(), "laptop"))
+	phone := client(t, url, "phone", filepath.Join(t.TempDir(), "phone"))
+	writeFile(t, filepath.Join(laptop.Folders[0].Path, "a.md"), "note")
+	runSync(t, laptop)
+
+	// a directory in the way makes writing the note fail
+	blocked := filepath.Join(phone.Folders[0].Path, "a.md")
+	if err := os.MkdirAll(blocked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	result, err := syncResult(t, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := result.ExitCode(); code != watcher.SyncFailed || result.Vaults[0].Error == "" {
+		t.Fatalf("exit code %d, result %+v", code, result.Vaults[0])
+	}
+	var session sql_manager.ClientSession
+	if err := s.db.First(&session, "session_id = ?", "phone").Error; err != nil {
+		t.Fatal(err)
+	}
+	if !session.LastSyncTime.IsZero() {
+		t.Error("the server moved the cursor past a file the client did not get")
+	}
+
+	os.Remove(blocked)
+	if r := runSync(t, phone); len(r.Downloaded) != 1 || r.Error != "" {
+		t.Errorf("the file was not offered again: %+v", r)
+	}
+	if err := s.db.First(&session, "session_id = ?", "phone").Error; err != nil || session.LastSyncTime.IsZero() {
+		t.Errorf("the cursor did not move after a complete catch-up: %v", err)
+	}
+}
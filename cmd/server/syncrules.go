@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// GetSyncRules returns the folders a client mirrors of a vault.
+func (s *FileTransferServer) GetSyncRules(
+	ctx context.Context,
+	req *connect.Request[ft.SyncRules],
+) (*connect.Response[ft.SyncRules], error) {
+	user, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.claimSession(req.Msg.SessionId, user); err != nil {
+		return nil, err
+	}
+
+	rules, err := sql_manager.GetSessionRules(s.db, req.Msg.SessionId, req.Msg.VaultId)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(rulesMessage(req.Msg.VaultId, req.Msg.SessionId, rules)), nil
+}
+
+// SetSyncRules replaces the folders a client mirrors. Catch-up and
+// notifications only cover files the rules sync, files of folders the client
+// adds are downloaded by the client itself. Rules match locations, so vaults
+// with encrypted paths cannot use them.
+func (s *FileTransferServer) SetSyncRules(
+	ctx context.Context,
+	req *connect.Request[ft.SyncRules],
+) (*connect.Response[ft.SyncRules], error) {
+	user, _, err := s.authorize(req.Header(), req.Msg.VaultId, auth.RoleReader)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.claimSession(req.Msg.SessionId, user); err != nil {
+		return nil, err
+	}
+	if key, err := sql_manager.GetCurrentVaultKey(s.db, req.Msg.VaultId); err == nil && key.EncryptPaths {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("vault %s encrypts paths, its folders cannot be selected", req.Msg.VaultId))
+	}
+	rules, err := sql_manager.NewSyncRules(req.Msg.Include, req.Msg.Exclude)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	if err := sql_manager.SetSessionRules(s.db, req.Msg.SessionId, req.Msg.VaultId, userID(user), rules); err != nil {
+		return nil, err
+	}
+	// a connected session picks them up right away
+	s.mu.Lock()
+	if session := s.sessions[req.Msg.SessionId]; session != nil && session.vaults[req.Msg.VaultId] {
+		session.rules[req.Msg.VaultId] = rules
+	}
+	s.mu.Unlock()
+	return connect.NewResponse(rulesMessage(req.Msg.VaultId, req.Msg.SessionId, rules)), nil
+}
+
+// claimSession refuses session ids that are missing or held by another
+// user, connected or not.
+func (s *FileTransferServer) claimSession(sessionID string, user *sql_manager.User) error {
+	if sessionID == "" {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New("session id is required"))
+	}
+	s.mu.RLock()
+	session := s.sessions[sessionID]
+	s.mu.RUnlock()
+	if session != nil && userID(session.user) != userID(user) {
+		return connect.NewError(connect.CodePermissionDenied, sql_manager.ErrSessionTaken)
+	}
+	if err := sql_manager.ClaimSession(s.db, sessionID, userID(user)); errors.Is(err, sql_manager.ErrSessionTaken) {
+		return connect.NewError(connect.CodePermissionDenied, err)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func rulesMessage(vaultID, sessionID string, rules sql_manager.SyncRules) *ft.SyncRules {
+	return &ft.SyncRules{
+		VaultId:   vaultID,
+		SessionId: sessionID,
+		Include:   rules.Include,
+		Exclude:   rules.Exclude,
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// CreateVault is open to every authenticated user, who becomes its owner.
+func (s *FileTransferServer) CreateVault(
+	ctx context.Context,
+	req *connect.Request[ft.Vault],
+) (*connect.Response[ft.Vault], error) {
+	user, err := s.authenticate(req.Header())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := sql_manager.FindVault(s.db, req.Msg.Id); err == nil {
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("vault %s already exists", req.Msg.Id))
+	}
+
+	vault, err := sql_manager.CreateVault(s.db, req.Msg.Id, req.Msg.Name)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if user != nil {
+		if err := sql_manager.SetMembership(s.db, vault.ID, user.ID, string(auth.RoleOwner)); err != nil {
+			return nil, err
+		}
+	}
+	return connect.NewResponse(s.vaultMessage(vault)), nil
+}
+
+// ListVaults returns the vaults the caller is a member of.
+func (s *FileTransferServer) ListVaults(
+	ctx context.Context,
+	req *connect.Request[ft.ActionRequest],
+) (*connect.Response[ft.VaultList], error) {
+	user, err := s.authenticate(req.Header())
+	if err != nil {
+		return nil, err
+	}
+
+	var vaults []sql_manager.Vault
+	if user == nil {
+		vaults, err = sql_manager.GetAllVaults(s.db)
+	} else {
+		vaults, err = sql_manager.GetUserVaults(s.db, user.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*ft.Vault, len(vaults))
+	for idx := range vaults {
+		list[idx] = s.vaultMessage(&vaults[idx])
+	}
+	return connect.NewResponse(&ft.VaultList{Vaults: list}), nil
+}
+
+// requireVault turns an unknown vault id into a NotFound error for the caller.
+func (s *FileTransferServer) requireVault(vaultID string) error {
+	_, err := sql_manager.FindVault(s.db, vaultID)
+	if err == gorm.ErrRecordNotFound {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("vault %q not found", vaultID))
+	}
+	return err
+}
+
+func (s *FileTransferServer) vaultMessage(vault *sql_manager.Vault) *ft.Vault {
+	return &ft.Vault{
+		Id:          vault.ID,
+		Name:        vault.Name,
+		CreatedAt:   timestamppb.New(vault.CreatedAt),
+		MaxFileSize: s.fileLimit(vault.ID),
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	sql_manager "github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// watchBuffer is how many changes a watcher may fall behind before further
+// ones are dropped for it.
+const watchBuffer = 64
+
+// vaultWatch is an open WatchChanges call, unlike a session it has no sync
+// rules, is never paused and is told about its own changes too.
+type vaultWatch struct {
+	vaultID string
+	changes chan *ft.ControlMessage
+}
+
+// WatchChanges streams a NEW_FILE message for every file stored in a vault,
+// deletes and renames included, until the caller hangs up. SessionId names
+// the client the change came from. A READY is sent first so the caller knows
+// it will not miss what is stored after it.
+func (s *FileTransferServer) WatchChanges(
+	ctx context.Context,
+	req *connect.Request[ft.ActionRequest],
+	stream *connect.ServerStream[ft.ControlMessage],
+) error {
+	vaultID := req.Msg.VaultId
+	if vaultID == "" {
+		vaultID = sql_manager.DefaultVault
+	}
+	if _, _, err := s.authorize(req.Header(), vaultID, auth.RoleReader); err != nil {
+		return err
+	}
+
+	w := &vaultWatch{vaultID: vaultID, changes: make(chan *ft.ControlMessage, watchBuffer)}
+	s.mu.Lock()
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, w)
+		s.mu.Unlock()
+	}()
+
+	if err := stream.Send(&ft.ControlMessage{Type: ft.ControlMessage_READY, VaultId: vaultID}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-w.changes:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// notifyWatchers passes a change on to the watchers of its vault. It is
+// called by broadcast with mu held, so a watcher that is not keeping up
+// misses the change rather than holding up the sessions.
+func (s *FileTransferServer) notifyWatchers(from string, msg *ft.ControlMessage) {
+	for w := range s.watchers {
+		if w.vaultID != msg.VaultId {
+			continue
+		}
+		change := &ft.ControlMessage{
+			SessionId: from,
+			Type:      msg.Type,
+			Filename:  msg.Filename,
+			FileId:    msg.FileId,
+			VaultId:   msg.VaultId,
+		}
+		select {
+		case w.changes <- change:
+		default:
+			log.Printf("Watcher of vault %s fell behind, dropped change to %s", msg.VaultId, msg.Filename)
+		}
+	}
+}
@@ -1,58 +1,103 @@
 package main
 
 import (
-	"crypto/tls"
+	"flag"
 	"log"
-	"net"
 	"net/http"
+	"os"
 
+	"github.com/itsrobel/sync/internal/auth"
+	"github.com/itsrobel/sync/internal/config"
 	"github.com/itsrobel/sync/internal/handlers"
 	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
+	"github.com/itsrobel/sync/internal/transport"
 
 	"github.com/rs/cors"
-	"golang.org/x/net/http2"
 )
 
 func main() {
+	cfg, err := config.Load(config.Path(os.Args[1:]))
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	web := &cfg.Web
+	// already read by config.Path, registered so flag.Parse accepts it
+	flag.String("config", "", "path to a yaml or toml config file (or set SYNC_CONFIG)")
+	flag.StringVar(&web.Addr, "addr", web.Addr, "address the web app listens on")
+	flag.StringVar(&web.ShareAddr, "share-addr", web.ShareAddr, "address share links are served on, empty turns them off")
+	flag.StringVar(&web.Server, "server", web.Server, "address of the sync server")
+	flag.StringVar(&web.Token, "token", web.Token, "device token issued by the server (or set SYNC_TOKEN)")
+	flag.StringVar(&web.Vault, "vault", web.Vault, "vault shown by the web app")
+	flag.BoolVar(&web.TLS.Enabled, "tls", web.TLS.Enabled, "connect to the sync server over TLS")
+	flag.StringVar(&web.TLS.CA, "tls-ca", web.TLS.CA, "PEM bundle of CAs trusted for the server certificate")
+	flag.Var((*config.StringList)(&web.TLS.Pins), "tls-pin", "comma separated sha256 fingerprints of trusted server certificates")
+	flag.Parse()
+
+	if err := web.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
 	mux := http.NewServeMux()
 
+	httpClient, err := transport.NewHTTPClient(web.TLS.Options())
+	if err != nil {
+		log.Fatalf("Failed to configure transport: %v", err)
+	}
+
 	client := filetransferconnect.NewFileServiceClient(
-		&http.Client{
-			Transport: &http2.Transport{
-				AllowHTTP: true,
-				DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
-					return net.Dial(network, addr)
-				},
-			},
-		},
-		"http://localhost:50051",
+		httpClient,
+		transport.BaseURL(web.Server, web.TLS.Enabled),
+		auth.WithToken(web.Token),
 	)
 
 	// Initialize handlers
-	handlers := handlers.NewHandlers(client)
+	handlers := handlers.NewHandlers(client, web.Vault)
 
 	// Routes
 	mux.HandleFunc("/", handlers.Index)
+	mux.HandleFunc("/files", handlers.HandleFiles)
+	mux.HandleFunc("/note", handlers.HandleNote)
 	mux.HandleFunc("/edit", handlers.HandleEditor)
-	mux.HandleFunc("/greet", handlers.HandleGreet)
-	// mux.HandleFunc("/greet", handlers.HandleGreet)
-	// mux.HandleFunc("/draw", handlers.HandleDraw)
-
-	// Serve static files
-	fs := http.FileServer(http.Dir("web"))
-	mux.Handle("/web/", http.StripPrefix("/web/", fs))
-
-	// Configure CORS
+	mux.HandleFunc("/draw", handlers.HandleDraw)
+	mux.HandleFunc("/graph", handlers.HandleGraph)
+	mux.HandleFunc("/graph.json", handlers.HandleGraphJSON)
+	mux.HandleFunc("/search", handlers.HandleSearch)
+	mux.HandleFunc("/history", handlers.HandleHistory)
+	mux.HandleFunc("/diff", handlers.HandleDiff)
+	mux.HandleFunc("/restore", handlers.HandleRestore)
+	mux.HandleFunc("/events", handlers.HandleEvents)
+	mux.HandleFunc("/export", handlers.HandleExport)
+	mux.HandleFunc("/upload", handlers.HandleUpload)
+	// the vault's data is read with the device token, so other origins
+	// must not read it through a visitor's browser. Only the greeting is
+	// open to them
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
 		AllowedHeaders: []string{"Accept", "Content-Type", "Connect-Protocol-Version"},
 	})
+	mux.Handle("/greet", corsHandler.Handler(http.HandlerFunc(handlers.HandleGreet)))
 
-	wrappedHandler := corsHandler.Handler(mux)
+	// Serve static files
+	fs := http.StripPrefix("/web/", http.FileServer(http.Dir("web")))
+	mux.Handle("/web/", fs)
+
+	// share links get a listener of their own, everything on the main one
+	// reads the vault with the device token
+	if web.ShareAddr != "" {
+		shares := http.NewServeMux()
+		shares.HandleFunc("/s/", handlers.HandleShare)
+		shares.Handle("/web/", fs)
+		go func() {
+			log.Printf("Share links served on %s", web.ShareAddr)
+			if err := http.ListenAndServe(web.ShareAddr, shares); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
 
-	log.Println("Server starting on :3000")
-	if err := http.ListenAndServe(":3000", wrappedHandler); err != nil {
+	log.Printf("Server starting on %s", web.Addr)
+	if err := http.ListenAndServe(web.Addr, mux); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -4,17 +4,22 @@ go 1.23
 
 require (
 	connectrpc.com/connect v1.17.0
+	github.com/BurntSushi/toml v1.4.0
+	github.com/a-h/templ v0.3.819
 	github.com/fsnotify/fsnotify v1.7.0
+	github.com/google/uuid v1.6.0
+	github.com/rs/cors v1.11.1
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/crypto v0.32.0
 	golang.org/x/net v0.33.0
 	google.golang.org/protobuf v1.35.1
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/postgres v1.5.11
 	gorm.io/driver/sqlite v1.5.7
 	gorm.io/gorm v1.25.12
 )
 
 require (
-	github.com/a-h/templ v0.3.819 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
 	github.com/jackc/pgx/v5 v5.7.2 // indirect
@@ -22,8 +27,6 @@ require (
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/mattn/go-sqlite3 v1.14.24 // indirect
-	github.com/rs/cors v1.11.1 // indirect
-	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/sync v0.10.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
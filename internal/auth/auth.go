@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleReader Role = "reader"
+)
+
+var roleRank = map[Role]int{
+	RoleReader: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+func ParseRole(value string) (Role, error) {
+	role := Role(strings.ToLower(value))
+	if role == "read-only" {
+		role = RoleReader
+	}
+	if _, ok := roleRank[role]; !ok {
+		return "", fmt.Errorf("unknown role %q: use owner, editor or reader", value)
+	}
+	return role, nil
+}
+
+// Allows reports whether r grants at least the permissions of need.
+func (r Role) Allows(need Role) bool {
+	return roleRank[r] >= roleRank[need]
+}
+
+func (r Role) CanWrite() bool {
+	return r.Allows(RoleEditor)
+}
+
+// NewToken returns a random device token and the hash the server stores.
+func NewToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashPassword hashes a share link password for storage.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// BearerToken extracts the token from an Authorization header.
+func BearerToken(header http.Header) string {
+	value := header.Get("Authorization")
+	if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// WithToken attaches the device token to every request a client makes.
+func WithToken(token string) connect.ClientOption {
+	return connect.WithInterceptors(tokenInterceptor{token: token})
+}
+
+type tokenInterceptor struct {
+	token string
+}
+
+func (t tokenInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if t.token != "" {
+			req.Header().Set("Authorization", "Bearer "+t.token)
+		}
+		return next(ctx, req)
+	}
+}
+
+func (t tokenInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		if t.token != "" {
+			conn.RequestHeader().Set("Authorization", "Bearer "+t.token)
+		}
+		return conn
+	}
+}
+
+func (t tokenInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
@@ -0,0 +1,93 @@
+package blobs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+var ErrTooLarge = errors.New("file is larger than the limit")
+
+// Store keeps attachment content on disk, one file per version, so large
+// files never pass through the database or sit in memory.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create content directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// path only accepts uuids, so ids from requests can never leave the store
+func (s *Store) path(vaultID, versionID string) (string, error) {
+	if _, err := uuid.Parse(versionID); err != nil {
+		return "", fmt.Errorf("invalid version id %q", versionID)
+	}
+	if vaultID == "" || filepath.Base(vaultID) != vaultID || vaultID == "." || vaultID == ".." {
+		return "", fmt.Errorf("invalid vault id %q", vaultID)
+	}
+	return filepath.Join(s.dir, vaultID, versionID), nil
+}
+
+// Write copies r into the store and returns the number of bytes written. It
+// fails with ErrTooLarge as soon as more than limit bytes arrive, a limit of
+// 0 means none. Nothing is kept when Write fails.
+func (s *Store) Write(vaultID, versionID string, r io.Reader, limit int64) (int64, error) {
+	path, err := s.path(vaultID, versionID)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		return n, err
+	}
+	if limit > 0 && n > limit {
+		return n, ErrTooLarge
+	}
+	if err := tmp.Close(); err != nil {
+		return n, err
+	}
+	return n, os.Rename(tmp.Name(), path)
+}
+
+// Remove deletes the content of a version, a version without content is not
+// an error.
+func (s *Store) Remove(vaultID, versionID string) error {
+	path, err := s.path(vaultID, versionID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Open returns the content of a version, the caller closes it.
+func (s *Store) Open(vaultID, versionID string) (*os.File, error) {
+	path, err := s.path(vaultID, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
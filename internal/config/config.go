@@ -0,0 +1,392 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/itsrobel/sync/internal/transport"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is shared by the server, cli and web binaries, each only reads its
+// own section. Values are layered: defaults, then the config file, then
+// SYNC_* environment variables, then command line flags.
+type Config struct {
+	Server ServerConfig `yaml:"server" toml:"server"`
+	Client ClientConfig `yaml:"client" toml:"client"`
+	Web    WebConfig    `yaml:"web" toml:"web"`
+
+	// Source is the file the config was read from, empty when none was found
+	Source string `yaml:"-" toml:"-"`
+}
+
+type ServerConfig struct {
+	Addr        string          `yaml:"addr" toml:"addr" env:"SYNC_SERVER_ADDR"`
+	DatabaseURL string          `yaml:"database_url" toml:"database_url" env:"SYNC_DATABASE_URL"`
+	TLS         ServerTLSConfig `yaml:"tls" toml:"tls"`
+	// RewriteLinks updates links in other notes when a note is renamed
+	RewriteLinks bool `yaml:"rewrite_links" toml:"rewrite_links" env:"SYNC_REWRITE_LINKS"`
+	// ContentDir holds attachments, they are kept out of the database
+	ContentDir  string `yaml:"content_dir" toml:"content_dir" env:"SYNC_CONTENT_DIR"`
+	MaxFileSize string `yaml:"max_file_size" toml:"max_file_size" env:"SYNC_MAX_FILE_SIZE"`
+	// Vaults overrides settings per vault id
+	Vaults map[string]VaultConfig `yaml:"vaults" toml:"vaults"`
+}
+
+type VaultConfig struct {
+	MaxFileSize string `yaml:"max_file_size" toml:"max_file_size"`
+}
+
+type ServerTLSConfig struct {
+	Cert       string   `yaml:"cert" toml:"cert" env:"SYNC_TLS_CERT"`
+	Key        string   `yaml:"key" toml:"key" env:"SYNC_TLS_KEY"`
+	SelfSigned bool     `yaml:"self_signed" toml:"self_signed" env:"SYNC_TLS_SELF_SIGNED"`
+	Hosts      []string `yaml:"hosts" toml:"hosts" env:"SYNC_TLS_HOSTS"`
+}
+
+// ClientTLSConfig is used by both the cli and the web app to reach the server.
+type ClientTLSConfig struct {
+	Enabled    bool     `yaml:"enabled" toml:"enabled"`
+	CA         string   `yaml:"ca" toml:"ca"`
+	Pins       []string `yaml:"pins" toml:"pins"`
+	ServerName string   `yaml:"server_name" toml:"server_name"`
+}
+
+type ClientConfig struct {
+	Server         string          `yaml:"server" toml:"server" env:"SYNC_SERVER"`
+	Name           string          `yaml:"name" toml:"name" env:"SYNC_CLIENT_NAME"`
+	DBPath         string          `yaml:"db_path" toml:"db_path" env:"SYNC_DB_PATH"`
+	Token          string          `yaml:"token" toml:"token" env:"SYNC_TOKEN"`
+	PassphraseFile string          `yaml:"passphrase_file" toml:"passphrase_file" env:"SYNC_PASSPHRASE_FILE"`
+	EncryptPaths   bool            `yaml:"encrypt_paths" toml:"encrypt_paths"`
+	TLS            ClientTLSConfig `yaml:"tls" toml:"tls"`
+	Folders        []FolderConfig  `yaml:"folders" toml:"folders"`
+}
+
+type FolderConfig struct {
+	Path  string `yaml:"path" toml:"path"`
+	Vault string `yaml:"vault" toml:"vault"`
+	// MaxFileSize skips larger files, on top of the server's limit
+	MaxFileSize string `yaml:"max_file_size" toml:"max_file_size"`
+	// LazyAttachments only downloads attachments when they are fetched
+	LazyAttachments bool `yaml:"lazy_attachments" toml:"lazy_attachments"`
+}
+
+type WebConfig struct {
+	Addr string `yaml:"addr" toml:"addr" env:"SYNC_WEB_ADDR"`
+	// ShareAddr serves share links on their own, so they can be exposed
+	// without the rest of the web app. Empty turns share links off
+	ShareAddr string          `yaml:"share_addr" toml:"share_addr" env:"SYNC_WEB_SHARE_ADDR"`
+	Server    string          `yaml:"server" toml:"server" env:"SYNC_SERVER"`
+	Token     string          `yaml:"token" toml:"token" env:"SYNC_TOKEN"`
+	Vault     string          `yaml:"vault" toml:"vault" env:"SYNC_VAULT"`
+	TLS       ClientTLSConfig `yaml:"tls" toml:"tls"`
+}
+
+// searched in order when no -config flag or SYNC_CONFIG is given
+var defaultPaths = []string{"sync.yaml", "sync.yml", "sync.toml"}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:        "localhost:50051",
+			DatabaseURL: "host=localhost user=postgres password=postgres dbname=myapp port=5432 sslmode=disable",
+			ContentDir:  "./attachments",
+			MaxFileSize: "100MB",
+		},
+		Client: ClientConfig{
+			Server: "localhost:50051",
+			Name:   hostname(),
+			DBPath: "./sync-test.db",
+			Folders: []FolderConfig{
+				{Path: "./content", Vault: "default"},
+			},
+		},
+		Web: WebConfig{
+			Addr:      ":3000",
+			ShareAddr: ":3001",
+			Server:    "localhost:50051",
+			Vault:     "default",
+		},
+	}
+}
+
+// Load builds the configuration from path (or the default locations when
+// empty) and the environment. Flags are applied afterwards by the caller.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("SYNC_CONFIG")
+	}
+	if path == "" {
+		for _, candidate := range defaultPaths {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+		cfg.Source = path
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("%s: config must be .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// FileFolders returns the client folders listed in the config file at path,
+// without the defaults. A missing file lists none.
+func FileFolders(path string) ([]FolderConfig, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	var layer Config
+	if err := layer.readFile(path); err != nil {
+		return nil, err
+	}
+	return layer.Client.Folders, nil
+}
+
+// SaveFolders sets client.folders in the config file at path, creating it
+// when missing. The rest of the file is kept as it is, so defaults,
+// environment variables and flags never end up in it.
+func SaveFolders(path string, folders []FolderConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	layer := map[string]interface{}{}
+	format := strings.ToLower(filepath.Ext(path))
+	switch format {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &layer)
+	case ".toml":
+		_, err = toml.Decode(string(data), &layer)
+	default:
+		return fmt.Errorf("%s: config must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if layer == nil {
+		layer = map[string]interface{}{}
+	}
+
+	list := make([]map[string]interface{}, len(folders))
+	for i, folder := range folders {
+		entry := map[string]interface{}{"path": folder.Path, "vault": folder.Vault}
+		if folder.MaxFileSize != "" {
+			entry["max_file_size"] = folder.MaxFileSize
+		}
+		if folder.LazyAttachments {
+			entry["lazy_attachments"] = true
+		}
+		list[i] = entry
+	}
+	client, _ := layer["client"].(map[string]interface{})
+	if client == nil {
+		client = map[string]interface{}{}
+	}
+	client["folders"] = list
+	layer["client"] = client
+
+	var buf bytes.Buffer
+	if format == ".toml" {
+		err = toml.NewEncoder(&buf).Encode(layer)
+	} else {
+		err = yaml.NewEncoder(&buf).Encode(layer)
+	}
+	if err != nil {
+		return err
+	}
+	// the file may hold device tokens
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// applyEnv walks the config and overrides every field with an env tag that
+// is set in the environment.
+func applyEnv(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		info := v.Type().Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := info.Tag.Get("env")
+		value, ok := os.LookupEnv(name)
+		if name == "" || !ok {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: expected true or false, got %q", name, value)
+			}
+			field.SetBool(parsed)
+		case reflect.Slice:
+			field.Set(reflect.ValueOf(SplitList(value)))
+		}
+	}
+	return nil
+}
+
+func (c *ServerConfig) Validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("server.addr is required")
+	}
+	if c.DatabaseURL == "" {
+		return fmt.Errorf("server.database_url is required")
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		return fmt.Errorf("server.tls needs both cert and key")
+	}
+	if c.ContentDir == "" {
+		return fmt.Errorf("server.content_dir is required")
+	}
+	if _, err := ParseSize(c.MaxFileSize); err != nil {
+		return fmt.Errorf("server.max_file_size: %w", err)
+	}
+	for id, vault := range c.Vaults {
+		if _, err := ParseSize(vault.MaxFileSize); err != nil {
+			return fmt.Errorf("server.vaults.%s.max_file_size: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// FileLimits returns the server wide size limit and the per vault ones in
+// bytes, 0 means unlimited. Validate has checked them.
+func (c *ServerConfig) FileLimits() (int64, map[string]int64) {
+	limit, _ := ParseSize(c.MaxFileSize)
+	vaults := make(map[string]int64, len(c.Vaults))
+	for id, vault := range c.Vaults {
+		if vault.MaxFileSize != "" {
+			vaults[id], _ = ParseSize(vault.MaxFileSize)
+		}
+	}
+	return limit, vaults
+}
+
+func (c *ServerConfig) TLSOptions() transport.ServerTLS {
+	return transport.ServerTLS{
+		CertFile:   c.TLS.Cert,
+		KeyFile:    c.TLS.Key,
+		SelfSigned: c.TLS.SelfSigned,
+		Hosts:      c.TLS.Hosts,
+	}
+}
+
+func (c *ClientConfig) Validate() error {
+	if c.Server == "" {
+		return fmt.Errorf("client.server is required")
+	}
+	if c.Name == "" {
+		return fmt.Errorf("client.name is required")
+	}
+	if c.DBPath == "" {
+		return fmt.Errorf("client.db_path is required")
+	}
+	if len(c.Folders) == 0 {
+		return fmt.Errorf("client.folders needs at least one path and vault")
+	}
+	for i, folder := range c.Folders {
+		if folder.Path == "" || folder.Vault == "" {
+			return fmt.Errorf("client.folders[%d] needs both path and vault", i)
+		}
+		if _, err := ParseSize(folder.MaxFileSize); err != nil {
+			return fmt.Errorf("client.folders[%d].max_file_size: %w", i, err)
+		}
+	}
+	return c.TLS.validate("client.tls")
+}
+
+func (c *WebConfig) Validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("web.addr is required")
+	}
+	if c.Server == "" {
+		return fmt.Errorf("web.server is required")
+	}
+	if c.Vault == "" {
+		return fmt.Errorf("web.vault is required")
+	}
+	if c.ShareAddr != "" && c.ShareAddr == c.Addr {
+		return fmt.Errorf("web.share_addr has to differ from web.addr")
+	}
+	return c.TLS.validate("web.tls")
+}
+
+func (c ClientTLSConfig) validate(section string) error {
+	if !c.Enabled && (c.CA != "" || len(c.Pins) > 0) {
+		return fmt.Errorf("%s: ca and pins are set but tls is not enabled", section)
+	}
+	return nil
+}
+
+func (c ClientTLSConfig) Options() transport.ClientTLS {
+	return transport.ClientTLS{
+		Enabled:    c.Enabled,
+		CAFile:     c.CA,
+		Pins:       c.Pins,
+		ServerName: c.ServerName,
+	}
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "client"
+	}
+	return name
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeConfig(t, "sync.yaml", `
+server:
+  addr: ":9000"
+client:
+  server: "sync.example.com:443"
+`)
+	t.Setenv("SYNC_SERVER_ADDR", ":9100")
+	t.Setenv("SYNC_TLS_HOSTS", "a.example.com, b.example.com")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Source != path {
+		t.Errorf("Source = %q", cfg.Source)
+	}
+	if cfg.Server.Addr != ":9100" {
+		t.Errorf("server.addr = %q, the environment should win over the file", cfg.Server.Addr)
+	}
+	if cfg.Client.Server != "sync.example.com:443" {
+		t.Errorf("client.server = %q, want the value from the file", cfg.Client.Server)
+	}
+	if cfg.Server.ContentDir != Default().Server.ContentDir {
+		t.Errorf("server.content_dir = %q, want the default", cfg.Server.ContentDir)
+	}
+	if want := []string{"a.example.com", "b.example.com"}; !reflect.DeepEqual(cfg.Server.TLS.Hosts, want) {
+		t.Errorf("server.tls.hosts = %q", cfg.Server.TLS.Hosts)
+	}
+}
+
+func TestLoadToml(t *testing.T) {
+	path := writeConfig(t, "sync.toml", `
+[web]
+vault = "notes"
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Web.Vault != "notes" {
+		t.Errorf("web.vault = %q", cfg.Web.Vault)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+	}{
+		{"unknown yaml key", "sync.yaml", "server:\n  adress: x\n"},
+		{"unknown toml key", "sync.toml", "[server]\nadress = \"x\"\n"},
+		{"other format", "sync.json", "{}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, tt.file, tt.content)); err == nil {
+				t.Error("config was accepted")
+			}
+		})
+	}
+
+	t.Run("bad bool", func(t *testing.T) {
+		t.Setenv("SYNC_REWRITE_LINKS", "sometimes")
+		if _, err := Load(writeConfig(t, "sync.yaml", "")); err == nil {
+			t.Error("config was accepted")
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		check  func(*Config) error
+		want   string
+	}{
+		{"defaults", func(*Config) {}, nil, ""},
+		{"cert without key", func(c *Config) { c.Server.TLS.Cert = "server.crt" },
+			func(c *Config) error { return c.Server.Validate() }, "both cert and key"},
+		{"bad size", func(c *Config) { c.Server.Vaults = map[string]VaultConfig{"v": {MaxFileSize: "lots"}} },
+			func(c *Config) error { return c.Server.Validate() }, "server.vaults.v.max_file_size"},
+		{"folder without vault", func(c *Config) { c.Client.Folders = []FolderConfig{{Path: "notes"}} },
+			func(c *Config) error { return c.Client.Validate() }, "client.folders[0]"},
+		{"pins without tls", func(c *Config) { c.Client.TLS.Pins = []string{"ab"} },
+			func(c *Config) error { return c.Client.Validate() }, "not enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(cfg)
+			if tt.check == nil {
+				for _, err := range []error{cfg.Server.Validate(), cfg.Client.Validate(), cfg.Web.Validate()} {
+					if err != nil {
+						t.Error(err)
+					}
+				}
+				return
+			}
+			if err := tt.check(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileLimits(t *testing.T) {
+	c := ServerConfig{MaxFileSize: "2MB", Vaults: map[string]VaultConfig{"big": {MaxFileSize: "1G"}, "same": {}}}
+	limit, vaults := c.FileLimits()
+	if limit != 2<<20 {
+		t.Errorf("limit = %d", limit)
+	}
+	if want := map[string]int64{"big": 1 << 30}; !reflect.DeepEqual(vaults, want) {
+		t.Errorf("vault limits = %v", vaults)
+	}
+}
+
+func TestSaveFoldersKeepsFile(t *testing.T) {
+	for _, name := range []string{"sync.yaml", "sync.toml"} {
+		t.Run(name, func(t *testing.T) {
+			var content string
+			if strings.HasSuffix(name, ".toml") {
+				content = "[client]\ntoken = \"secret\"\n"
+			} else {
+				content = "client:\n  token: secret\n"
+			}
+			path := writeConfig(t, name, content)
+			// values from the environment must not end up in the file
+			t.Setenv("SYNC_SERVER", "env.example.com:443")
+
+			folders := []FolderConfig{{Path: "notes", Vault: "v", LazyAttachments: true}, {Path: "work", Vault: "w", MaxFileSize: "5MB"}}
+			if err := SaveFolders(path, folders); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "env.example.com") || strings.Contains(string(data), "database_url") {
+				t.Errorf("config file got more than the folders:\n%s", data)
+			}
+
+			got, err := FileFolders(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, folders) {
+				t.Errorf("FileFolders = %+v", got)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Client.Token != "secret" {
+				t.Errorf("client.token = %q, the rest of the file was lost", cfg.Client.Token)
+			}
+			if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+				t.Errorf("config file mode %o, want 600", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestSaveFoldersCreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.yaml")
+	if folders, err := FileFolders(path); err != nil || folders != nil {
+		t.Fatalf("FileFolders of a missing file = %v, %v", folders, err)
+	}
+	if err := SaveFolders(path, []FolderConfig{{Path: "notes", Vault: "v"}}); err != nil {
+		t.Fatal(err)
+	}
+	if folders, err := FileFolders(path); err != nil || len(folders) != 1 {
+		t.Errorf("FileFolders = %v, %v", folders, err)
+	}
+}
+
+func TestValidateShareAddr(t *testing.T) {
+	c := Default().Web
+	c.ShareAddr = c.Addr
+	if err := c.Validate(); err == nil {
+		t.Error("share links on the address of the web app were accepted")
+	}
+	c.ShareAddr = ""
+	if err := c.Validate(); err != nil {
+		t.Errorf("share links turned off: %v", err)
+	}
+}
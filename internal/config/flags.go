@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path finds the -config flag before the flag set is parsed, so the file can
+// be loaded first and its values used as the flag defaults.
+func Path(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// StringList is a flag.Value for comma separated lists such as TLS pins.
+type StringList []string
+
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *StringList) Set(value string) error {
+	*l = SplitList(value)
+	return nil
+}
+
+func SplitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+var sizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"GB", 1 << 30}, {"G", 1 << 30},
+	{"MB", 1 << 20}, {"M", 1 << 20},
+	{"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize reads sizes like 100MB, 512K or 1048576. Units are binary and
+// the empty string means no limit.
+func ParseSize(value string) (int64, error) {
+	input := value
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	scale := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, scale = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, use a number with an optional unit like 100MB", input)
+	}
+	return int64(n * float64(scale)), nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-config", "a.yaml"}, "a.yaml"},
+		{[]string{"sync", "--config=b.toml"}, "b.toml"},
+		{[]string{"-addr", ":1", "-config"}, ""},
+		{[]string{"--", "-config", "c.yaml"}, ""},
+		{[]string{"config", "d.yaml"}, ""},
+	}
+	for _, tt := range tests {
+		if got := Path(tt.args); got != tt.want {
+			t.Errorf("Path(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	if got := SplitList(" a, ,b ,"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("SplitList = %q", got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"1048576", 1 << 20},
+		{"100MB", 100 << 20},
+		{"512k", 512 << 10},
+		{"1.5 G", 3 << 29},
+		{"7B", 7},
+	}
+	for _, tt := range tests {
+		if got, err := ParseSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"lots", "-1MB", "MB"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) was accepted", in)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Equal Kind = iota
+	Insert
+	Delete
+)
+
+// Line is one line of an edit script.
+type Line struct {
+	Kind Kind
+	Text string
+	Old  int // 1-based line number in a, 0 for inserts
+	New  int // 1-based line number in b, 0 for deletes
+}
+
+// Lines computes a shortest line edit script from a to b using Myers'
+// algorithm.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+	n, m := len(x), len(y)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+2)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				return backtrack(trace, x, y, offset)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, x, y []string, offset int) []Line {
+	var script []Line
+	i, j := len(x), len(y)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := i - j
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := v[offset+prevK]
+		prevJ := prevI - prevK
+
+		for i > prevI && j > prevJ {
+			script = append(script, Line{Kind: Equal, Text: x[i-1], Old: i, New: j})
+			i--
+			j--
+		}
+		if d > 0 {
+			if i == prevI {
+				script = append(script, Line{Kind: Insert, Text: y[j-1], New: j})
+			} else {
+				script = append(script, Line{Kind: Delete, Text: x[i-1], Old: i})
+			}
+		}
+		i, j = prevI, prevJ
+	}
+
+	for l, r := 0, len(script)-1; l < r; l, r = l+1, r-1 {
+		script[l], script[r] = script[r], script[l]
+	}
+	return script
+}
+
+// Unified renders the difference between a and b as a unified diff with the
+// given number of context lines. It returns "" when they are equal.
+func Unified(a, b, nameA, nameB string, context int) string {
+	script := Lines(a, b)
+
+	var out strings.Builder
+	for start := 0; start < len(script); {
+		// find the next change
+		for start < len(script) && script[start].Kind == Equal {
+			start++
+		}
+		if start == len(script) {
+			break
+		}
+
+		from := max(start-context, 0)
+		end := start
+		for end < len(script) {
+			if script[end].Kind != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(script) && script[run].Kind == Equal {
+				run++
+			}
+			if run == len(script) || run-end > 2*context {
+				break
+			}
+			end = run
+		}
+		to := min(end+context, len(script))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		writeHunk(&out, script[from:to])
+		start = to
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, hunk []Line) {
+	oldStart, newStart, oldCount, newCount := 0, 0, 0, 0
+	for _, line := range hunk {
+		if line.Kind != Insert {
+			if oldStart == 0 {
+				oldStart = line.Old
+			}
+			oldCount++
+		}
+		if line.Kind != Delete {
+			if newStart == 0 {
+				newStart = line.New
+			}
+			newCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	prefix := map[Kind]string{Equal: " ", Insert: "+", Delete: "-"}
+	for _, line := range hunk {
+		out.WriteString(prefix[line.Kind] + line.Text + "\n")
+	}
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,266 @@
+// Package drawing reads and merges Excalidraw drawings, plain .excalidraw
+// files and the .excalidraw.md notes of the Obsidian plugin.
+package drawing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// EmptyScene is the scene of a new drawing.
+const EmptyScene = `{
+  "type": "excalidraw",
+  "version": 2,
+  "source": "sync",
+  "elements": [],
+  "appState": {},
+  "files": {}
+}`
+
+// the plugin keeps the scene in a code block under a Drawing heading, newer
+// versions compress it
+var sceneBlock = regexp.MustCompile("(?s)```(json|compressed-json)\n(.*?)\n```")
+
+// IsDrawing reports whether a location holds an Excalidraw drawing.
+func IsDrawing(location string) bool {
+	lower := strings.ToLower(location)
+	return strings.HasSuffix(lower, ".excalidraw") || strings.HasSuffix(lower, ".excalidraw.md")
+}
+
+func isNote(location string) bool {
+	return strings.HasSuffix(strings.ToLower(location), ".md")
+}
+
+// Scene returns the scene JSON of a drawing, EmptyScene when it has none
+// yet.
+func Scene(location, content string) (string, error) {
+	if !isNote(location) {
+		if strings.TrimSpace(content) == "" {
+			return EmptyScene, nil
+		}
+		return content, nil
+	}
+	match := sceneBlock.FindStringSubmatch(content)
+	if match == nil {
+		return EmptyScene, nil
+	}
+	if match[1] == "json" {
+		return match[2], nil
+	}
+	scene, err := decompressFromBase64(strings.Join(strings.Fields(match[2]), ""))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the compressed drawing: %w", err)
+	}
+	return scene, nil
+}
+
+// WithScene returns the content of a drawing holding scene. A note keeps its
+// text around the scene, which is always written uncompressed.
+func WithScene(location, content, scene string) string {
+	if !isNote(location) {
+		return scene
+	}
+	block := "```json\n" + scene + "\n```"
+	if loc := sceneBlock.FindStringIndex(content); loc != nil {
+		return content[:loc[0]] + block + content[loc[1]:]
+	}
+	return strings.TrimRight(content, "\n") + "\n\n%%\n# Drawing\n" + block + "\n%%\n"
+}
+
+// Merge combines two versions of a drawing that were changed independently.
+// Elements are matched by id and the one with the higher version is kept,
+// with ties going to the lower versionNonce like Excalidraw's own
+// reconciliation, so deletions survive too. Elements only one side has are
+// kept. The rest of the content, app state included, comes from ours, and
+// the element order from theirs with elements new in ours at the end.
+func Merge(location, ours, theirs string) (string, error) {
+	ourScene, err := Scene(location, ours)
+	if err != nil {
+		return "", err
+	}
+	theirScene, err := Scene(location, theirs)
+	if err != nil {
+		return "", err
+	}
+	merged, err := mergeScenes([]byte(ourScene), []byte(theirScene))
+	if err != nil {
+		return "", err
+	}
+	return WithScene(location, ours, merged), nil
+}
+
+type field struct {
+	key   string
+	value json.RawMessage
+}
+
+// element holds what merging looks at, the element itself stays raw so
+// fields this package does not know are kept.
+type element struct {
+	ID           string `json:"id"`
+	Version      int64  `json:"version"`
+	VersionNonce int64  `json:"versionNonce"`
+	raw          json.RawMessage
+}
+
+func mergeScenes(ours, theirs []byte) (string, error) {
+	ourFields, err := objectFields(ours)
+	if err != nil {
+		return "", err
+	}
+	theirFields, err := objectFields(theirs)
+	if err != nil {
+		return "", err
+	}
+
+	ourElements, err := elements(lookup(ourFields, "elements"))
+	if err != nil {
+		return "", err
+	}
+	theirElements, err := elements(lookup(theirFields, "elements"))
+	if err != nil {
+		return "", err
+	}
+	byID := make(map[string]element, len(ourElements))
+	for _, el := range ourElements {
+		byID[el.ID] = el
+	}
+	var merged []json.RawMessage
+	seen := make(map[string]bool)
+	for _, their := range theirElements {
+		seen[their.ID] = true
+		winner := their
+		if our, ok := byID[their.ID]; ok && newer(our, their) {
+			winner = our
+		}
+		merged = append(merged, winner.raw)
+	}
+	for _, our := range ourElements {
+		if !seen[our.ID] {
+			merged = append(merged, our.raw)
+		}
+	}
+	elementsJSON, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	ourFields = set(ourFields, "elements", elementsJSON)
+
+	// images pasted on either side
+	files, err := mergeFiles(lookup(ourFields, "files"), lookup(theirFields, "files"))
+	if err != nil {
+		return "", err
+	}
+	if files != nil {
+		ourFields = set(ourFields, "files", files)
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, f := range ourFields {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(f.value)
+	}
+	out.WriteByte('}')
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, out.Bytes(), "", "  "); err != nil {
+		return "", err
+	}
+	return indented.String(), nil
+}
+
+func newer(a, b element) bool {
+	if a.Version != b.Version {
+		return a.Version > b.Version
+	}
+	return a.VersionNonce < b.VersionNonce
+}
+
+// objectFields reads the members of a JSON object in the order they appear.
+func objectFields(data []byte) ([]field, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("invalid drawing: %w", err)
+	} else if tok != json.Delim('{') {
+		return nil, errors.New("invalid drawing: the scene is not an object")
+	}
+	var fields []field
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid drawing: %w", err)
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid drawing: %w", err)
+		}
+		fields = append(fields, field{key: tok.(string), value: value})
+	}
+	return fields, nil
+}
+
+func lookup(fields []field, key string) json.RawMessage {
+	for _, f := range fields {
+		if f.key == key {
+			return f.value
+		}
+	}
+	return nil
+}
+
+func set(fields []field, key string, value json.RawMessage) []field {
+	for i := range fields {
+		if fields[i].key == key {
+			fields[i].value = value
+			return fields
+		}
+	}
+	return append(fields, field{key: key, value: value})
+}
+
+func elements(data json.RawMessage) ([]element, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid drawing elements: %w", err)
+	}
+	list := make([]element, len(raw))
+	for i, r := range raw {
+		if err := json.Unmarshal(r, &list[i]); err != nil {
+			return nil, fmt.Errorf("invalid drawing element: %w", err)
+		}
+		list[i].raw = r
+	}
+	return list, nil
+}
+
+func mergeFiles(ours, theirs json.RawMessage) (json.RawMessage, error) {
+	if theirs == nil {
+		return nil, nil
+	}
+	files := map[string]json.RawMessage{}
+	if err := json.Unmarshal(theirs, &files); err != nil {
+		return nil, fmt.Errorf("invalid drawing files: %w", err)
+	}
+	if ours != nil {
+		var ourFiles map[string]json.RawMessage
+		if err := json.Unmarshal(ours, &ourFiles); err != nil {
+			return nil, fmt.Errorf("invalid drawing files: %w", err)
+		}
+		for id, file := range ourFiles {
+			files[id] = file
+		}
+	}
+	return json.Marshal(files)
+}
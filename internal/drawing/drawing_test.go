@@ -0,0 +1,144 @@
+package drawing
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScene(t *testing.T) {
+	scene := `{"type":"excalidraw","elements":[{"id":"x","version":3,"text":"héllo"}]}`
+	compressed := "N4IgLgngDgpiBcIYA8DGBDANgSwCYCd0B3EAGiUxgFsYA7MAZwQG1Q8ERkyQA3GfBtgD2tBAGZyYFGA4ALAJeZMQkAF8AuqqA==="
+	tests := []struct {
+		name, location, content, want string
+	}{
+		{"plain file", "a.excalidraw", scene, scene},
+		{"empty file", "a.excalidraw", " \n", EmptyScene},
+		{"note", "a.excalidraw.md", "# Text Elements\nhi\n%%\n# Drawing\n```json\n" + scene + "\n```\n%%\n", scene},
+		// the plugin wraps compressed scenes over several lines
+		{"compressed note", "a.excalidraw.md", "# Drawing\n```compressed-json\n" + compressed[:40] + "\n" + compressed[40:] + "\n```\n", scene},
+		{"note without scene", "a.excalidraw.md", "just text", EmptyScene},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Scene(tt.location, tt.content)
+			if err != nil || got != tt.want {
+				t.Errorf("Scene = %q, %v", got, err)
+			}
+		})
+	}
+
+	if _, err := Scene("a.excalidraw.md", "```compressed-json\n!!\n```"); err == nil {
+		t.Error("a corrupt scene was read")
+	}
+}
+
+func TestWithScene(t *testing.T) {
+	note := "---\nexcalidraw-plugin: parsed\n---\n# Text Elements\nhi\n%%\n# Drawing\n```compressed-json\nIZA=\n```\n%%\n"
+	got := WithScene("a.excalidraw.md", note, "{}")
+	want := "---\nexcalidraw-plugin: parsed\n---\n# Text Elements\nhi\n%%\n# Drawing\n```json\n{}\n```\n%%\n"
+	if got != want {
+		t.Errorf("WithScene = %q", got)
+	}
+
+	got = WithScene("a.excalidraw.md", "just text\n", "{}")
+	if scene, _ := Scene("a.excalidraw.md", got); !strings.HasPrefix(got, "just text\n") || scene != "{}" {
+		t.Errorf("WithScene = %q", got)
+	}
+	if got := WithScene("a.excalidraw", "old", "{}"); got != "{}" {
+		t.Errorf("WithScene = %q", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ours := `{"type":"excalidraw","elements":[
+		{"id":"a","version":2,"versionNonce":5,"x":10},
+		{"id":"b","version":1,"versionNonce":5},
+		{"id":"tie","version":1,"versionNonce":9,"side":"ours"},
+		{"id":"c","version":1,"versionNonce":1}
+	],"appState":{"bg":"ours"},"files":{"f1":{"id":"f1"}}}`
+	theirs := `{"type":"excalidraw","elements":[
+		{"id":"d","version":1,"versionNonce":1},
+		{"id":"tie","version":1,"versionNonce":3,"side":"theirs"},
+		{"id":"b","version":2,"versionNonce":9,"isDeleted":true},
+		{"id":"a","version":1,"versionNonce":5,"x":0}
+	],"appState":{"bg":"theirs"},"files":{"f2":{"id":"f2"}}}`
+
+	merged, err := Merge("a.excalidraw", ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scene struct {
+		Elements []map[string]interface{}
+		AppState map[string]string
+		Files    map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(merged), &scene); err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	byID := make(map[string]map[string]interface{})
+	for _, el := range scene.Elements {
+		order = append(order, el["id"].(string))
+		byID[el["id"].(string)] = el
+	}
+	// theirs gives the order, elements only ours has go last
+	if want := []string{"d", "tie", "b", "a", "c"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("elements %v, want %v", order, want)
+	}
+	if byID["a"]["x"] != 10.0 {
+		t.Error("the newer edit of ours was lost")
+	}
+	if byID["b"]["isDeleted"] != true {
+		t.Error("the deletion of theirs was lost")
+	}
+	if byID["tie"]["side"] != "theirs" {
+		t.Error("a tie did not go to the lower versionNonce")
+	}
+	if scene.AppState["bg"] != "ours" {
+		t.Errorf("app state %v, want ours", scene.AppState)
+	}
+	if len(scene.Files) != 2 {
+		t.Errorf("files %v, want both", scene.Files)
+	}
+}
+
+func TestMergeNote(t *testing.T) {
+	note := func(text, scene string) string {
+		return "---\nexcalidraw-plugin: parsed\n---\n# Text Elements\n" + text + "\n%%\n# Drawing\n```json\n" + scene + "\n```\n%%\n"
+	}
+	ours := note("ours", `{"elements":[{"id":"a","version":1}]}`)
+	theirs := note("theirs", `{"elements":[{"id":"b","version":1}]}`)
+
+	merged, err := Merge("a.excalidraw.md", ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(merged, "# Text Elements\nours\n") || !strings.HasSuffix(merged, "```\n%%\n") {
+		t.Errorf("the note around the scene changed:\n%s", merged)
+	}
+	scene, _ := Scene("a.excalidraw.md", merged)
+	if !strings.Contains(scene, `"a"`) || !strings.Contains(scene, `"b"`) {
+		t.Errorf("merged scene %s", scene)
+	}
+}
+
+func TestMergeInvalid(t *testing.T) {
+	for _, theirs := range []string{`[]`, `{"elements":{}}`, `{"elements":[],"files":[]}`} {
+		if _, err := Merge("a.excalidraw", `{"elements":[]}`, theirs); err == nil {
+			t.Errorf("merged with %s", theirs)
+		}
+	}
+}
+
+func TestIsDrawing(t *testing.T) {
+	for location, want := range map[string]bool{
+		"a.excalidraw": true, "dir/A.Excalidraw.md": true, "a.md": false, "excalidraw.png": false,
+	} {
+		if IsDrawing(location) != want {
+			t.Errorf("IsDrawing(%q) = %v", location, !want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package drawing
+
+import (
+	"errors"
+	"unicode/utf16"
+)
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+var errCorrupt = errors.New("corrupt compressed data")
+
+// decompressFromBase64 undoes LZString.compressToBase64, which the Obsidian
+// plugin compresses scenes with. LZString works on UTF-16 code units.
+func decompressFromBase64(input string) (string, error) {
+	if input == "" {
+		return "", nil
+	}
+	values := make([]int, len(input))
+	for i := 0; i < len(input); i++ {
+		v := -1
+		for j := 0; j < len(base64Alphabet); j++ {
+			if base64Alphabet[j] == input[i] {
+				v = j
+				break
+			}
+		}
+		if v < 0 {
+			return "", errCorrupt
+		}
+		values[i] = v
+	}
+
+	r := &bitReader{values: values, val: values[0], position: 32, index: 1}
+	dictionary := [][]uint16{{0}, {1}, {2}}
+	enlargeIn, numBits := 4, 3
+
+	var w []uint16
+	switch r.read(2) {
+	case 0:
+		w = []uint16{uint16(r.read(8))}
+	case 1:
+		w = []uint16{uint16(r.read(16))}
+	default:
+		return "", nil
+	}
+	dictionary = append(dictionary, w)
+	result := append([]uint16{}, w...)
+
+	for {
+		if r.index > len(values) {
+			return "", errCorrupt
+		}
+		c := r.read(numBits)
+		switch c {
+		case 0, 1:
+			bits := 8
+			if c == 1 {
+				bits = 16
+			}
+			dictionary = append(dictionary, []uint16{uint16(r.read(bits))})
+			c = len(dictionary) - 1
+			enlargeIn--
+		case 2:
+			return string(utf16.Decode(result)), nil
+		}
+		if enlargeIn == 0 {
+			enlargeIn = 1 << numBits
+			numBits++
+		}
+
+		var entry []uint16
+		switch {
+		case c < len(dictionary):
+			entry = dictionary[c]
+		case c == len(dictionary):
+			entry = append(append([]uint16{}, w...), w[0])
+		default:
+			return "", errCorrupt
+		}
+		result = append(result, entry...)
+		dictionary = append(dictionary, append(append([]uint16{}, w...), entry[0]))
+		enlargeIn--
+		w = entry
+		if enlargeIn == 0 {
+			enlargeIn = 1 << numBits
+			numBits++
+		}
+	}
+}
+
+// bitReader hands out the bits of 6 bit base64 values, lowest bit of the
+// result first.
+type bitReader struct {
+	values   []int
+	val      int
+	position int
+	index    int
+}
+
+func (r *bitReader) read(n int) int {
+	bits := 0
+	for power := 1; power != 1<<n; power <<= 1 {
+		if r.val&r.position > 0 {
+			bits |= power
+		}
+		r.position >>= 1
+		if r.position == 0 {
+			r.position = 32
+			if r.index < len(r.values) {
+				r.val = r.values[r.index]
+			} else {
+				r.val = 0
+			}
+			r.index++
+		}
+	}
+	return bits
+}
@@ -0,0 +1,33 @@
+package drawing
+
+import "testing"
+
+// compressed with LZString.compressToBase64
+func TestDecompressFromBase64(t *testing.T) {
+	tests := []struct {
+		compressed, want string
+	}{
+		{"", ""},
+		{"Q===", ""},
+		{"IZA=", "a"},
+		{"BYUwNmD2Q===", "hello"},
+		{"IYI17SKA", "abababababababab"},
+		{"D8Ow9wxgHwJglwAkMjkDC8G4AD2EBcCmBnLIA===", "ünïcødé ✓ 😀 test"},
+		{"N4IgLgngDgpiBcIYA8DGBDANgSwCYCd0B3EAGiUxgFsYA7MAZwQG1Q8ERkyQA3GfBtgD2tBAGZyYFGA4ALAJeZMQkAF8AuqqA===",
+			`{"type":"excalidraw","elements":[{"id":"x","version":3,"text":"héllo"}]}`},
+	}
+	for _, tt := range tests {
+		got, err := decompressFromBase64(tt.compressed)
+		if err != nil || got != tt.want {
+			t.Errorf("decompressFromBase64(%q) = %q, %v, want %q", tt.compressed, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	for _, input := range []string{"not base64!", "BYUwNmD2"} {
+		if got, err := decompressFromBase64(input); err == nil {
+			t.Errorf("decompressFromBase64(%q) = %q, want an error", input, got)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// contentPrefix marks a value as sealed, once a vault has a key anything
+	// without it is refused
+	contentPrefix = "e2e1:"
+	pathPrefix    = "e2ep1:"
+	checkText     = "sync key check v1"
+	keySize       = 32
+)
+
+var (
+	ErrWrongPassphrase = errors.New("wrong passphrase for vault key")
+	ErrUnknownKey      = errors.New("content sealed with an unknown key version")
+	ErrNotSealed       = errors.New("content of an encrypted vault is not sealed")
+)
+
+type Params struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+var DefaultParams = Params{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// The key info comes from the server, which could weaken the key derivation
+// to make guessing the passphrase cheap or inflate it to stall clients.
+// Parameters outside these bounds are refused.
+var (
+	MinParams = Params{Time: 2, Memory: 19 * 1024, Threads: 1}
+	MaxParams = Params{Time: 16, Memory: 1024 * 1024, Threads: 16}
+)
+
+func (p Params) check() error {
+	if p.Time < MinParams.Time || p.Memory < MinParams.Memory || p.Threads < MinParams.Threads {
+		return fmt.Errorf("key derivation parameters %+v are weaker than the minimum %+v", p, MinParams)
+	}
+	if p.Time > MaxParams.Time || p.Memory > MaxParams.Memory || p.Threads > MaxParams.Threads {
+		return fmt.Errorf("key derivation parameters %+v exceed the maximum %+v", p, MaxParams)
+	}
+	return nil
+}
+
+// Keyring holds the current vault key and every previous key it can unwrap,
+// so content sealed before a rotation stays readable.
+type Keyring struct {
+	current      uint32
+	keys         map[uint32][]byte
+	EncryptPaths bool
+}
+
+func DeriveKey(passphrase string, salt []byte, p Params) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, p.Time, p.Memory, p.Threads, keySize)
+}
+
+// NewKeyInfo derives a fresh key from passphrase and returns the KeyInfo to
+// publish along with the unlocked keyring. Passing the previous keyring
+// rotates the key: its keys are wrapped with the new one.
+func NewKeyInfo(passphrase string, prev *Keyring, encryptPaths bool) (*ft.KeyInfo, *Keyring, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+
+	ring := &Keyring{
+		current:      1,
+		keys:         make(map[uint32][]byte),
+		EncryptPaths: encryptPaths,
+	}
+	if prev != nil {
+		ring.current = prev.current + 1
+		for version, key := range prev.keys {
+			ring.keys[version] = key
+		}
+	}
+	ring.keys[ring.current] = DeriveKey(passphrase, salt, DefaultParams)
+
+	check, err := ring.seal(ring.current, []byte(checkText), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var wrapped []byte
+	if prev != nil {
+		wrapped, err = ring.seal(ring.current, encodeKeys(prev.keys), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &ft.KeyInfo{
+		Version:      ring.current,
+		Salt:         salt,
+		KdfTime:      DefaultParams.Time,
+		KdfMemory:    DefaultParams.Memory,
+		KdfThreads:   uint32(DefaultParams.Threads),
+		Check:        check,
+		WrappedKeys:  wrapped,
+		EncryptPaths: encryptPaths,
+	}, ring, nil
+}
+
+// Unlock derives the key described by info and verifies it against the
+// key-check blob, returning ErrWrongPassphrase on mismatch.
+func Unlock(info *ft.KeyInfo, passphrase string) (*Keyring, error) {
+	if info.KdfThreads > uint32(MaxParams.Threads) {
+		return nil, fmt.Errorf("key derivation uses %d threads, at most %d are allowed", info.KdfThreads, MaxParams.Threads)
+	}
+	params := Params{
+		Time:    info.KdfTime,
+		Memory:  info.KdfMemory,
+		Threads: uint8(info.KdfThreads),
+	}
+	if err := params.check(); err != nil {
+		return nil, err
+	}
+	key := DeriveKey(passphrase, info.Salt, params)
+
+	ring := &Keyring{
+		current:      info.Version,
+		keys:         map[uint32][]byte{info.Version: key},
+		EncryptPaths: info.EncryptPaths,
+	}
+
+	check, err := ring.open(info.Check)
+	if err != nil || string(check) != checkText {
+		return nil, ErrWrongPassphrase
+	}
+
+	if len(info.WrappedKeys) > 0 {
+		raw, err := ring.open(info.WrappedKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap previous keys: %w", err)
+		}
+		previous, err := decodeKeys(raw)
+		if err != nil {
+			return nil, err
+		}
+		for version, key := range previous {
+			if version != info.Version {
+				ring.keys[version] = key
+			}
+		}
+	}
+	return ring, nil
+}
+
+func (k *Keyring) Version() uint32 {
+	return k.current
+}
+
+// fileAAD binds a sealed value to the file it belongs to, so the server
+// cannot hand the ciphertext of one file out as another's.
+func fileAAD(vaultID, fileID string) []byte {
+	aad := binary.BigEndian.AppendUint32(nil, uint32(len(vaultID)))
+	aad = append(aad, vaultID...)
+	aad = binary.BigEndian.AppendUint32(aad, uint32(len(fileID)))
+	return append(aad, fileID...)
+}
+
+// Encrypt seals the content of a file with the current key.
+func (k *Keyring) Encrypt(plaintext []byte, vaultID, fileID string) (string, error) {
+	sealed, err := k.sealWith(k.current, plaintext, nil, fileAAD(vaultID, fileID))
+	if err != nil {
+		return "", err
+	}
+	return contentPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens content Encrypt sealed for the same file. Anything else is
+// refused with ErrNotSealed, plaintext would come from the server alone.
+func (k *Keyring) Decrypt(content, vaultID, fileID string) ([]byte, error) {
+	if !IsEncrypted(content) {
+		return nil, ErrNotSealed
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(content, contentPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("malformed ciphertext: %w", err)
+	}
+	return k.openWith(sealed, fileAAD(vaultID, fileID))
+}
+
+// EncryptPath seals the location of a file deterministically so it always
+// maps to the same ciphertext. Paths pass through when EncryptPaths is off.
+func (k *Keyring) EncryptPath(location, vaultID, fileID string) (string, error) {
+	if !k.EncryptPaths {
+		return location, nil
+	}
+	// the nonce covers the file too, GCM must never see one nonce with two
+	// different additional data
+	aad := fileAAD(vaultID, fileID)
+	mac := hmac.New(sha256.New, k.keys[k.current])
+	mac.Write([]byte("path:"))
+	mac.Write(aad)
+	mac.Write([]byte(location))
+	nonce := mac.Sum(nil)[:12]
+
+	sealed, err := k.sealWith(k.current, []byte(location), nonce, aad)
+	if err != nil {
+		return "", err
+	}
+	return pathPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptPath reverses EncryptPath. Plain locations are only accepted from
+// vaults that keep their paths in the clear.
+func (k *Keyring) DecryptPath(location, vaultID, fileID string) (string, error) {
+	if !strings.HasPrefix(location, pathPrefix) {
+		if k.EncryptPaths {
+			return "", ErrNotSealed
+		}
+		return location, nil
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(location, pathPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed path ciphertext: %w", err)
+	}
+	plain, err := k.openWith(sealed, fileAAD(vaultID, fileID))
+	return string(plain), err
+}
+
+func IsEncrypted(content string) bool {
+	return strings.HasPrefix(content, contentPrefix)
+}
+
+// sealed layout: key version (4 bytes) | nonce (12 bytes) | ciphertext
+func (k *Keyring) seal(version uint32, plaintext, nonce []byte) ([]byte, error) {
+	return k.sealWith(version, plaintext, nonce, nil)
+}
+
+// sealWith also authenticates aad, which is not stored and has to be passed
+// to openWith again.
+func (k *Keyring) sealWith(version uint32, plaintext, nonce, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(k.keys[version])
+	if err != nil {
+		return nil, err
+	}
+	if nonce == nil {
+		nonce = make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	header := make([]byte, 4, 4+len(nonce)+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint32(header, version)
+	header = append(header, nonce...)
+	return aead.Seal(header, nonce, plaintext, append(header[:4:4], aad...)), nil
+}
+
+func (k *Keyring) open(sealed []byte) ([]byte, error) {
+	return k.openWith(sealed, nil)
+}
+
+func (k *Keyring) openWith(sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < 4+12 {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	version := binary.BigEndian.Uint32(sealed[:4])
+	key, ok := k.keys[version]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := sealed[4 : 4+aead.NonceSize()]
+	return aead.Open(nil, nonce, sealed[4+aead.NonceSize():], append(sealed[:4:4], aad...))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, ErrUnknownKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encodeKeys(keys map[uint32][]byte) []byte {
+	out := make([]byte, 0, len(keys)*(4+keySize))
+	for version, key := range keys {
+		out = binary.BigEndian.AppendUint32(out, version)
+		out = append(out, key...)
+	}
+	return out
+}
+
+func decodeKeys(raw []byte) (map[uint32][]byte, error) {
+	if len(raw)%(4+keySize) != 0 {
+		return nil, fmt.Errorf("malformed wrapped keys")
+	}
+	keys := make(map[uint32][]byte)
+	for i := 0; i < len(raw); i += 4 + keySize {
+		keys[binary.BigEndian.Uint32(raw[i:i+4])] = append([]byte(nil), raw[i+4:i+4+keySize]...)
+	}
+	return keys, nil
+}
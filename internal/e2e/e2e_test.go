@@ -0,0 +1,158 @@
+package e2e
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"google.golang.org/protobuf/proto"
+)
+
+// newRing creates a key once per test, deriving it is deliberately slow.
+func newRing(t *testing.T, encryptPaths bool) *Keyring {
+	t.Helper()
+	_, ring, err := NewKeyInfo("correct horse", nil, encryptPaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func TestUnlock(t *testing.T) {
+	info, ring, err := NewKeyInfo("correct horse", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := ring.Encrypt([]byte("note"), "vault", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Unlock(info, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+	unlocked, err := Unlock(info, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := unlocked.Decrypt(sealed, "vault", "file")
+	if err != nil || string(plain) != "note" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+}
+
+func TestUnlockBoundsKeyDerivation(t *testing.T) {
+	info, _, err := NewKeyInfo("correct horse", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		change func(*ft.KeyInfo)
+	}{
+		{"one pass", func(info *ft.KeyInfo) { info.KdfTime = 1 }},
+		{"little memory", func(info *ft.KeyInfo) { info.KdfMemory = 1024 }},
+		{"no threads", func(info *ft.KeyInfo) { info.KdfThreads = 0 }},
+		{"too many passes", func(info *ft.KeyInfo) { info.KdfTime = MaxParams.Time + 1 }},
+		{"too much memory", func(info *ft.KeyInfo) { info.KdfMemory = MaxParams.Memory + 1 }},
+		// would wrap around to 1 thread as a uint8
+		{"thread overflow", func(info *ft.KeyInfo) { info.KdfThreads = 257 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := proto.Clone(info).(*ft.KeyInfo)
+			tt.change(changed)
+			if _, err := Unlock(changed, "correct horse"); err == nil || errors.Is(err, ErrWrongPassphrase) {
+				t.Errorf("Unlock = %v, want the parameters refused", err)
+			}
+		})
+	}
+}
+
+func TestRotationKeepsOldKeys(t *testing.T) {
+	_, first, err := NewKeyInfo("first", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := first.Encrypt([]byte("before"), "vault", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _, err := NewKeyInfo("second", first, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != 2 {
+		t.Errorf("version %d after a rotation, want 2", info.Version)
+	}
+
+	ring, err := Unlock(info, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := ring.Decrypt(old, "vault", "file"); err != nil || string(plain) != "before" {
+		t.Errorf("content from before the rotation: %q, %v", plain, err)
+	}
+}
+
+func TestDecryptBindsFile(t *testing.T) {
+	ring := newRing(t, false)
+	sealed, err := ring.Encrypt([]byte("secret"), "vault", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ring.Decrypt(sealed, "vault", "b"); err == nil {
+		t.Error("content of one file opened as another")
+	}
+	if _, err := ring.Decrypt(sealed, "other", "a"); err == nil {
+		t.Error("content of one vault opened in another")
+	}
+	// the length prefixes keep ids from shifting into each other
+	if _, err := ring.Decrypt(sealed, "vaulta", ""); err == nil {
+		t.Error("vault and file ids are ambiguous")
+	}
+	if _, err := ring.Decrypt("plain text", "vault", "a"); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("plaintext: %v, want ErrNotSealed", err)
+	}
+}
+
+func TestEncryptPath(t *testing.T) {
+	ring := newRing(t, true)
+	sealed, err := ring.EncryptPath("notes/a.md", "vault", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "notes") || !strings.HasPrefix(sealed, pathPrefix) {
+		t.Errorf("EncryptPath = %q", sealed)
+	}
+	if again, _ := ring.EncryptPath("notes/a.md", "vault", "a"); again != sealed {
+		t.Error("a path does not always seal the same")
+	}
+	if other, _ := ring.EncryptPath("notes/a.md", "vault", "b"); other == sealed {
+		t.Error("two files share the ciphertext of a path")
+	}
+
+	plain, err := ring.DecryptPath(sealed, "vault", "a")
+	if err != nil || plain != "notes/a.md" {
+		t.Errorf("DecryptPath = %q, %v", plain, err)
+	}
+	if _, err := ring.DecryptPath(sealed, "vault", "b"); err == nil {
+		t.Error("path of one file opened as another")
+	}
+	if _, err := ring.DecryptPath("injected.md", "vault", "a"); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("plain path: %v, want ErrNotSealed", err)
+	}
+}
+
+func TestPlainPaths(t *testing.T) {
+	ring := newRing(t, false)
+	location, err := ring.EncryptPath("notes/a.md", "vault", "a")
+	if err != nil || location != "notes/a.md" {
+		t.Errorf("EncryptPath = %q, %v", location, err)
+	}
+	if location, err = ring.DecryptPath("notes/a.md", "vault", "a"); err != nil || location != "notes/a.md" {
+		t.Errorf("DecryptPath = %q, %v", location, err)
+	}
+}
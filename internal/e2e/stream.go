@@ -0,0 +1,179 @@
+package e2e
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+const (
+	// streamPrefix marks a sealed stream the way contentPrefix marks a value
+	streamPrefix = "e2es1"
+	// StreamChunk is how much plaintext one sealed frame holds
+	StreamChunk = 64 * 1024
+	// frame layout: length (4 bytes) | key version | nonce | ciphertext | tag
+	frameOverhead = 4 + 4 + 12 + 16
+)
+
+var ErrTruncated = errors.New("sealed stream is truncated")
+
+// SealedSize is the most a stream of n plaintext bytes grows to once sealed.
+func SealedSize(n int64) int64 {
+	frames := n/StreamChunk + 2
+	return int64(len(streamPrefix)) + n + frames*frameOverhead
+}
+
+// NewHash returns a MAC keyed with the current vault key. Clients use it in
+// place of a plain checksum so the server cannot confirm guesses about the
+// content of encrypted files.
+func (k *Keyring) NewHash() hash.Hash {
+	mac := hmac.New(sha256.New, k.keys[k.current])
+	mac.Write([]byte("file:"))
+	return mac
+}
+
+// frameAAD binds a frame to its file and its position, so frames cannot be
+// moved between files or reordered, and marks the last one, so a stream
+// cannot be cut short unnoticed.
+func frameAAD(file []byte, index uint64, last bool) []byte {
+	aad := binary.BigEndian.AppendUint64(append([]byte{}, file...), index)
+	if last {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+type sealWriter struct {
+	ring  *Keyring
+	w     io.Writer
+	file  []byte
+	buf   []byte
+	index uint64
+}
+
+// NewSealWriter encrypts everything written to it in StreamChunk frames, so
+// large files never have to be held in memory. Close writes the last frame
+// and has to be called.
+func (k *Keyring) NewSealWriter(w io.Writer, vaultID, fileID string) io.WriteCloser {
+	return &sealWriter{ring: k, w: w, file: fileAAD(vaultID, fileID), buf: make([]byte, 0, StreamChunk)}
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// a full buffer is only sealed once more data arrives, the last
+		// frame is written by Close
+		if len(s.buf) == cap(s.buf) {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[len(s.buf):cap(s.buf)], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (s *sealWriter) Close() error {
+	return s.flush(true)
+}
+
+func (s *sealWriter) flush(last bool) error {
+	if s.index == 0 {
+		if _, err := io.WriteString(s.w, streamPrefix); err != nil {
+			return err
+		}
+	}
+	sealed, err := s.ring.sealWith(s.ring.current, s.buf, nil, frameAAD(s.file, s.index, last))
+	if err != nil {
+		return err
+	}
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(sealed)), uint32(len(sealed)))
+	if _, err := s.w.Write(append(frame, sealed...)); err != nil {
+		return err
+	}
+	s.buf = s.buf[:0]
+	s.index++
+	return nil
+}
+
+type openReader struct {
+	ring  *Keyring
+	r     *bufio.Reader
+	file  []byte
+	plain []byte
+	index uint64
+	done  bool
+	err   error
+}
+
+// NewOpenReader reverses NewSealWriter for the same file. Like Decrypt it
+// refuses streams that were never sealed, reads fail with ErrNotSealed.
+func (k *Keyring) NewOpenReader(r io.Reader, vaultID, fileID string) io.Reader {
+	buffered := bufio.NewReader(r)
+	o := &openReader{ring: k, r: buffered, file: fileAAD(vaultID, fileID)}
+	if prefix, err := buffered.Peek(len(streamPrefix)); err != nil || string(prefix) != streamPrefix {
+		o.err = ErrNotSealed
+		return o
+	}
+	buffered.Discard(len(streamPrefix))
+	return o
+}
+
+// IsSealedStream reports whether data starts like a sealed stream.
+func IsSealedStream(data []byte) bool {
+	return len(data) >= len(streamPrefix) && string(data[:len(streamPrefix)]) == streamPrefix
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	for len(o.plain) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		if err := o.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.plain)
+	o.plain = o.plain[n:]
+	return n, nil
+}
+
+func (o *openReader) next() error {
+	var size [4]byte
+	if _, err := io.ReadFull(o.r, size[:]); err == io.EOF {
+		return ErrTruncated
+	} else if err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length > StreamChunk+frameOverhead {
+		return fmt.Errorf("malformed sealed stream: frame of %d bytes", length)
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(o.r, sealed); err != nil {
+		return ErrTruncated
+	}
+
+	// only the last frame opens with the last flag set
+	plain, err := o.ring.openWith(sealed, frameAAD(o.file, o.index, false))
+	if err != nil {
+		if plain, err = o.ring.openWith(sealed, frameAAD(o.file, o.index, true)); err != nil {
+			return err
+		}
+		o.done = true
+	}
+	o.plain = plain
+	o.index++
+	return nil
+}
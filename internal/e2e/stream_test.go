@@ -0,0 +1,98 @@
+package e2e
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func seal(t *testing.T, ring *Keyring, plain []byte, vaultID, fileID string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := ring.NewSealWriter(&out, vaultID, fileID)
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	ring := newRing(t, false)
+	for _, size := range []int{0, 1, StreamChunk, StreamChunk + 1, 3*StreamChunk + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		sealed := seal(t, ring, plain, "vault", "file")
+		if !IsSealedStream(sealed) {
+			t.Fatalf("%d bytes: not marked as sealed", size)
+		}
+		if int64(len(sealed)) > SealedSize(int64(size)) {
+			t.Errorf("%d bytes sealed to %d, SealedSize says at most %d", size, len(sealed), SealedSize(int64(size)))
+		}
+		got, err := io.ReadAll(ring.NewOpenReader(bytes.NewReader(sealed), "vault", "file"))
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("%d bytes: content changed", size)
+		}
+	}
+}
+
+func TestStreamRefusesTampering(t *testing.T) {
+	ring := newRing(t, false)
+	plain := make([]byte, 2*StreamChunk+5)
+	rand.Read(plain)
+	sealed := seal(t, ring, plain, "vault", "file")
+	frame := len(streamPrefix) + StreamChunk + frameOverhead
+
+	tests := []struct {
+		name   string
+		stream []byte
+		fileID string
+		want   error
+	}{
+		{"other file", sealed, "other", nil},
+		{"cut after a frame", sealed[:len(streamPrefix)+frame], "file", ErrTruncated},
+		{"last frame dropped", sealed[:len(sealed)-(len(sealed)-len(streamPrefix))%frame], "file", nil},
+		{"flipped bit", flip(sealed, len(sealed)-1), "file", nil},
+		{"never sealed", plain, "file", ErrNotSealed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(ring.NewOpenReader(bytes.NewReader(tt.stream), "vault", tt.fileID))
+			if err == nil {
+				t.Fatal("tampered stream opened")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func flip(data []byte, i int) []byte {
+	out := bytes.Clone(data)
+	out[i] ^= 1
+	return out
+}
+
+func TestHashIsKeyed(t *testing.T) {
+	a, b := newRing(t, false), newRing(t, false)
+	sum := func(ring *Keyring) string {
+		h := ring.NewHash()
+		h.Write([]byte("same content"))
+		return string(h.Sum(nil))
+	}
+	if sum(a) == sum(b) {
+		t.Error("two vault keys give the same checksum")
+	}
+	if sum(a) != sum(a) {
+		t.Error("one key gives two checksums")
+	}
+}
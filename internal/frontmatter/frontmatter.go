@@ -0,0 +1,119 @@
+package frontmatter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/itsrobel/sync/internal/links"
+	"gopkg.in/yaml.v3"
+)
+
+// Meta is the metadata of a markdown note.
+type Meta struct {
+	Fields []Field  // front matter, without tags
+	Tags   []string // lowercase, without #, sorted
+}
+
+// Field is one front matter value. Lists become one field per item and
+// nested keys are joined with a dot.
+type Field struct {
+	Key   string // lowercase
+	Value string
+}
+
+// tag characters as Obsidian allows them, a tag needs at least one letter
+var (
+	inlineTag = regexp.MustCompile(`(^|[\s(\[,;])#([\p{L}\p{N}_/-]+)`)
+	hasLetter = regexp.MustCompile(`[^\p{N}]`)
+)
+
+// Parse reads the front matter and the #tags of a note. Broken front matter
+// is ignored like Obsidian does, tags in the body still count.
+func Parse(content string) Meta {
+	var meta Meta
+	tags := make(map[string]bool)
+
+	header, body := split(content)
+	if header != "" {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(header), &node); err == nil && len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode {
+			fields(node.Content[0], "", func(key, value string) {
+				if key == "tags" || key == "tag" {
+					// "tags: a, b" and "tags: a b" are lists too
+					for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+						addTag(tags, tag)
+					}
+					return
+				}
+				meta.Fields = append(meta.Fields, Field{key, value})
+			})
+		}
+	}
+
+	links.Prose(body, func(_ int, _, prose string) {
+		for _, match := range inlineTag.FindAllStringSubmatch(prose, -1) {
+			addTag(tags, match[2])
+		}
+	})
+
+	for tag := range tags {
+		meta.Tags = append(meta.Tags, tag)
+	}
+	sort.Strings(meta.Tags)
+	return meta
+}
+
+// split cuts the front matter off a note. It has to start on the first line
+// and end with a line of --- or ...
+func split(content string) (string, string) {
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		if rest, ok = strings.CutPrefix(content, "---\r\n"); !ok {
+			return "", content
+		}
+	}
+	offset := 0
+	for _, line := range strings.SplitAfter(rest, "\n") {
+		switch strings.TrimRight(line, "\r\n") {
+		case "---", "...":
+			return rest[:offset], rest[offset+len(line):]
+		}
+		offset += len(line)
+	}
+	return "", content
+}
+
+func fields(node *yaml.Node, prefix string, fn func(key, value string)) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := strings.ToLower(node.Content[i].Value)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			fields(node.Content[i+1], key, fn)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			fields(item, prefix, fn)
+		}
+	case yaml.AliasNode:
+		// only plain values are taken over, expanding aliased lists and maps
+		// lets a few bytes of front matter grow without bound
+		if node.Alias != nil && node.Alias.Kind == yaml.ScalarNode && node.Alias.Tag != "!!null" {
+			fn(prefix, node.Alias.Value)
+		}
+	case yaml.ScalarNode:
+		if node.Tag != "!!null" {
+			fn(prefix, node.Value)
+		}
+	}
+}
+
+func addTag(tags map[string]bool, tag string) {
+	tag = strings.ToLower(strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "#"), "/"))
+	if tag != "" && hasLetter.MatchString(tag) {
+		tags[tag] = true
+	}
+}
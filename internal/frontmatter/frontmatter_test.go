@@ -0,0 +1,74 @@
+package frontmatter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	content := `---
+title: Trip
+Tags: [Travel, "#2024/summer"]
+people:
+  - Ann
+  - Bob
+place:
+  city: Oslo
+empty:
+---
+Packing #list and #Travel, not #123 or ` + "`#code`" + `.
+` + "```\n#fenced\n```\n"
+
+	meta := Parse(content)
+	wantTags := []string{"2024/summer", "list", "travel"}
+	if !reflect.DeepEqual(meta.Tags, wantTags) {
+		t.Errorf("Tags = %q, want %q", meta.Tags, wantTags)
+	}
+	wantFields := []Field{{"title", "Trip"}, {"people", "Ann"}, {"people", "Bob"}, {"place.city", "Oslo"}}
+	if !reflect.DeepEqual(meta.Fields, wantFields) {
+		t.Errorf("Fields = %+v, want %+v", meta.Fields, wantFields)
+	}
+}
+
+func TestParseTagString(t *testing.T) {
+	meta := Parse("---\ntags: a, b c\n---\n")
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(meta.Tags, want) {
+		t.Errorf("Tags = %q", meta.Tags)
+	}
+}
+
+func TestParseBrokenHeader(t *testing.T) {
+	meta := Parse("---\ntitle: [unclosed\n---\n#body")
+	if len(meta.Fields) != 0 || !reflect.DeepEqual(meta.Tags, []string{"body"}) {
+		t.Errorf("Parse = %+v", meta)
+	}
+	// without a closing line it is not front matter at all
+	meta = Parse("---\ntitle: x\n#open")
+	if len(meta.Fields) != 0 || !reflect.DeepEqual(meta.Tags, []string{"open"}) {
+		t.Errorf("Parse = %+v", meta)
+	}
+}
+
+func TestParseDoesNotExpandAliases(t *testing.T) {
+	// every level doubles the list, expanding it would give 2^20 fields
+	var b strings.Builder
+	b.WriteString("---\na0: &a0 [x, x]\n")
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&b, "a%d: &a%d [*a%d, *a%d]\n", i, i, i-1, i-1)
+	}
+	b.WriteString("name: &n plain\nsame: *n\n---\n")
+
+	meta := Parse(b.String())
+	if len(meta.Fields) > 10 {
+		t.Fatalf("%d fields from aliased lists", len(meta.Fields))
+	}
+	found := false
+	for _, field := range meta.Fields {
+		found = found || field == Field{"same", "plain"}
+	}
+	if !found {
+		t.Errorf("an alias of a plain value was dropped: %+v", meta.Fields)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/drawing"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/templates"
+)
+
+type drawRequest struct {
+	ID    string `json:"id"`
+	Base  string `json:"base"`
+	Scene string `json:"scene"`
+}
+
+// drawResponse carries the scene as stored, it differs from the one sent
+// when the server merged it with a newer version.
+type drawResponse struct {
+	editResponse
+	Scene string `json:"scene,omitempty"`
+}
+
+// HandleDraw serves /draw?id=<file id> with the drawing loaded in
+// Excalidraw, a POST saves it.
+func (h *Handlers) HandleDraw(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		h.saveDrawing(w, r)
+		return
+	}
+	session := webSession(w, r)
+
+	file, err := h.download(r.Context(), r.URL.Query().Get("id"), "")
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to download file", http.StatusBadGateway)
+		return
+	}
+	if !drawing.IsDrawing(file.Location) || file.Blob {
+		http.Error(w, "Only Excalidraw drawings can be drawn on", http.StatusBadRequest)
+		return
+	}
+	if encrypted, err := h.encrypted(r.Context()); err != nil {
+		http.Error(w, "Failed to check the vault key", http.StatusBadGateway)
+		return
+	} else if encrypted {
+		http.Error(w, "This vault is encrypted, draw on a device", http.StatusConflict)
+		return
+	}
+	scene, err := drawing.Scene(file.Location, string(file.Content))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	templates.Excalidraw(h.vault, session, file, scene).Render(r.Context(), w)
+}
+
+// saveDrawing puts the scene into the version the drawing was loaded at, so
+// the text of an .excalidraw.md note is kept, and saves it like an edit.
+func (h *Handlers) saveDrawing(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(webSessionCookie)
+	if err != nil || cookie.Value == "" {
+		writeJSON(w, http.StatusForbidden, drawResponse{editResponse: editResponse{Error: "Open the drawing before saving"}})
+		return
+	}
+	var edit drawRequest
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil || !json.Valid([]byte(edit.Scene)) {
+		writeJSON(w, http.StatusBadRequest, drawResponse{editResponse: editResponse{Error: "Failed to parse the drawing"}})
+		return
+	}
+	base, err := h.download(r.Context(), edit.ID, edit.Base)
+	if err != nil {
+		writeJSON(w, httpStatus(err), drawResponse{editResponse: editResponse{Error: connectMessage(err)}})
+		return
+	}
+
+	resp, err := h.greetClient.SaveEdit(r.Context(), connect.NewRequest(&filetransfer.EditRequest{
+		VaultId:       h.vault,
+		FileId:        edit.ID,
+		BaseVersionId: edit.Base,
+		Content:       drawing.WithScene(base.Location, string(base.Content), edit.Scene),
+		Session:       cookie.Value,
+	}))
+	if err != nil {
+		writeJSON(w, httpStatus(err), drawResponse{editResponse: editResponse{Error: connectMessage(err)}})
+		return
+	}
+	saved := drawResponse{editResponse: editResponse{
+		Version:   resp.Msg.Id,
+		Timestamp: resp.Msg.Timestamp.AsTime().Format(time.RFC3339),
+	}}
+	if stored, err := h.download(r.Context(), edit.ID, resp.Msg.Id); err == nil {
+		saved.Scene, _ = drawing.Scene(stored.Location, string(stored.Content))
+	}
+	writeJSON(w, http.StatusOK, saved)
+}
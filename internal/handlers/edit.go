@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/templates"
+)
+
+const webSessionCookie = "web_session"
+
+// webSession names the browser in the versions it saves, the name is kept in
+// a cookie.
+func webSession(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(webSessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	session := hex.EncodeToString(id)
+	http.SetCookie(w, &http.Cookie{
+		Name:     webSessionCookie,
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return session
+}
+
+type editRequest struct {
+	ID      string `json:"id"`
+	Base    string `json:"base"`
+	Content string `json:"content"`
+}
+
+type editResponse struct {
+	Version   string `json:"version,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HandleEditor serves /edit?id=<file id> with the note loaded in the
+// editor, a POST saves it.
+func (h *Handlers) HandleEditor(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		h.saveEdit(w, r)
+		return
+	}
+	session := webSession(w, r)
+
+	file, err := h.download(r.Context(), r.URL.Query().Get("id"), "")
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to download file", http.StatusBadGateway)
+		return
+	}
+	if file.Blob || !strings.HasSuffix(file.Location, ".md") {
+		http.Error(w, "Only notes can be edited", http.StatusBadRequest)
+		return
+	}
+	// the content of encrypted vaults is sealed, only devices can edit it
+	if encrypted, err := h.encrypted(r.Context()); err != nil {
+		http.Error(w, "Failed to check the vault key", http.StatusBadGateway)
+		return
+	} else if encrypted {
+		http.Error(w, "This vault is encrypted, edit it on a device", http.StatusConflict)
+		return
+	}
+
+	templates.Editor(h.vault, session, file).Render(r.Context(), w)
+}
+
+// encrypted reports whether the vault has a key, the web app can neither
+// read nor write its content.
+func (h *Handlers) encrypted(ctx context.Context) (bool, error) {
+	_, err := h.greetClient.GetKeyInfo(ctx, connect.NewRequest(&filetransfer.ActionRequest{VaultId: h.vault}))
+	if err == nil {
+		return true, nil
+	} else if connect.CodeOf(err) == connect.CodeNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+// saveEdit needs the session cookie the editor page set, browsers leave the
+// strict cookie out of requests other sites make.
+func (h *Handlers) saveEdit(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(webSessionCookie)
+	if err != nil || cookie.Value == "" {
+		writeJSON(w, http.StatusForbidden, editResponse{Error: "Open the note in the editor before saving"})
+		return
+	}
+	var edit editRequest
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		writeJSON(w, http.StatusBadRequest, editResponse{Error: "Failed to parse the edit"})
+		return
+	}
+
+	resp, err := h.greetClient.SaveEdit(r.Context(), connect.NewRequest(&filetransfer.EditRequest{
+		VaultId:       h.vault,
+		FileId:        edit.ID,
+		BaseVersionId: edit.Base,
+		Content:       edit.Content,
+		Session:       cookie.Value,
+	}))
+	if err != nil {
+		writeJSON(w, httpStatus(err), editResponse{Error: connectMessage(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, editResponse{
+		Version:   resp.Msg.Id,
+		Timestamp: resp.Msg.Timestamp.AsTime().Format(time.RFC3339),
+	})
+}
+
+// httpStatus maps the error of a change the server refused to a response
+// status, a conflict with a newer version is 409.
+func httpStatus(err error) int {
+	switch connect.CodeOf(err) {
+	case connect.CodeAborted:
+		return http.StatusConflict
+	case connect.CodeNotFound:
+		return http.StatusNotFound
+	case connect.CodeInvalidArgument, connect.CodeFailedPrecondition, connect.CodeResourceExhausted:
+		return http.StatusBadRequest
+	case connect.CodePermissionDenied:
+		return http.StatusForbidden
+	}
+	return http.StatusBadGateway
+}
+
+// connectMessage is the server's own message, without the code prefix.
+func connectMessage(err error) string {
+	if connectErr, ok := err.(*connect.Error); ok {
+		return connectErr.Message()
+	}
+	return err.Error()
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+
+	"connectrpc.com/connect"
+)
+
+// change is the data of a change event, Mine is set when the browser's own
+// session saved it.
+type change struct {
+	FileID   string `json:"file_id"`
+	Location string `json:"location"`
+	Mine     bool   `json:"mine"`
+}
+
+// HandleEvents serves /events, a stream of server-sent events with a change
+// event whenever a file of the vault is stored. The open note and folder
+// pages listen to it to refresh themselves.
+func (h *Handlers) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	// versions saved by the editor are named after the session
+	client := "web/" + webSession(w, r)
+
+	stream, err := h.greetClient.WatchChanges(r.Context(), connect.NewRequest(&filetransfer.ActionRequest{VaultId: h.vault}))
+	if err != nil {
+		http.Error(w, "Failed to watch the vault", http.StatusBadGateway)
+		return
+	}
+	defer stream.Close()
+	// the server answers with READY once it watches, or with an error
+	if !stream.Receive() {
+		http.Error(w, "Failed to watch the vault", httpStatus(stream.Err()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	for stream.Receive() {
+		msg := stream.Msg()
+		if msg.Type != filetransfer.ControlMessage_NEW_FILE {
+			continue
+		}
+		data, _ := json.Marshal(change{FileID: msg.FileId, Location: msg.Filename, Mine: msg.SessionId == client})
+		if _, err := fmt.Fprintf(w, "event: change\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"connectrpc.com/connect"
+)
+
+// HandleExport serves /export?path=<folder> as a zip of the folder, the whole
+// vault without a path. &at=<time> exports the files as they were then.
+func (h *Handlers) HandleExport(w http.ResponseWriter, r *http.Request) {
+	folder := strings.Trim(r.URL.Query().Get("path"), "/")
+	req := &filetransfer.ExportRequest{VaultId: h.vault, Folder: folder}
+	name := h.vault
+	if folder != "" {
+		name = path.Base(folder)
+	}
+	if value := r.URL.Query().Get("at"); value != "" {
+		at, err := parseTime(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.At = timestamppb.New(at)
+		name += "-" + at.Format("20060102-1504")
+	}
+
+	stream, err := h.greetClient.ExportArchive(r.Context(), connect.NewRequest(req))
+	if err != nil {
+		http.Error(w, "Failed to export", http.StatusBadGateway)
+		return
+	}
+	defer stream.Close()
+	// errors arrive before the first chunk, once it is sent the status is
+	// already out
+	if !stream.Receive() {
+		err := stream.Err()
+		if err == nil {
+			err = fmt.Errorf("the server sent no archive")
+		}
+		http.Error(w, connectMessage(err), httpStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+	for {
+		if _, err := w.Write(stream.Msg().Data); err != nil {
+			return
+		}
+		if !stream.Receive() {
+			break
+		}
+	}
+	if err := stream.Err(); err != nil {
+		// the archive is cut short, the browser reports the download as failed
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// parseTime reads the times of query parameters, a datetime-local input
+// sends the second layout.
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time, use 2006-01-02 or 2006-01-02T15:04", value)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/drawing"
+	"github.com/itsrobel/sync/internal/links"
+	"github.com/itsrobel/sync/internal/render"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/templates"
+)
+
+// HandleFiles serves /files?path=<folder>, one level of the vault's folder
+// tree. Index shows the root.
+func (h *Handlers) HandleFiles(w http.ResponseWriter, r *http.Request) {
+	folder := strings.Trim(path.Clean("/"+r.URL.Query().Get("path")), "/")
+	resp, err := h.greetClient.RetrieveListOfFiles(r.Context(), connect.NewRequest(&filetransfer.ActionRequest{VaultId: h.vault}))
+	if err != nil {
+		http.Error(w, "Failed to list files", http.StatusBadGateway)
+		return
+	}
+	listing, ok := listFolder(h.vault, folder, resp.Msg.Files)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	// the upload form posts with the session cookie
+	webSession(w, r)
+	templates.FileBrowser(listing).Render(r.Context(), w)
+}
+
+// listFolder picks the files directly inside folder and sums up the folders
+// below it. It reports false when nothing is stored under folder.
+func listFolder(vault, folder string, files []*filetransfer.File) (templates.Listing, bool) {
+	listing := templates.Listing{Vault: vault, Path: folder}
+	prefix := ""
+	if folder != "" {
+		prefix = folder + "/"
+	}
+
+	found := folder == ""
+	folders := map[string]*templates.FolderEntry{}
+	for _, file := range files {
+		if !file.Active || !strings.HasPrefix(file.Location, prefix) {
+			continue
+		}
+		found = true
+		rest := strings.TrimPrefix(file.Location, prefix)
+		name, _, nested := strings.Cut(rest, "/")
+		if !nested {
+			listing.Files = append(listing.Files, file)
+			continue
+		}
+		entry := folders[name]
+		if entry == nil {
+			entry = &templates.FolderEntry{Name: name, Path: prefix + name}
+			folders[name] = entry
+		}
+		entry.Files++
+		entry.Size += templates.FileSize(file)
+		if file.ModifiedAt != nil && file.ModifiedAt.AsTime().After(entry.ModifiedAt) {
+			entry.ModifiedAt = file.ModifiedAt.AsTime()
+		}
+	}
+
+	for _, entry := range folders {
+		listing.Folders = append(listing.Folders, *entry)
+	}
+	sort.Slice(listing.Folders, func(i, j int) bool { return listing.Folders[i].Name < listing.Folders[j].Name })
+	sort.Slice(listing.Files, func(i, j int) bool { return listing.Files[i].Location < listing.Files[j].Location })
+	return listing, found
+}
+
+// download reads a version of a file from the server, the latest when
+// versionID is empty. The first message carries the metadata, its content is
+// replaced by the whole file.
+func (h *Handlers) download(ctx context.Context, fileID, versionID string) (*filetransfer.FileVersionData, error) {
+	stream, err := h.greetClient.DownloadFile(ctx, connect.NewRequest(&filetransfer.FileRequest{
+		FileId:    fileID,
+		VaultId:   h.vault,
+		VersionId: versionID,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var meta *filetransfer.FileVersionData
+	var content bytes.Buffer
+	for stream.Receive() {
+		msg := stream.Msg()
+		if meta == nil {
+			meta = msg
+		}
+		content.Write(msg.Content)
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	meta.Content = content.Bytes()
+	return meta, nil
+}
+
+// HandleNote serves /note?id=<file id>, notes are rendered and other files
+// are sent as they are. &version=<version id> picks an older version.
+func (h *Handlers) HandleNote(w http.ResponseWriter, r *http.Request) {
+	file, err := h.download(r.Context(), r.URL.Query().Get("id"), r.URL.Query().Get("version"))
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to download file", http.StatusBadGateway)
+		return
+	}
+
+	// the latest version of a drawing opens in Excalidraw
+	if !file.Blob && drawing.IsDrawing(file.Location) && r.URL.Query().Get("version") == "" {
+		http.Redirect(w, r, "/draw?id="+url.QueryEscape(file.FileId), http.StatusSeeOther)
+		return
+	}
+	if !file.Blob && strings.HasSuffix(file.Location, ".md") {
+		opts, err := h.noteOptions(r.Context(), file.Location)
+		if err != nil {
+			http.Error(w, "Failed to list files", http.StatusBadGateway)
+			return
+		}
+		html, err := render.Note(string(file.Content), opts)
+		if err != nil {
+			http.Error(w, "Failed to render note", http.StatusInternalServerError)
+			return
+		}
+		templates.Note(h.vault, file, html).Render(r.Context(), w)
+		return
+	}
+	serveRaw(w, file)
+}
+
+// noteOptions points the links of a note at this app, attachments are
+// served by HandleNote as well.
+func (h *Handlers) noteOptions(ctx context.Context, location string) (render.Options, error) {
+	resp, err := h.greetClient.RetrieveListOfFiles(ctx, connect.NewRequest(&filetransfer.ActionRequest{VaultId: h.vault}))
+	if err != nil {
+		return render.Options{}, err
+	}
+	ids := map[string]string{}
+	var locations []string
+	for _, file := range resp.Msg.Files {
+		if file.Active {
+			ids[file.Location] = file.ID
+			locations = append(locations, file.Location)
+		}
+	}
+
+	fileURL := func(location string) (string, bool) {
+		id, ok := ids[location]
+		return "/note?id=" + url.QueryEscape(id), ok
+	}
+	return render.Options{
+		Location: location,
+		File:     fileURL,
+		Link: func(target string) (string, bool) {
+			location, ok := links.Resolve(target, locations)
+			if !ok {
+				return "", false
+			}
+			return fileURL(location)
+		},
+	}, nil
+}
+
+// serveRaw sends an attachment as it is. Scripts in it, an svg for example,
+// must not run with the app's origin.
+func serveRaw(w http.ResponseWriter, file *filetransfer.FileVersionData) {
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if kind := mime.TypeByExtension(path.Ext(file.Location)); kind != "" {
+		w.Header().Set("Content-Type", kind)
+	}
+	w.Write(file.Content)
+}
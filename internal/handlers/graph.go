@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/templates"
+)
+
+type graphError struct {
+	Error string `json:"error"`
+}
+
+// HandleGraph serves /graph, the notes of the vault drawn with the links
+// between them. ?folder= and ?tag= narrow it down.
+func (h *Handlers) HandleGraph(w http.ResponseWriter, r *http.Request) {
+	webSession(w, r)
+	folder, tag := graphFilters(r)
+	graph, err := h.graph(r.Context(), folder, tag)
+	if err != nil {
+		http.Error(w, connectMessage(err), httpStatus(err))
+		return
+	}
+	templates.GraphPage(h.vault, folder, tag, graph).Render(r.Context(), w)
+}
+
+// HandleGraphJSON serves /graph.json, the graph of /graph as JSON with the
+// same filters for scripts and outside tools. Like the pages it is served
+// without CORS, other sites cannot read it through a visitor's browser.
+func (h *Handlers) HandleGraphJSON(w http.ResponseWriter, r *http.Request) {
+	folder, tag := graphFilters(r)
+	graph, err := h.graph(r.Context(), folder, tag)
+	if err != nil {
+		writeJSON(w, httpStatus(err), graphError{Error: connectMessage(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, graph)
+}
+
+func graphFilters(r *http.Request) (folder, tag string) {
+	query := r.URL.Query()
+	return strings.Trim(query.Get("folder"), "/"), strings.TrimPrefix(query.Get("tag"), "#")
+}
+
+func (h *Handlers) graph(ctx context.Context, folder, tag string) (templates.Graph, error) {
+	resp, err := h.greetClient.GetGraph(ctx, connect.NewRequest(&filetransfer.GraphRequest{
+		VaultId: h.vault,
+		Folder:  folder,
+		Tag:     tag,
+	}))
+	if err != nil {
+		return templates.Graph{}, err
+	}
+
+	// empty lists rather than null, so scripts can iterate them as is
+	graph := templates.Graph{
+		Nodes: make([]templates.GraphNode, len(resp.Msg.Nodes)),
+		Edges: make([]templates.GraphEdge, len(resp.Msg.Edges)),
+		Tags:  append([]string{}, resp.Msg.Tags...),
+	}
+	for i, node := range resp.Msg.Nodes {
+		graph.Nodes[i] = templates.GraphNode{
+			ID:        node.FileId,
+			Location:  node.Location,
+			Tags:      append([]string{}, node.Tags...),
+			Links:     int(node.Links),
+			Backlinks: int(node.Backlinks),
+		}
+	}
+	for i, edge := range resp.Msg.Edges {
+		graph.Edges[i] = templates.GraphEdge{Source: edge.SourceId, Target: edge.TargetId, Count: int(edge.Count)}
+	}
+	return graph, nil
+}
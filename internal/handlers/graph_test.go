@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
+	"github.com/itsrobel/sync/internal/templates"
+)
+
+// graphServer answers GetGraph with a fixed graph, other calls panic.
+type graphServer struct {
+	filetransferconnect.FileServiceClient
+	request *filetransfer.GraphRequest
+}
+
+func (s *graphServer) GetGraph(ctx context.Context, req *connect.Request[filetransfer.GraphRequest]) (*connect.Response[filetransfer.Graph], error) {
+	s.request = req.Msg
+	return connect.NewResponse(&filetransfer.Graph{
+		Nodes: []*filetransfer.GraphNode{{FileId: "a", Location: "notes/a.md", Links: 1}, {FileId: "b", Location: "b.md", Backlinks: 1}},
+		Edges: []*filetransfer.GraphEdge{{SourceId: "a", TargetId: "b", Count: 1}},
+	}), nil
+}
+
+func TestHandleGraphJSON(t *testing.T) {
+	server := &graphServer{}
+	h := NewHandlers(server, "notes")
+
+	// no cookie, outside tools get the graph like any other page
+	w := httptest.NewRecorder()
+	h.HandleGraphJSON(w, httptest.NewRequest(http.MethodGet, "/graph.json?folder=/notes/&tag=%23todo", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if server.request.VaultId != "notes" || server.request.Folder != "notes" || server.request.Tag != "todo" {
+		t.Errorf("asked for %+v", server.request)
+	}
+
+	var graph templates.Graph
+	if err := json.Unmarshal(w.Body.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Nodes) != 2 || len(graph.Edges) != 1 || graph.Tags == nil {
+		t.Errorf("graph = %+v", graph)
+	}
+}
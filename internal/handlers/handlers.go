@@ -3,7 +3,6 @@ package handlers
 import (
 	// "baby-backend/internal/services/apiv1"
 
-	"context"
 	"fmt"
 	"net/http"
 
@@ -16,18 +15,32 @@ import (
 
 type Handlers struct {
 	greetClient filetransferconnect.FileServiceClient
+	vault       string
 }
 
-func NewHandlers(greetClient filetransferconnect.FileServiceClient) *Handlers {
+func NewHandlers(greetClient filetransferconnect.FileServiceClient, vault string) *Handlers {
 	return &Handlers{
 		greetClient: greetClient,
+		vault:       vault,
 	}
 }
 
 func (h *Handlers) Index(w http.ResponseWriter, r *http.Request) {
-	// TODO: make a request to the backend and then pass that into templates.Index
-	component := templates.Index()
-	component.Render(context.Background(), w)
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	req := connect.NewRequest(&filetransfer.ActionRequest{VaultId: h.vault})
+	resp, err := h.greetClient.RetrieveListOfFiles(r.Context(), req)
+	if err != nil {
+		http.Error(w, "Failed to list files", http.StatusBadGateway)
+		return
+	}
+	listing, _ := listFolder(h.vault, "", resp.Msg.Files)
+	webSession(w, r)
+
+	component := templates.Index(listing)
+	component.Render(r.Context(), w)
 }
 
 func (h *Handlers) HandleGreet(w http.ResponseWriter, r *http.Request) {
@@ -51,23 +64,3 @@ func (h *Handlers) HandleGreet(w http.ResponseWriter, r *http.Request) {
 	component := templates.GreetingResponse(resp.Msg.Greeting)
 	component.Render(r.Context(), w)
 }
-
-// func (h *Handlers) HandleFiles(w http.ResponseWriter, r *http.Request) {
-// }
-
-func (h *Handlers) HandleEditor(w http.ResponseWriter, r *http.Request) {
-	req := connect.NewRequest(&filetransfer.ActionRequest{})
-	resp, _ := h.greetClient.RetrieveListOfFiles(r.Context(), req)
-	fmt.Println(resp)
-
-	component := templates.Editor()
-
-	component.Render(context.Background(), w)
-}
-
-func (h *Handlers) HandleDraw(w http.ResponseWriter, r *http.Request) {
-	component := templates.Excalidraw()
-
-	// Render the component
-	component.Render(context.Background(), w)
-}
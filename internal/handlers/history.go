@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/diff"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/templates"
+)
+
+// HandleHistory serves /history?id=<file id>, the versions of a file with
+// ways to compare and restore them.
+func (h *Handlers) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	webSession(w, r)
+	fileID := r.URL.Query().Get("id")
+	versions, ok := h.listVersions(w, r, fileID)
+	if !ok {
+		return
+	}
+	templates.History(h.vault, fileID, versions, "").Render(r.Context(), w)
+}
+
+func (h *Handlers) listVersions(w http.ResponseWriter, r *http.Request, fileID string) ([]*filetransfer.FileVersionData, bool) {
+	resp, err := h.greetClient.ListVersions(r.Context(), connect.NewRequest(&filetransfer.FileRequest{
+		FileId:  fileID,
+		VaultId: h.vault,
+	}))
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		http.NotFound(w, r)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, "Failed to list versions", http.StatusBadGateway)
+		return nil, false
+	}
+	if len(resp.Msg.Versions) == 0 {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return resp.Msg.Versions, true
+}
+
+// HandleDiff serves /diff?id=<file id>&from=<version id>&to=<version id>.
+// to defaults to the latest version and from to the one before to.
+// &view=split shows both versions side by side.
+func (h *Handlers) HandleDiff(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	fileID := query.Get("id")
+	versions, ok := h.listVersions(w, r, fileID)
+	if !ok {
+		return
+	}
+
+	to := len(versions) - 1
+	if id := query.Get("to"); id != "" {
+		if to = versionIndex(versions, id); to < 0 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	from := to - 1
+	if id := query.Get("from"); id != "" {
+		if from = versionIndex(versions, id); from < 0 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if versions[to].Blob || (from >= 0 && versions[from].Blob) {
+		http.Error(w, "Attachments cannot be compared", http.StatusBadRequest)
+		return
+	}
+
+	// the first version is compared with an empty file
+	old := ""
+	if from >= 0 {
+		file, err := h.download(r.Context(), fileID, versions[from].Id)
+		if err != nil {
+			http.Error(w, "Failed to download version", http.StatusBadGateway)
+			return
+		}
+		old = string(file.Content)
+	}
+	file, err := h.download(r.Context(), fileID, versions[to].Id)
+	if err != nil {
+		http.Error(w, "Failed to download version", http.StatusBadGateway)
+		return
+	}
+
+	templates.Diff(templates.DiffPage{
+		Vault:    h.vault,
+		FileID:   fileID,
+		Versions: versions,
+		From:     from,
+		To:       to,
+		Split:    query.Get("view") == "split",
+		Lines:    diff.Lines(old, string(file.Content)),
+	}).Render(r.Context(), w)
+}
+
+func versionIndex(versions []*filetransfer.FileVersionData, id string) int {
+	for i, version := range versions {
+		if version.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// HandleRestore takes the restore form of the history page. Like saving an
+// edit it needs the session cookie, and fails when the file changed after the
+// page was loaded.
+func (h *Handlers) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cookie, err := r.Cookie(webSessionCookie)
+	if err != nil || cookie.Value == "" {
+		http.Error(w, "Open the history before restoring", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	fileID := r.FormValue("id")
+	_, err = h.greetClient.RestoreVersion(r.Context(), connect.NewRequest(&filetransfer.RestoreRequest{
+		VaultId:       h.vault,
+		FileId:        fileID,
+		VersionId:     r.FormValue("version"),
+		BaseVersionId: r.FormValue("base"),
+		Session:       cookie.Value,
+	}))
+	if err != nil {
+		// the reason is shown next to the versions as they are now
+		versions, ok := h.listVersions(w, r, fileID)
+		if !ok {
+			return
+		}
+		w.WriteHeader(httpStatus(err))
+		templates.History(h.vault, fileID, versions, connectMessage(err)).Render(r.Context(), w)
+		return
+	}
+	http.Redirect(w, r, "/history?id="+url.QueryEscape(fileID), http.StatusSeeOther)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/templates"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// searchLimit is how many hits the search page shows.
+const searchLimit = 50
+
+// HandleSearch serves /search?q=<words>, full-text search over the current
+// content of the vault. &folder=, &tag=, &after= and &before= narrow it
+// down. The page searches while typing, htmx then only asks for the results.
+func (h *Handlers) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	webSession(w, r)
+	query := r.URL.Query()
+	form := templates.SearchForm{
+		Query:  strings.TrimSpace(query.Get("q")),
+		Folder: strings.Trim(query.Get("folder"), "/"),
+		Tag:    strings.TrimPrefix(strings.TrimSpace(query.Get("tag")), "#"),
+		After:  query.Get("after"),
+		Before: query.Get("before"),
+	}
+
+	var hits []*filetransfer.SearchHit
+	problem := ""
+	if form.Query != "" {
+		var err error
+		if hits, err = h.search(r.Context(), form); err != nil {
+			problem = connectMessage(err)
+		}
+	}
+
+	// a history restore wants the whole page back
+	if r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-History-Restore-Request") != "true" {
+		templates.SearchResults(form.Query, hits, problem).Render(r.Context(), w)
+		return
+	}
+	templates.Search(h.vault, form, hits, problem).Render(r.Context(), w)
+}
+
+func (h *Handlers) search(ctx context.Context, form templates.SearchForm) ([]*filetransfer.SearchHit, error) {
+	req := &filetransfer.SearchRequest{
+		Query:   form.Query,
+		VaultId: h.vault,
+		Folder:  form.Folder,
+		Tag:     form.Tag,
+		Limit:   searchLimit,
+	}
+	if form.After != "" {
+		after, err := parseTime(form.After)
+		if err != nil {
+			return nil, err
+		}
+		req.ModifiedAfter = timestamppb.New(after)
+	}
+	if form.Before != "" {
+		before, err := parseTime(form.Before)
+		if err != nil {
+			return nil, err
+		}
+		req.ModifiedBefore = timestamppb.New(before)
+	}
+
+	resp, err := h.greetClient.Search(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg.Hits, nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/render"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/templates"
+)
+
+const sharePasswordCookie = "share_password"
+
+// HandleShare serves /s/<token>. It talks to the server with the share token
+// alone, so it works for visitors without a device token.
+func (h *Handlers) HandleShare(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/s/")
+	if token == "" || strings.Contains(token, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	password := ""
+	if cookie, err := r.Cookie(sharePasswordCookie); err == nil {
+		password = cookie.Value
+	}
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		password = r.FormValue("password")
+	}
+
+	resp, err := h.greetClient.OpenShareLink(r.Context(), connect.NewRequest(&filetransfer.ShareRequest{
+		Token:    token,
+		Password: password,
+		Location: r.URL.Query().Get("path"),
+	}))
+	switch connect.CodeOf(err) {
+	case connect.CodeNotFound:
+		http.NotFound(w, r)
+		return
+	case connect.CodePermissionDenied:
+		w.WriteHeader(http.StatusUnauthorized)
+		templates.SharePassword(token, password != "").Render(r.Context(), w)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to open share link", http.StatusBadGateway)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		// keep the password for the rest of the folder, scoped to this link
+		http.SetCookie(w, &http.Cookie{
+			Name:     sharePasswordCookie,
+			Value:    password,
+			Path:     "/s/" + token,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
+		return
+	}
+
+	content := resp.Msg
+	if content.File == nil {
+		templates.ShareFolder(token, content.Link, content.Locations).Render(r.Context(), w)
+		return
+	}
+
+	if !strings.HasSuffix(content.File.Location, ".md") {
+		// downloaded rather than shown, a shared .html or .svg must not
+		// run script on this origin
+		if kind := mime.TypeByExtension(path.Ext(content.File.Location)); kind != "" {
+			w.Header().Set("Content-Type", kind)
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(content.File.Location)}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Write([]byte(content.File.Content))
+		return
+	}
+
+	html, err := render.Markdown(content.File.Content)
+	if err != nil {
+		http.Error(w, "Failed to render note", http.StatusInternalServerError)
+		return
+	}
+	templates.ShareFile(token, content.Link, content.File, html).Render(r.Context(), w)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+	"github.com/itsrobel/sync/internal/drawing"
+	"github.com/itsrobel/sync/internal/links"
+	"github.com/itsrobel/sync/internal/services/filetransfer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// uploadChunkSize matches the chunks the sync client uploads in.
+const uploadChunkSize = 64 * 1024
+
+type uploadResponse struct {
+	Location  string `json:"location,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Unchanged bool   `json:"unchanged,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HandleUpload takes a POST of one file, the form has the folder the file
+// browser shows, the path of the file below it, which keeps the subfolders
+// of a dropped folder, and the file itself. It is uploaded like a device
+// would, as a new version of the file at that location if there is one.
+func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// like saving an edit, the strict cookie keeps other sites out
+	cookie, err := r.Cookie(webSessionCookie)
+	if err != nil || cookie.Value == "" {
+		writeJSON(w, http.StatusForbidden, uploadResponse{Error: "Open the file browser before uploading"})
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeJSON(w, http.StatusBadRequest, uploadResponse{Error: "Failed to parse the upload"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, uploadResponse{Error: "No file in the upload"})
+		return
+	}
+	defer file.Close()
+	location := uploadLocation(r.FormValue("folder"), r.FormValue("path"))
+	if strings.Trim(r.FormValue("path"), "/") == "" || location == "" {
+		writeJSON(w, http.StatusBadRequest, uploadResponse{Error: "The file has no name"})
+		return
+	}
+
+	if encrypted, err := h.encrypted(r.Context()); err != nil {
+		writeJSON(w, http.StatusBadGateway, uploadResponse{Error: "Failed to check the vault key"})
+		return
+	} else if encrypted {
+		writeJSON(w, http.StatusConflict, uploadResponse{Error: "This vault is encrypted, add files on a device"})
+		return
+	}
+
+	size, sum, err := hashUpload(file)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, uploadResponse{Error: "Failed to read the upload"})
+		return
+	}
+	data := &filetransfer.FileVersionData{
+		Id:        uuid.New().String(),
+		Location:  location,
+		FileId:    uuid.New().String(),
+		VaultId:   h.vault,
+		Timestamp: timestamppb.New(time.Now()),
+		Client:    "web/" + cookie.Value,
+		TotalSize: size,
+		Blob:      !links.IsNote(location) && !drawing.IsDrawing(location),
+	}
+	if data.Blob {
+		data.Hash = sum
+	}
+
+	// a file already at the location gets a new version
+	existing, err := h.greetClient.FindFile(r.Context(), connect.NewRequest(&filetransfer.FileLookup{VaultId: h.vault, Location: location}))
+	switch {
+	case err == nil:
+		data.FileId = existing.Msg.ID
+		if unchanged, err := sameContent(existing.Msg, file, sum); err != nil {
+			writeJSON(w, http.StatusBadRequest, uploadResponse{Error: "Failed to read the upload"})
+			return
+		} else if unchanged {
+			writeJSON(w, http.StatusOK, uploadResponse{Location: location, Unchanged: true})
+			return
+		}
+	case connect.CodeOf(err) != connect.CodeNotFound:
+		writeJSON(w, http.StatusBadGateway, uploadResponse{Error: "Failed to look up the file"})
+		return
+	}
+
+	if err := h.upload(r.Context(), data, file); err != nil {
+		writeJSON(w, httpStatus(err), uploadResponse{Location: location, Error: connectMessage(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, uploadResponse{Location: location, Version: data.Id})
+}
+
+// uploadLocation joins the folder and the path of a file below it into a
+// location of the vault, paths cannot climb out of the vault.
+func uploadLocation(folder, file string) string {
+	return strings.TrimPrefix(path.Clean("/"+folder+"/"+file), "/")
+}
+
+func hashUpload(file multipart.File) (int64, string, error) {
+	sum := sha256.New()
+	size, err := io.Copy(sum, file)
+	if err != nil {
+		return 0, "", err
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	return size, hex.EncodeToString(sum.Sum(nil)), err
+}
+
+// sameContent reports whether an upload matches the latest version of a
+// file, which carries the hash of attachments and the content of notes.
+func sameContent(existing *filetransfer.File, file multipart.File, sum string) (bool, error) {
+	if existing.Blob {
+		return existing.Hash == sum, nil
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return false, err
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	return err == nil && string(content) == existing.Content, err
+}
+
+// upload sends the file in chunks that each repeat the metadata, the way the
+// sync client does. An empty file still sends one chunk.
+func (h *Handlers) upload(ctx context.Context, meta *filetransfer.FileVersionData, file io.Reader) error {
+	stream := h.greetClient.SendFileToServer(ctx)
+	buf := make([]byte, uploadChunkSize)
+	var offset int64
+	var sendErr error
+	for {
+		n, err := io.ReadFull(file, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			sendErr = err
+			break
+		}
+		if n > 0 || offset == 0 {
+			chunk := &filetransfer.FileVersionData{
+				Id:        meta.Id,
+				Location:  meta.Location,
+				FileId:    meta.FileId,
+				VaultId:   meta.VaultId,
+				Timestamp: meta.Timestamp,
+				Client:    meta.Client,
+				TotalSize: meta.TotalSize,
+				Blob:      meta.Blob,
+				Hash:      meta.Hash,
+				Content:   buf[:n],
+				Offset:    offset,
+			}
+			// a server that refuses the upload ends the stream, the reason
+			// comes with the response
+			if sendErr = stream.Send(chunk); sendErr != nil {
+				break
+			}
+		}
+		offset += int64(n)
+		if err != nil {
+			break
+		}
+	}
+	if _, err := stream.CloseAndReceive(); err != nil {
+		return err
+	}
+	if sendErr != nil && !errors.Is(sendErr, io.EOF) {
+		return sendErr
+	}
+	return nil
+}
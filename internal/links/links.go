@@ -0,0 +1,184 @@
+package links
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Link is one [[wikilink]] or ![[embed]] in a note.
+type Link struct {
+	Target  string // as written, without heading or alias
+	Heading string // after # (or ^ for block references)
+	Alias   string // after |
+	Embed   bool
+	Line    int // 1-based
+}
+
+var wikilink = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+?)\]\]`)
+
+// Parse finds the wikilinks of a markdown note, skipping fenced code blocks
+// and inline code like Obsidian does.
+func Parse(content string) []Link {
+	var found []Link
+	scan(content, func(line int, text string, match []int) {
+		link := ParseLink(text[match[4]:match[5]])
+		if link.Target == "" && link.Heading == "" {
+			return
+		}
+		link.Embed = match[3] > match[2]
+		link.Line = line
+		found = append(found, link)
+	})
+	return found
+}
+
+// Rewrite replaces link targets in a note. retarget gets every link and
+// returns the new target, headings, aliases and the embed marker are kept as
+// written. It returns the new content and how many links changed.
+func Rewrite(content string, retarget func(Link) (string, bool)) (string, int) {
+	lines := strings.Split(content, "\n")
+	changed := 0
+	// matches are collected per line and applied back to front so earlier
+	// offsets stay valid
+	edits := make(map[int][][]int)
+	targets := make(map[int][]string)
+	scan(content, func(line int, text string, match []int) {
+		link := ParseLink(text[match[4]:match[5]])
+		link.Embed = match[3] > match[2]
+		link.Line = line
+		target, ok := retarget(link)
+		if !ok || target == link.Target {
+			return
+		}
+		inner := text[match[4]:match[5]]
+		end := strings.IndexAny(inner, "#^|")
+		if end < 0 {
+			end = len(inner)
+		}
+		// only the target part of the link is replaced
+		edits[line-1] = append(edits[line-1], []int{match[4], match[4] + end})
+		targets[line-1] = append(targets[line-1], target)
+	})
+
+	for i, spans := range edits {
+		line := lines[i]
+		for j := len(spans) - 1; j >= 0; j-- {
+			line = line[:spans[j][0]] + targets[i][j] + line[spans[j][1]:]
+			changed++
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n"), changed
+}
+
+// scan calls fn with the submatch offsets of every wikilink outside of code.
+// Offsets index into the original line.
+func scan(content string, fn func(line int, text string, match []int)) {
+	Prose(content, func(line int, text, prose string) {
+		for _, match := range wikilink.FindAllStringSubmatchIndex(prose, -1) {
+			fn(line, text, match)
+		}
+	})
+}
+
+// Prose calls fn with every line of a note outside fenced code blocks, as
+// written and with its inline code blanked. Both have the same byte length so
+// offsets carry over. Lines are 1-based.
+func Prose(content string, fn func(line int, text, prose string)) {
+	fence := ""
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		fn(i+1, line, stripInlineCode(line))
+	}
+}
+
+// ParseLink splits what is written between the brackets of a wikilink.
+func ParseLink(inner string) Link {
+	var link Link
+	inner, link.Alias, _ = strings.Cut(inner, "|")
+	if i := strings.IndexAny(inner, "#^"); i >= 0 {
+		link.Heading = inner[i+1:]
+		if inner[i] == '^' {
+			link.Heading = "^" + link.Heading
+		}
+		inner = inner[:i]
+	}
+	link.Target = strings.TrimSpace(inner)
+	link.Alias = strings.TrimSpace(link.Alias)
+	link.Heading = strings.TrimSpace(link.Heading)
+	return link
+}
+
+// stripInlineCode blanks `code spans` keeping the byte length.
+func stripInlineCode(line string) string {
+	if !strings.Contains(line, "`") {
+		return line
+	}
+	out := []byte(line)
+	inCode := false
+	for i := range out {
+		if out[i] == '`' {
+			inCode = !inCode
+			out[i] = ' '
+		} else if inCode {
+			out[i] = ' '
+		}
+	}
+	return string(out)
+}
+
+// Resolve finds the location a link target points to. Targets without an
+// extension refer to markdown notes. A bare name matches a note anywhere in
+// the vault, preferring the shortest path, a path has to match from the
+// vault root. Matching ignores case like Obsidian does on most systems.
+func Resolve(target string, locations []string) (string, bool) {
+	target = strings.TrimPrefix(path.Clean("/"+strings.TrimSpace(target)), "/")
+	if target == "" {
+		return "", false
+	}
+
+	// "v1.2 notes" has no real extension, so the note is tried first
+	candidates := []string{target}
+	if !IsNote(target) {
+		candidates = []string{target + ".md", target}
+	}
+	for _, candidate := range candidates {
+		if location, ok := resolve(candidate, locations); ok {
+			return location, true
+		}
+	}
+	return "", false
+}
+
+func resolve(target string, locations []string) (string, bool) {
+	best := ""
+	for _, location := range locations {
+		switch {
+		case strings.EqualFold(location, target):
+			return location, true
+		case strings.Contains(target, "/"):
+			continue
+		case strings.EqualFold(path.Base(location), target):
+			if best == "" || len(location) < len(best) || (len(location) == len(best) && location < best) {
+				best = location
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// IsNote reports whether a location is parsed for links.
+func IsNote(location string) bool {
+	return strings.EqualFold(path.Ext(location), ".md")
+}
@@ -0,0 +1,77 @@
+package links
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	content := "See [[Note]] and ![[img.png|200]].\n" +
+		"`[[inline code]]` [[other#Heading|shown]]\n" +
+		"```\n[[in a fence]]\n```\n" +
+		"[[#Local]] [[block^abc]] [[]]"
+
+	want := []Link{
+		{Target: "Note", Line: 1},
+		{Target: "img.png", Alias: "200", Embed: true, Line: 1},
+		{Target: "other", Heading: "Heading", Alias: "shown", Line: 2},
+		{Heading: "Local", Line: 6},
+		{Target: "block", Heading: "^abc", Line: 6},
+	}
+	if got := Parse(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	content := "[[old]] [[Old#Top|alias]] ![[old]] [[keep]]\n```\n[[old]]\n```"
+	got, changed := Rewrite(content, func(link Link) (string, bool) {
+		if link.Target == "old" || link.Target == "Old" {
+			return "dir/new", true
+		}
+		return "", false
+	})
+	want := "[[dir/new]] [[dir/new#Top|alias]] ![[dir/new]] [[keep]]\n```\n[[old]]\n```"
+	if got != want || changed != 3 {
+		t.Errorf("Rewrite = %q, %d", got, changed)
+	}
+}
+
+func TestProseKeepsOffsets(t *testing.T) {
+	var lines []int
+	Prose("a `b` c\n~~~\nskipped\n~~~\nd", func(line int, text, prose string) {
+		lines = append(lines, line)
+		if len(text) != len(prose) {
+			t.Errorf("line %d: %q blanked to %q", line, text, prose)
+		}
+		if line == 1 && prose != "a     c" {
+			t.Errorf("prose = %q", prose)
+		}
+	})
+	if !reflect.DeepEqual(lines, []int{1, 5}) {
+		t.Errorf("lines = %v", lines)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	locations := []string{"deep/dir/Note.md", "Note.md", "dir/other.md", "img/photo.png", "v1.2 notes.md"}
+	tests := []struct {
+		target, want string
+	}{
+		{"note", "Note.md"},
+		{"other", "dir/other.md"},
+		{"dir/other", "dir/other.md"},
+		{"/dir/other.md", "dir/other.md"},
+		{"photo.png", "img/photo.png"},
+		{"v1.2 notes", "v1.2 notes.md"},
+		{"missing", ""},
+		{"other/dir", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := Resolve(tt.target, locations)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", tt.target, got, ok, tt.want)
+		}
+	}
+}
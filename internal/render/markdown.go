@@ -0,0 +1,64 @@
+package render
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// raw HTML in notes is dropped, goldmark only passes it through with the
+// unsafe renderer option. It also leaves out javascript: and data: URLs.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+		parser.WithInlineParsers(
+			util.Prioritized(&wikilinkParser{}, 199),
+			util.Prioritized(&mathInlineParser{}, 150),
+		),
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 690)),
+		parser.WithASTTransformers(util.Prioritized(&pathTransformer{}, 100)),
+	),
+	goldmark.WithRendererOptions(renderer.WithNodeRenderers(util.Prioritized(&nodeRenderer{}, 100))),
+)
+
+// Options tie a note to the vault it is stored in. Without them wikilinks
+// are shown as unresolved and relative paths are left alone.
+type Options struct {
+	// Location of the note, relative paths are resolved from its folder
+	Location string
+	// Link resolves the target of a [[wikilink]] to a URL
+	Link func(target string) (string, bool)
+	// File returns the URL a vault location is served at
+	File func(location string) (string, bool)
+}
+
+var optionsKey = parser.NewContextKey()
+
+func options(pc parser.Context) *Options {
+	if opts, ok := pc.Get(optionsKey).(*Options); ok {
+		return opts
+	}
+	return &Options{}
+}
+
+// Markdown converts a note to HTML for the web views.
+func Markdown(source string) (string, error) {
+	return Note(source, Options{})
+}
+
+// Note converts a note to HTML with its links pointing into the vault. Math
+// is marked with the math class and left for KaTeX in the browser.
+func Note(source string, opts Options) (string, error) {
+	pc := parser.NewContext()
+	pc.Set(optionsKey, &opts)
+	var out bytes.Buffer
+	if err := markdown.Convert([]byte(source), &out, parser.WithContext(pc)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,85 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, source string, opts Options) string {
+	t.Helper()
+	html, err := Note(source, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return html
+}
+
+func TestNoteWikilinks(t *testing.T) {
+	opts := Options{
+		Location: "dir/note.md",
+		Link: func(target string) (string, bool) {
+			if target == "missing" {
+				return "", false
+			}
+			return "/vault/" + target, true
+		},
+		File: func(location string) (string, bool) { return "/files/" + location, true },
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"[[other]]", `<a class="wikilink" href="/vault/other">other</a>`},
+		{"[[other#Some Heading|shown]]", `<a class="wikilink" href="/vault/other#some-heading">shown</a>`},
+		{"[[other#Top]]", `>other &gt; Top</a>`},
+		{"[[#Local part]]", `<a class="wikilink" href="#local-part">Local part</a>`},
+		{"[[missing]]", `<span class="wikilink unresolved">missing</span>`},
+		{"![[photo.png|300]]", `<img src="/vault/photo.png" alt="photo.png" width="300">`},
+		{"![[song.mp3]]", `<audio controls src="/vault/song.mp3"></audio>`},
+		{"[[a<b>]]", `a&lt;b&gt;`},
+		{"![pic](img/a%20b.png)", `<img src="/files/dir/img/a%20b.png"`},
+		{"[doc](../top.md#part)", `href="/files/top.md#part"`},
+		{"[site](https://example.com)", `href="https://example.com"`},
+	}
+	for _, tt := range tests {
+		if got := render(t, tt.source, opts); !strings.Contains(got, tt.want) {
+			t.Errorf("%s\n got %s\nwant %s", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownWithoutVault(t *testing.T) {
+	html, err := Markdown("[[note]] [a](b.md) <script>alert(1)</script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, `<span class="wikilink unresolved">note</span>`) || !strings.Contains(html, `href="b.md"`) {
+		t.Errorf("got %s", html)
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("raw html passed through: %s", html)
+	}
+}
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"$x^2$", `<span class="math math-inline">x^2</span>`},
+		{"$$a<b$$ inline", `<span class="math math-display">a&lt;b</span>`},
+		{"$$\nx = 1\n$$", `<div class="math math-display">x = 1`},
+		{"$$x$$", `<div class="math math-display">x</div>`},
+		{"costs $5 and $10", `costs $5 and $10`},
+		{"$ x$", `$ x$`},
+		{`$\$$`, `<span class="math math-inline">\$</span>`},
+		// the escaped $ does not close the math
+		{`$a\$ b$`, `<span class="math math-inline">a\$ b</span>`},
+		{`$\$`, `<p>$$</p>`},
+	}
+	for _, tt := range tests {
+		if got := render(t, tt.source, Options{}); !strings.Contains(got, tt.want) {
+			t.Errorf("%s\n got %s\nwant %s", tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/itsrobel/sync/internal/links"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	kindWikilink   = ast.NewNodeKind("Wikilink")
+	kindMathInline = ast.NewNodeKind("MathInline")
+	kindMathBlock  = ast.NewNodeKind("MathBlock")
+)
+
+// Wikilink is a [[link]] or ![[embed]], URL is empty when the target is not
+// in the vault.
+type Wikilink struct {
+	ast.BaseInline
+	Link links.Link
+	URL  string
+}
+
+func (n *Wikilink) Kind() ast.NodeKind { return kindWikilink }
+
+func (n *Wikilink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Link.Target, "URL": n.URL}, nil)
+}
+
+// MathInline is TeX within a paragraph, $$ marks it as display math.
+type MathInline struct {
+	ast.BaseInline
+	TeX     []byte
+	Display bool
+}
+
+func (n *MathInline) Kind() ast.NodeKind { return kindMathInline }
+
+func (n *MathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": string(n.TeX)}, nil)
+}
+
+// MathBlock is TeX between lines of $$.
+type MathBlock struct {
+	ast.BaseBlock
+	closed bool
+}
+
+func (n *MathBlock) Kind() ast.NodeKind { return kindMathBlock }
+func (n *MathBlock) IsRaw() bool        { return true }
+
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type wikilinkParser struct{}
+
+func (p *wikilinkParser) Trigger() []byte { return []byte{'!', '['} }
+
+func (p *wikilinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	embed := len(line) > 0 && line[0] == '!'
+	start := 2
+	if embed {
+		start = 3
+	}
+	if len(line) < start || !bytes.HasPrefix(line[start-2:], []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line[start:], []byte("]]"))
+	if end <= 0 {
+		return nil
+	}
+	inner := line[start : start+end]
+	if bytes.ContainsAny(inner, "[]\n") {
+		return nil
+	}
+	link := links.ParseLink(string(inner))
+	if link.Target == "" && link.Heading == "" {
+		return nil
+	}
+	link.Embed = embed
+	block.Advance(start + end + 2)
+
+	node := &Wikilink{Link: link}
+	opts := options(pc)
+	switch {
+	case link.Target == "":
+		// a heading of the note itself
+		node.URL = "#" + headingID(link.Heading)
+	case opts.Link != nil:
+		if target, ok := opts.Link(link.Target); ok {
+			node.URL = target
+			if link.Heading != "" && !embed {
+				node.URL += "#" + headingID(link.Heading)
+			}
+		}
+	}
+	return node
+}
+
+// headingID follows the IDs goldmark gives headings, close enough for
+// links to headings with plain words.
+func headingID(heading string) string {
+	var id strings.Builder
+	for _, r := range strings.TrimSpace(heading) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			id.WriteRune(unicode.ToLower(r))
+		case unicode.IsSpace(r):
+			id.WriteRune('-')
+		}
+	}
+	return id.String()
+}
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte { return []byte{'$'} }
+
+// Parse takes $x$ and $$x$$ within a line. Like pandoc, the opening $ is
+// not followed by a space, and the closing one does not follow a space and
+// is not followed by a digit, so "$5 and $10" stays text.
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if bytes.HasPrefix(line, []byte("$$")) {
+		end := bytes.Index(line[2:], []byte("$$"))
+		if end <= 0 {
+			return nil
+		}
+		block.Advance(end + 4)
+		return &MathInline{TeX: bytes.Clone(line[2 : 2+end]), Display: true}
+	}
+	if len(line) < 3 || line[1] == ' ' || line[1] == '$' {
+		return nil
+	}
+	// escapes are skipped before looking for the closing $, so $\$ is text
+	for i := 1; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '$':
+			if line[i-1] == ' ' || (i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9') {
+				return nil
+			}
+			block.Advance(i + 1)
+			return &MathInline{TeX: bytes.Clone(line[1:i])}
+		}
+	}
+	return nil
+}
+
+// mathBlockParser takes display math between lines of $$.
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte { return []byte{'$'} }
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	if !bytes.HasPrefix(trimmed, []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	node := &MathBlock{}
+	if rest := bytes.TrimSpace(trimmed[2:]); len(rest) > 0 {
+		// $$x$$ on one line
+		if !bytes.HasSuffix(rest, []byte("$$")) || len(rest) < 3 {
+			return nil, parser.NoChildren
+		}
+		start := segment.Start + bytes.Index(line, []byte("$$")) + 2
+		node.Lines().Append(text.NewSegment(start, segment.Start+bytes.LastIndex(line, []byte("$$"))))
+		node.closed = true
+	}
+	reader.Advance(segment.Len() - 1)
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	math := node.(*MathBlock)
+	if math.closed {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	if bytes.Equal(bytes.TrimSpace(line), []byte("$$")) {
+		reader.Advance(segment.Len())
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+func (p *mathBlockParser) CanInterruptParagraph() bool                                { return true }
+func (p *mathBlockParser) CanAcceptIndentedLine() bool                                { return false }
+
+// pathTransformer points markdown links and images with paths relative to the
+// note at the files of the vault.
+type pathTransformer struct{}
+
+func (t *pathTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	opts := options(pc)
+	if opts.File == nil {
+		return
+	}
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Image:
+			n.Destination = resolvePath(opts, n.Destination)
+		case *ast.Link:
+			n.Destination = resolvePath(opts, n.Destination)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func resolvePath(opts *Options, destination []byte) []byte {
+	dest := string(destination)
+	if dest == "" || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "/") {
+		return destination
+	}
+	// anything with a scheme, http: or mailto: for example, is not a path
+	if colon := strings.Index(dest, ":"); colon >= 0 && !strings.Contains(dest[:colon], "/") {
+		return destination
+	}
+	dest, fragment, _ := strings.Cut(dest, "#")
+	if unescaped, err := url.PathUnescape(dest); err == nil {
+		dest = unescaped
+	}
+	location := strings.TrimPrefix(path.Join(path.Dir(opts.Location), dest), "/")
+	target, ok := opts.File(location)
+	if !ok {
+		return destination
+	}
+	if fragment != "" {
+		target += "#" + fragment
+	}
+	return []byte(target)
+}
+
+type nodeRenderer struct{}
+
+func (r *nodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindWikilink, r.renderWikilink)
+	reg.Register(kindMathInline, r.renderMathInline)
+	reg.Register(kindMathBlock, r.renderMathBlock)
+}
+
+func (r *nodeRenderer) renderWikilink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*Wikilink)
+	label := n.Link.Alias
+	switch {
+	case label != "" && !(n.Link.Embed && isNumber(label)):
+	case n.Link.Target == "":
+		label = n.Link.Heading
+	case n.Link.Heading != "" && !n.Link.Embed:
+		label = n.Link.Target + " > " + n.Link.Heading
+	default:
+		label = n.Link.Target
+	}
+	if n.URL == "" {
+		fmt.Fprintf(w, `<span class="wikilink unresolved">%s</span>`, util.EscapeHTML([]byte(label)))
+		return ast.WalkSkipChildren, nil
+	}
+
+	href := util.EscapeHTML(util.URLEscape([]byte(n.URL), false))
+	if n.Link.Embed {
+		switch embedKind(n.Link.Target) {
+		case "image":
+			// ![[photo.png|300]] sets the width like Obsidian
+			width := ""
+			if isNumber(n.Link.Alias) {
+				width = fmt.Sprintf(` width="%s"`, n.Link.Alias)
+			}
+			fmt.Fprintf(w, `<img src="%s" alt="%s"%s>`, href, util.EscapeHTML([]byte(label)), width)
+			return ast.WalkSkipChildren, nil
+		case "audio":
+			fmt.Fprintf(w, `<audio controls src="%s"></audio>`, href)
+			return ast.WalkSkipChildren, nil
+		case "video":
+			fmt.Fprintf(w, `<video controls src="%s"></video>`, href)
+			return ast.WalkSkipChildren, nil
+		}
+	}
+	fmt.Fprintf(w, `<a class="wikilink" href="%s">%s</a>`, href, util.EscapeHTML([]byte(label)))
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *nodeRenderer) renderMathInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*MathInline)
+	class := "math math-inline"
+	if n.Display {
+		class = "math math-display"
+	}
+	fmt.Fprintf(w, `<span class="%s">%s</span>`, class, util.EscapeHTML(n.TeX))
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *nodeRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	w.WriteString(`<div class="math math-display">`)
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		w.Write(util.EscapeHTML(segment.Value(source)))
+	}
+	w.WriteString("</div>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+func embedKind(target string) string {
+	switch strings.ToLower(path.Ext(target)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".bmp", ".svg", ".webp":
+		return "image"
+	case ".mp3", ".wav", ".m4a", ".ogg", ".flac":
+		return "audio"
+	case ".mp4", ".webm", ".ogv", ".mov", ".mkv":
+		return "video"
+	}
+	return ""
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -44,6 +44,76 @@ const (
 	// FileServiceRetrieveListOfFilesProcedure is the fully-qualified name of the FileService's
 	// RetrieveListOfFiles RPC.
 	FileServiceRetrieveListOfFilesProcedure = "/filetransfer.FileService/RetrieveListOfFiles"
+	// FileServiceFindFileProcedure is the fully-qualified name of the FileService's FindFile RPC.
+	FileServiceFindFileProcedure = "/filetransfer.FileService/FindFile"
+	// FileServiceDownloadFileProcedure is the fully-qualified name of the FileService's DownloadFile
+	// RPC.
+	FileServiceDownloadFileProcedure = "/filetransfer.FileService/DownloadFile"
+	// FileServiceGetKeyInfoProcedure is the fully-qualified name of the FileService's GetKeyInfo RPC.
+	FileServiceGetKeyInfoProcedure = "/filetransfer.FileService/GetKeyInfo"
+	// FileServiceSetKeyInfoProcedure is the fully-qualified name of the FileService's SetKeyInfo RPC.
+	FileServiceSetKeyInfoProcedure = "/filetransfer.FileService/SetKeyInfo"
+	// FileServiceCreateVaultProcedure is the fully-qualified name of the FileService's CreateVault RPC.
+	FileServiceCreateVaultProcedure = "/filetransfer.FileService/CreateVault"
+	// FileServiceListVaultsProcedure is the fully-qualified name of the FileService's ListVaults RPC.
+	FileServiceListVaultsProcedure = "/filetransfer.FileService/ListVaults"
+	// FileServiceGetAccessProcedure is the fully-qualified name of the FileService's GetAccess RPC.
+	FileServiceGetAccessProcedure = "/filetransfer.FileService/GetAccess"
+	// FileServiceSetMembershipProcedure is the fully-qualified name of the FileService's SetMembership
+	// RPC.
+	FileServiceSetMembershipProcedure = "/filetransfer.FileService/SetMembership"
+	// FileServiceListMembersProcedure is the fully-qualified name of the FileService's ListMembers RPC.
+	FileServiceListMembersProcedure = "/filetransfer.FileService/ListMembers"
+	// FileServiceCreateDeviceTokenProcedure is the fully-qualified name of the FileService's
+	// CreateDeviceToken RPC.
+	FileServiceCreateDeviceTokenProcedure = "/filetransfer.FileService/CreateDeviceToken"
+	// FileServiceCreateShareLinkProcedure is the fully-qualified name of the FileService's
+	// CreateShareLink RPC.
+	FileServiceCreateShareLinkProcedure = "/filetransfer.FileService/CreateShareLink"
+	// FileServiceListShareLinksProcedure is the fully-qualified name of the FileService's
+	// ListShareLinks RPC.
+	FileServiceListShareLinksProcedure = "/filetransfer.FileService/ListShareLinks"
+	// FileServiceRevokeShareLinkProcedure is the fully-qualified name of the FileService's
+	// RevokeShareLink RPC.
+	FileServiceRevokeShareLinkProcedure = "/filetransfer.FileService/RevokeShareLink"
+	// FileServiceOpenShareLinkProcedure is the fully-qualified name of the FileService's OpenShareLink
+	// RPC.
+	FileServiceOpenShareLinkProcedure = "/filetransfer.FileService/OpenShareLink"
+	// FileServiceSearchProcedure is the fully-qualified name of the FileService's Search RPC.
+	FileServiceSearchProcedure = "/filetransfer.FileService/Search"
+	// FileServiceGetBacklinksProcedure is the fully-qualified name of the FileService's GetBacklinks
+	// RPC.
+	FileServiceGetBacklinksProcedure = "/filetransfer.FileService/GetBacklinks"
+	// FileServiceGetOutgoingLinksProcedure is the fully-qualified name of the FileService's
+	// GetOutgoingLinks RPC.
+	FileServiceGetOutgoingLinksProcedure = "/filetransfer.FileService/GetOutgoingLinks"
+	// FileServiceGetUnresolvedLinksProcedure is the fully-qualified name of the FileService's
+	// GetUnresolvedLinks RPC.
+	FileServiceGetUnresolvedLinksProcedure = "/filetransfer.FileService/GetUnresolvedLinks"
+	// FileServiceQueryNotesProcedure is the fully-qualified name of the FileService's QueryNotes RPC.
+	FileServiceQueryNotesProcedure = "/filetransfer.FileService/QueryNotes"
+	// FileServiceGetGraphProcedure is the fully-qualified name of the FileService's GetGraph RPC.
+	FileServiceGetGraphProcedure = "/filetransfer.FileService/GetGraph"
+	// FileServiceGetSyncRulesProcedure is the fully-qualified name of the FileService's GetSyncRules
+	// RPC.
+	FileServiceGetSyncRulesProcedure = "/filetransfer.FileService/GetSyncRules"
+	// FileServiceSetSyncRulesProcedure is the fully-qualified name of the FileService's SetSyncRules
+	// RPC.
+	FileServiceSetSyncRulesProcedure = "/filetransfer.FileService/SetSyncRules"
+	// FileServiceSaveEditProcedure is the fully-qualified name of the FileService's SaveEdit RPC.
+	FileServiceSaveEditProcedure = "/filetransfer.FileService/SaveEdit"
+	// FileServiceListVersionsProcedure is the fully-qualified name of the FileService's ListVersions
+	// RPC.
+	FileServiceListVersionsProcedure = "/filetransfer.FileService/ListVersions"
+	// FileServiceRestoreVersionProcedure is the fully-qualified name of the FileService's
+	// RestoreVersion RPC.
+	FileServiceRestoreVersionProcedure = "/filetransfer.FileService/RestoreVersion"
+	// FileServiceWatchChangesProcedure is the fully-qualified name of the FileService's WatchChanges
+	// RPC.
+	FileServiceWatchChangesProcedure = "/filetransfer.FileService/WatchChanges"
+	// FileServiceExportArchiveProcedure is the fully-qualified name of the FileService's ExportArchive
+	// RPC.
+	FileServiceExportArchiveProcedure = "/filetransfer.FileService/ExportArchive"
 )
 
 // FileServiceClient is a client for the filetransfer.FileService service.
@@ -52,6 +122,33 @@ type FileServiceClient interface {
 	SendFileToServer(context.Context) *connect.ClientStreamForClient[filetransfer.FileVersionData, filetransfer.ActionResponse]
 	Greet(context.Context, *connect.Request[filetransfer.GreetRequest]) (*connect.Response[filetransfer.GreetResponse], error)
 	RetrieveListOfFiles(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.FileList], error)
+	FindFile(context.Context, *connect.Request[filetransfer.FileLookup]) (*connect.Response[filetransfer.File], error)
+	DownloadFile(context.Context, *connect.Request[filetransfer.FileRequest]) (*connect.ServerStreamForClient[filetransfer.FileVersionData], error)
+	GetKeyInfo(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.KeyInfo], error)
+	SetKeyInfo(context.Context, *connect.Request[filetransfer.KeyInfo]) (*connect.Response[filetransfer.ActionResponse], error)
+	CreateVault(context.Context, *connect.Request[filetransfer.Vault]) (*connect.Response[filetransfer.Vault], error)
+	ListVaults(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.VaultList], error)
+	GetAccess(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.Membership], error)
+	SetMembership(context.Context, *connect.Request[filetransfer.Membership]) (*connect.Response[filetransfer.ActionResponse], error)
+	ListMembers(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.MemberList], error)
+	CreateDeviceToken(context.Context, *connect.Request[filetransfer.DeviceToken]) (*connect.Response[filetransfer.DeviceToken], error)
+	CreateShareLink(context.Context, *connect.Request[filetransfer.ShareLink]) (*connect.Response[filetransfer.ShareLink], error)
+	ListShareLinks(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.ShareLinkList], error)
+	RevokeShareLink(context.Context, *connect.Request[filetransfer.ShareLink]) (*connect.Response[filetransfer.ActionResponse], error)
+	OpenShareLink(context.Context, *connect.Request[filetransfer.ShareRequest]) (*connect.Response[filetransfer.SharedContent], error)
+	Search(context.Context, *connect.Request[filetransfer.SearchRequest]) (*connect.Response[filetransfer.SearchResults], error)
+	GetBacklinks(context.Context, *connect.Request[filetransfer.LinkRequest]) (*connect.Response[filetransfer.LinkList], error)
+	GetOutgoingLinks(context.Context, *connect.Request[filetransfer.LinkRequest]) (*connect.Response[filetransfer.LinkList], error)
+	GetUnresolvedLinks(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.LinkList], error)
+	QueryNotes(context.Context, *connect.Request[filetransfer.NoteQuery]) (*connect.Response[filetransfer.NoteList], error)
+	GetGraph(context.Context, *connect.Request[filetransfer.GraphRequest]) (*connect.Response[filetransfer.Graph], error)
+	GetSyncRules(context.Context, *connect.Request[filetransfer.SyncRules]) (*connect.Response[filetransfer.SyncRules], error)
+	SetSyncRules(context.Context, *connect.Request[filetransfer.SyncRules]) (*connect.Response[filetransfer.SyncRules], error)
+	SaveEdit(context.Context, *connect.Request[filetransfer.EditRequest]) (*connect.Response[filetransfer.FileVersionData], error)
+	ListVersions(context.Context, *connect.Request[filetransfer.FileRequest]) (*connect.Response[filetransfer.VersionList], error)
+	RestoreVersion(context.Context, *connect.Request[filetransfer.RestoreRequest]) (*connect.Response[filetransfer.FileVersionData], error)
+	WatchChanges(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.ServerStreamForClient[filetransfer.ControlMessage], error)
+	ExportArchive(context.Context, *connect.Request[filetransfer.ExportRequest]) (*connect.ServerStreamForClient[filetransfer.ArchiveChunk], error)
 }
 
 // NewFileServiceClient constructs a client for the filetransfer.FileService service. By default, it
@@ -89,6 +186,168 @@ func NewFileServiceClient(httpClient connect.HTTPClient, baseURL string, opts ..
 			connect.WithSchema(fileServiceMethods.ByName("RetrieveListOfFiles")),
 			connect.WithClientOptions(opts...),
 		),
+		findFile: connect.NewClient[filetransfer.FileLookup, filetransfer.File](
+			httpClient,
+			baseURL+FileServiceFindFileProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("FindFile")),
+			connect.WithClientOptions(opts...),
+		),
+		downloadFile: connect.NewClient[filetransfer.FileRequest, filetransfer.FileVersionData](
+			httpClient,
+			baseURL+FileServiceDownloadFileProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("DownloadFile")),
+			connect.WithClientOptions(opts...),
+		),
+		getKeyInfo: connect.NewClient[filetransfer.ActionRequest, filetransfer.KeyInfo](
+			httpClient,
+			baseURL+FileServiceGetKeyInfoProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("GetKeyInfo")),
+			connect.WithClientOptions(opts...),
+		),
+		setKeyInfo: connect.NewClient[filetransfer.KeyInfo, filetransfer.ActionResponse](
+			httpClient,
+			baseURL+FileServiceSetKeyInfoProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("SetKeyInfo")),
+			connect.WithClientOptions(opts...),
+		),
+		createVault: connect.NewClient[filetransfer.Vault, filetransfer.Vault](
+			httpClient,
+			baseURL+FileServiceCreateVaultProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("CreateVault")),
+			connect.WithClientOptions(opts...),
+		),
+		listVaults: connect.NewClient[filetransfer.ActionRequest, filetransfer.VaultList](
+			httpClient,
+			baseURL+FileServiceListVaultsProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("ListVaults")),
+			connect.WithClientOptions(opts...),
+		),
+		getAccess: connect.NewClient[filetransfer.ActionRequest, filetransfer.Membership](
+			httpClient,
+			baseURL+FileServiceGetAccessProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("GetAccess")),
+			connect.WithClientOptions(opts...),
+		),
+		setMembership: connect.NewClient[filetransfer.Membership, filetransfer.ActionResponse](
+			httpClient,
+			baseURL+FileServiceSetMembershipProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("SetMembership")),
+			connect.WithClientOptions(opts...),
+		),
+		listMembers: connect.NewClient[filetransfer.ActionRequest, filetransfer.MemberList](
+			httpClient,
+			baseURL+FileServiceListMembersProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("ListMembers")),
+			connect.WithClientOptions(opts...),
+		),
+		createDeviceToken: connect.NewClient[filetransfer.DeviceToken, filetransfer.DeviceToken](
+			httpClient,
+			baseURL+FileServiceCreateDeviceTokenProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("CreateDeviceToken")),
+			connect.WithClientOptions(opts...),
+		),
+		createShareLink: connect.NewClient[filetransfer.ShareLink, filetransfer.ShareLink](
+			httpClient,
+			baseURL+FileServiceCreateShareLinkProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("CreateShareLink")),
+			connect.WithClientOptions(opts...),
+		),
+		listShareLinks: connect.NewClient[filetransfer.ActionRequest, filetransfer.ShareLinkList](
+			httpClient,
+			baseURL+FileServiceListShareLinksProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("ListShareLinks")),
+			connect.WithClientOptions(opts...),
+		),
+		revokeShareLink: connect.NewClient[filetransfer.ShareLink, filetransfer.ActionResponse](
+			httpClient,
+			baseURL+FileServiceRevokeShareLinkProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("RevokeShareLink")),
+			connect.WithClientOptions(opts...),
+		),
+		openShareLink: connect.NewClient[filetransfer.ShareRequest, filetransfer.SharedContent](
+			httpClient,
+			baseURL+FileServiceOpenShareLinkProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("OpenShareLink")),
+			connect.WithClientOptions(opts...),
+		),
+		search: connect.NewClient[filetransfer.SearchRequest, filetransfer.SearchResults](
+			httpClient,
+			baseURL+FileServiceSearchProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("Search")),
+			connect.WithClientOptions(opts...),
+		),
+		getBacklinks: connect.NewClient[filetransfer.LinkRequest, filetransfer.LinkList](
+			httpClient,
+			baseURL+FileServiceGetBacklinksProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("GetBacklinks")),
+			connect.WithClientOptions(opts...),
+		),
+		getOutgoingLinks: connect.NewClient[filetransfer.LinkRequest, filetransfer.LinkList](
+			httpClient,
+			baseURL+FileServiceGetOutgoingLinksProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("GetOutgoingLinks")),
+			connect.WithClientOptions(opts...),
+		),
+		getUnresolvedLinks: connect.NewClient[filetransfer.ActionRequest, filetransfer.LinkList](
+			httpClient,
+			baseURL+FileServiceGetUnresolvedLinksProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("GetUnresolvedLinks")),
+			connect.WithClientOptions(opts...),
+		),
+		queryNotes: connect.NewClient[filetransfer.NoteQuery, filetransfer.NoteList](
+			httpClient,
+			baseURL+FileServiceQueryNotesProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("QueryNotes")),
+			connect.WithClientOptions(opts...),
+		),
+		getGraph: connect.NewClient[filetransfer.GraphRequest, filetransfer.Graph](
+			httpClient,
+			baseURL+FileServiceGetGraphProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("GetGraph")),
+			connect.WithClientOptions(opts...),
+		),
+		getSyncRules: connect.NewClient[filetransfer.SyncRules, filetransfer.SyncRules](
+			httpClient,
+			baseURL+FileServiceGetSyncRulesProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("GetSyncRules")),
+			connect.WithClientOptions(opts...),
+		),
+		setSyncRules: connect.NewClient[filetransfer.SyncRules, filetransfer.SyncRules](
+			httpClient,
+			baseURL+FileServiceSetSyncRulesProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("SetSyncRules")),
+			connect.WithClientOptions(opts...),
+		),
+		saveEdit: connect.NewClient[filetransfer.EditRequest, filetransfer.FileVersionData](
+			httpClient,
+			baseURL+FileServiceSaveEditProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("SaveEdit")),
+			connect.WithClientOptions(opts...),
+		),
+		listVersions: connect.NewClient[filetransfer.FileRequest, filetransfer.VersionList](
+			httpClient,
+			baseURL+FileServiceListVersionsProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("ListVersions")),
+			connect.WithClientOptions(opts...),
+		),
+		restoreVersion: connect.NewClient[filetransfer.RestoreRequest, filetransfer.FileVersionData](
+			httpClient,
+			baseURL+FileServiceRestoreVersionProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("RestoreVersion")),
+			connect.WithClientOptions(opts...),
+		),
+		watchChanges: connect.NewClient[filetransfer.ActionRequest, filetransfer.ControlMessage](
+			httpClient,
+			baseURL+FileServiceWatchChangesProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("WatchChanges")),
+			connect.WithClientOptions(opts...),
+		),
+		exportArchive: connect.NewClient[filetransfer.ExportRequest, filetransfer.ArchiveChunk](
+			httpClient,
+			baseURL+FileServiceExportArchiveProcedure,
+			connect.WithSchema(fileServiceMethods.ByName("ExportArchive")),
+			connect.WithClientOptions(opts...),
+		),
 	}
 }
 
@@ -98,6 +357,33 @@ type fileServiceClient struct {
 	sendFileToServer    *connect.Client[filetransfer.FileVersionData, filetransfer.ActionResponse]
 	greet               *connect.Client[filetransfer.GreetRequest, filetransfer.GreetResponse]
 	retrieveListOfFiles *connect.Client[filetransfer.ActionRequest, filetransfer.FileList]
+	findFile            *connect.Client[filetransfer.FileLookup, filetransfer.File]
+	downloadFile        *connect.Client[filetransfer.FileRequest, filetransfer.FileVersionData]
+	getKeyInfo          *connect.Client[filetransfer.ActionRequest, filetransfer.KeyInfo]
+	setKeyInfo          *connect.Client[filetransfer.KeyInfo, filetransfer.ActionResponse]
+	createVault         *connect.Client[filetransfer.Vault, filetransfer.Vault]
+	listVaults          *connect.Client[filetransfer.ActionRequest, filetransfer.VaultList]
+	getAccess           *connect.Client[filetransfer.ActionRequest, filetransfer.Membership]
+	setMembership       *connect.Client[filetransfer.Membership, filetransfer.ActionResponse]
+	listMembers         *connect.Client[filetransfer.ActionRequest, filetransfer.MemberList]
+	createDeviceToken   *connect.Client[filetransfer.DeviceToken, filetransfer.DeviceToken]
+	createShareLink     *connect.Client[filetransfer.ShareLink, filetransfer.ShareLink]
+	listShareLinks      *connect.Client[filetransfer.ActionRequest, filetransfer.ShareLinkList]
+	revokeShareLink     *connect.Client[filetransfer.ShareLink, filetransfer.ActionResponse]
+	openShareLink       *connect.Client[filetransfer.ShareRequest, filetransfer.SharedContent]
+	search              *connect.Client[filetransfer.SearchRequest, filetransfer.SearchResults]
+	getBacklinks        *connect.Client[filetransfer.LinkRequest, filetransfer.LinkList]
+	getOutgoingLinks    *connect.Client[filetransfer.LinkRequest, filetransfer.LinkList]
+	getUnresolvedLinks  *connect.Client[filetransfer.ActionRequest, filetransfer.LinkList]
+	queryNotes          *connect.Client[filetransfer.NoteQuery, filetransfer.NoteList]
+	getGraph            *connect.Client[filetransfer.GraphRequest, filetransfer.Graph]
+	getSyncRules        *connect.Client[filetransfer.SyncRules, filetransfer.SyncRules]
+	setSyncRules        *connect.Client[filetransfer.SyncRules, filetransfer.SyncRules]
+	saveEdit            *connect.Client[filetransfer.EditRequest, filetransfer.FileVersionData]
+	listVersions        *connect.Client[filetransfer.FileRequest, filetransfer.VersionList]
+	restoreVersion      *connect.Client[filetransfer.RestoreRequest, filetransfer.FileVersionData]
+	watchChanges        *connect.Client[filetransfer.ActionRequest, filetransfer.ControlMessage]
+	exportArchive       *connect.Client[filetransfer.ExportRequest, filetransfer.ArchiveChunk]
 }
 
 // ControlStream calls filetransfer.FileService.ControlStream.
@@ -120,12 +406,174 @@ func (c *fileServiceClient) RetrieveListOfFiles(ctx context.Context, req *connec
 	return c.retrieveListOfFiles.CallUnary(ctx, req)
 }
 
+// FindFile calls filetransfer.FileService.FindFile.
+func (c *fileServiceClient) FindFile(ctx context.Context, req *connect.Request[filetransfer.FileLookup]) (*connect.Response[filetransfer.File], error) {
+	return c.findFile.CallUnary(ctx, req)
+}
+
+// DownloadFile calls filetransfer.FileService.DownloadFile.
+func (c *fileServiceClient) DownloadFile(ctx context.Context, req *connect.Request[filetransfer.FileRequest]) (*connect.ServerStreamForClient[filetransfer.FileVersionData], error) {
+	return c.downloadFile.CallServerStream(ctx, req)
+}
+
+// GetKeyInfo calls filetransfer.FileService.GetKeyInfo.
+func (c *fileServiceClient) GetKeyInfo(ctx context.Context, req *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.KeyInfo], error) {
+	return c.getKeyInfo.CallUnary(ctx, req)
+}
+
+// SetKeyInfo calls filetransfer.FileService.SetKeyInfo.
+func (c *fileServiceClient) SetKeyInfo(ctx context.Context, req *connect.Request[filetransfer.KeyInfo]) (*connect.Response[filetransfer.ActionResponse], error) {
+	return c.setKeyInfo.CallUnary(ctx, req)
+}
+
+// CreateVault calls filetransfer.FileService.CreateVault.
+func (c *fileServiceClient) CreateVault(ctx context.Context, req *connect.Request[filetransfer.Vault]) (*connect.Response[filetransfer.Vault], error) {
+	return c.createVault.CallUnary(ctx, req)
+}
+
+// ListVaults calls filetransfer.FileService.ListVaults.
+func (c *fileServiceClient) ListVaults(ctx context.Context, req *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.VaultList], error) {
+	return c.listVaults.CallUnary(ctx, req)
+}
+
+// GetAccess calls filetransfer.FileService.GetAccess.
+func (c *fileServiceClient) GetAccess(ctx context.Context, req *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.Membership], error) {
+	return c.getAccess.CallUnary(ctx, req)
+}
+
+// SetMembership calls filetransfer.FileService.SetMembership.
+func (c *fileServiceClient) SetMembership(ctx context.Context, req *connect.Request[filetransfer.Membership]) (*connect.Response[filetransfer.ActionResponse], error) {
+	return c.setMembership.CallUnary(ctx, req)
+}
+
+// ListMembers calls filetransfer.FileService.ListMembers.
+func (c *fileServiceClient) ListMembers(ctx context.Context, req *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.MemberList], error) {
+	return c.listMembers.CallUnary(ctx, req)
+}
+
+// CreateDeviceToken calls filetransfer.FileService.CreateDeviceToken.
+func (c *fileServiceClient) CreateDeviceToken(ctx context.Context, req *connect.Request[filetransfer.DeviceToken]) (*connect.Response[filetransfer.DeviceToken], error) {
+	return c.createDeviceToken.CallUnary(ctx, req)
+}
+
+// CreateShareLink calls filetransfer.FileService.CreateShareLink.
+func (c *fileServiceClient) CreateShareLink(ctx context.Context, req *connect.Request[filetransfer.ShareLink]) (*connect.Response[filetransfer.ShareLink], error) {
+	return c.createShareLink.CallUnary(ctx, req)
+}
+
+// ListShareLinks calls filetransfer.FileService.ListShareLinks.
+func (c *fileServiceClient) ListShareLinks(ctx context.Context, req *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.ShareLinkList], error) {
+	return c.listShareLinks.CallUnary(ctx, req)
+}
+
+// RevokeShareLink calls filetransfer.FileService.RevokeShareLink.
+func (c *fileServiceClient) RevokeShareLink(ctx context.Context, req *connect.Request[filetransfer.ShareLink]) (*connect.Response[filetransfer.ActionResponse], error) {
+	return c.revokeShareLink.CallUnary(ctx, req)
+}
+
+// OpenShareLink calls filetransfer.FileService.OpenShareLink.
+func (c *fileServiceClient) OpenShareLink(ctx context.Context, req *connect.Request[filetransfer.ShareRequest]) (*connect.Response[filetransfer.SharedContent], error) {
+	return c.openShareLink.CallUnary(ctx, req)
+}
+
+// Search calls filetransfer.FileService.Search.
+func (c *fileServiceClient) Search(ctx context.Context, req *connect.Request[filetransfer.SearchRequest]) (*connect.Response[filetransfer.SearchResults], error) {
+	return c.search.CallUnary(ctx, req)
+}
+
+// GetBacklinks calls filetransfer.FileService.GetBacklinks.
+func (c *fileServiceClient) GetBacklinks(ctx context.Context, req *connect.Request[filetransfer.LinkRequest]) (*connect.Response[filetransfer.LinkList], error) {
+	return c.getBacklinks.CallUnary(ctx, req)
+}
+
+// GetOutgoingLinks calls filetransfer.FileService.GetOutgoingLinks.
+func (c *fileServiceClient) GetOutgoingLinks(ctx context.Context, req *connect.Request[filetransfer.LinkRequest]) (*connect.Response[filetransfer.LinkList], error) {
+	return c.getOutgoingLinks.CallUnary(ctx, req)
+}
+
+// GetUnresolvedLinks calls filetransfer.FileService.GetUnresolvedLinks.
+func (c *fileServiceClient) GetUnresolvedLinks(ctx context.Context, req *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.LinkList], error) {
+	return c.getUnresolvedLinks.CallUnary(ctx, req)
+}
+
+// QueryNotes calls filetransfer.FileService.QueryNotes.
+func (c *fileServiceClient) QueryNotes(ctx context.Context, req *connect.Request[filetransfer.NoteQuery]) (*connect.Response[filetransfer.NoteList], error) {
+	return c.queryNotes.CallUnary(ctx, req)
+}
+
+// GetGraph calls filetransfer.FileService.GetGraph.
+func (c *fileServiceClient) GetGraph(ctx context.Context, req *connect.Request[filetransfer.GraphRequest]) (*connect.Response[filetransfer.Graph], error) {
+	return c.getGraph.CallUnary(ctx, req)
+}
+
+// GetSyncRules calls filetransfer.FileService.GetSyncRules.
+func (c *fileServiceClient) GetSyncRules(ctx context.Context, req *connect.Request[filetransfer.SyncRules]) (*connect.Response[filetransfer.SyncRules], error) {
+	return c.getSyncRules.CallUnary(ctx, req)
+}
+
+// SetSyncRules calls filetransfer.FileService.SetSyncRules.
+func (c *fileServiceClient) SetSyncRules(ctx context.Context, req *connect.Request[filetransfer.SyncRules]) (*connect.Response[filetransfer.SyncRules], error) {
+	return c.setSyncRules.CallUnary(ctx, req)
+}
+
+// SaveEdit calls filetransfer.FileService.SaveEdit.
+func (c *fileServiceClient) SaveEdit(ctx context.Context, req *connect.Request[filetransfer.EditRequest]) (*connect.Response[filetransfer.FileVersionData], error) {
+	return c.saveEdit.CallUnary(ctx, req)
+}
+
+// ListVersions calls filetransfer.FileService.ListVersions.
+func (c *fileServiceClient) ListVersions(ctx context.Context, req *connect.Request[filetransfer.FileRequest]) (*connect.Response[filetransfer.VersionList], error) {
+	return c.listVersions.CallUnary(ctx, req)
+}
+
+// RestoreVersion calls filetransfer.FileService.RestoreVersion.
+func (c *fileServiceClient) RestoreVersion(ctx context.Context, req *connect.Request[filetransfer.RestoreRequest]) (*connect.Response[filetransfer.FileVersionData], error) {
+	return c.restoreVersion.CallUnary(ctx, req)
+}
+
+// WatchChanges calls filetransfer.FileService.WatchChanges.
+func (c *fileServiceClient) WatchChanges(ctx context.Context, req *connect.Request[filetransfer.ActionRequest]) (*connect.ServerStreamForClient[filetransfer.ControlMessage], error) {
+	return c.watchChanges.CallServerStream(ctx, req)
+}
+
+// ExportArchive calls filetransfer.FileService.ExportArchive.
+func (c *fileServiceClient) ExportArchive(ctx context.Context, req *connect.Request[filetransfer.ExportRequest]) (*connect.ServerStreamForClient[filetransfer.ArchiveChunk], error) {
+	return c.exportArchive.CallServerStream(ctx, req)
+}
+
 // FileServiceHandler is an implementation of the filetransfer.FileService service.
 type FileServiceHandler interface {
 	ControlStream(context.Context, *connect.BidiStream[filetransfer.ControlMessage, filetransfer.ControlMessage]) error
 	SendFileToServer(context.Context, *connect.ClientStream[filetransfer.FileVersionData]) (*connect.Response[filetransfer.ActionResponse], error)
 	Greet(context.Context, *connect.Request[filetransfer.GreetRequest]) (*connect.Response[filetransfer.GreetResponse], error)
 	RetrieveListOfFiles(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.FileList], error)
+	FindFile(context.Context, *connect.Request[filetransfer.FileLookup]) (*connect.Response[filetransfer.File], error)
+	DownloadFile(context.Context, *connect.Request[filetransfer.FileRequest], *connect.ServerStream[filetransfer.FileVersionData]) error
+	GetKeyInfo(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.KeyInfo], error)
+	SetKeyInfo(context.Context, *connect.Request[filetransfer.KeyInfo]) (*connect.Response[filetransfer.ActionResponse], error)
+	CreateVault(context.Context, *connect.Request[filetransfer.Vault]) (*connect.Response[filetransfer.Vault], error)
+	ListVaults(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.VaultList], error)
+	GetAccess(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.Membership], error)
+	SetMembership(context.Context, *connect.Request[filetransfer.Membership]) (*connect.Response[filetransfer.ActionResponse], error)
+	ListMembers(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.MemberList], error)
+	CreateDeviceToken(context.Context, *connect.Request[filetransfer.DeviceToken]) (*connect.Response[filetransfer.DeviceToken], error)
+	CreateShareLink(context.Context, *connect.Request[filetransfer.ShareLink]) (*connect.Response[filetransfer.ShareLink], error)
+	ListShareLinks(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.ShareLinkList], error)
+	RevokeShareLink(context.Context, *connect.Request[filetransfer.ShareLink]) (*connect.Response[filetransfer.ActionResponse], error)
+	OpenShareLink(context.Context, *connect.Request[filetransfer.ShareRequest]) (*connect.Response[filetransfer.SharedContent], error)
+	Search(context.Context, *connect.Request[filetransfer.SearchRequest]) (*connect.Response[filetransfer.SearchResults], error)
+	GetBacklinks(context.Context, *connect.Request[filetransfer.LinkRequest]) (*connect.Response[filetransfer.LinkList], error)
+	GetOutgoingLinks(context.Context, *connect.Request[filetransfer.LinkRequest]) (*connect.Response[filetransfer.LinkList], error)
+	GetUnresolvedLinks(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.LinkList], error)
+	QueryNotes(context.Context, *connect.Request[filetransfer.NoteQuery]) (*connect.Response[filetransfer.NoteList], error)
+	GetGraph(context.Context, *connect.Request[filetransfer.GraphRequest]) (*connect.Response[filetransfer.Graph], error)
+	GetSyncRules(context.Context, *connect.Request[filetransfer.SyncRules]) (*connect.Response[filetransfer.SyncRules], error)
+	SetSyncRules(context.Context, *connect.Request[filetransfer.SyncRules]) (*connect.Response[filetransfer.SyncRules], error)
+	SaveEdit(context.Context, *connect.Request[filetransfer.EditRequest]) (*connect.Response[filetransfer.FileVersionData], error)
+	ListVersions(context.Context, *connect.Request[filetransfer.FileRequest]) (*connect.Response[filetransfer.VersionList], error)
+	RestoreVersion(context.Context, *connect.Request[filetransfer.RestoreRequest]) (*connect.Response[filetransfer.FileVersionData], error)
+	WatchChanges(context.Context, *connect.Request[filetransfer.ActionRequest], *connect.ServerStream[filetransfer.ControlMessage]) error
+	ExportArchive(context.Context, *connect.Request[filetransfer.ExportRequest], *connect.ServerStream[filetransfer.ArchiveChunk]) error
 }
 
 // NewFileServiceHandler builds an HTTP handler from the service implementation. It returns the path
@@ -159,6 +607,168 @@ func NewFileServiceHandler(svc FileServiceHandler, opts ...connect.HandlerOption
 		connect.WithSchema(fileServiceMethods.ByName("RetrieveListOfFiles")),
 		connect.WithHandlerOptions(opts...),
 	)
+	fileServiceFindFileHandler := connect.NewUnaryHandler(
+		FileServiceFindFileProcedure,
+		svc.FindFile,
+		connect.WithSchema(fileServiceMethods.ByName("FindFile")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceDownloadFileHandler := connect.NewServerStreamHandler(
+		FileServiceDownloadFileProcedure,
+		svc.DownloadFile,
+		connect.WithSchema(fileServiceMethods.ByName("DownloadFile")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceGetKeyInfoHandler := connect.NewUnaryHandler(
+		FileServiceGetKeyInfoProcedure,
+		svc.GetKeyInfo,
+		connect.WithSchema(fileServiceMethods.ByName("GetKeyInfo")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceSetKeyInfoHandler := connect.NewUnaryHandler(
+		FileServiceSetKeyInfoProcedure,
+		svc.SetKeyInfo,
+		connect.WithSchema(fileServiceMethods.ByName("SetKeyInfo")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceCreateVaultHandler := connect.NewUnaryHandler(
+		FileServiceCreateVaultProcedure,
+		svc.CreateVault,
+		connect.WithSchema(fileServiceMethods.ByName("CreateVault")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceListVaultsHandler := connect.NewUnaryHandler(
+		FileServiceListVaultsProcedure,
+		svc.ListVaults,
+		connect.WithSchema(fileServiceMethods.ByName("ListVaults")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceGetAccessHandler := connect.NewUnaryHandler(
+		FileServiceGetAccessProcedure,
+		svc.GetAccess,
+		connect.WithSchema(fileServiceMethods.ByName("GetAccess")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceSetMembershipHandler := connect.NewUnaryHandler(
+		FileServiceSetMembershipProcedure,
+		svc.SetMembership,
+		connect.WithSchema(fileServiceMethods.ByName("SetMembership")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceListMembersHandler := connect.NewUnaryHandler(
+		FileServiceListMembersProcedure,
+		svc.ListMembers,
+		connect.WithSchema(fileServiceMethods.ByName("ListMembers")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceCreateDeviceTokenHandler := connect.NewUnaryHandler(
+		FileServiceCreateDeviceTokenProcedure,
+		svc.CreateDeviceToken,
+		connect.WithSchema(fileServiceMethods.ByName("CreateDeviceToken")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceCreateShareLinkHandler := connect.NewUnaryHandler(
+		FileServiceCreateShareLinkProcedure,
+		svc.CreateShareLink,
+		connect.WithSchema(fileServiceMethods.ByName("CreateShareLink")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceListShareLinksHandler := connect.NewUnaryHandler(
+		FileServiceListShareLinksProcedure,
+		svc.ListShareLinks,
+		connect.WithSchema(fileServiceMethods.ByName("ListShareLinks")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceRevokeShareLinkHandler := connect.NewUnaryHandler(
+		FileServiceRevokeShareLinkProcedure,
+		svc.RevokeShareLink,
+		connect.WithSchema(fileServiceMethods.ByName("RevokeShareLink")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceOpenShareLinkHandler := connect.NewUnaryHandler(
+		FileServiceOpenShareLinkProcedure,
+		svc.OpenShareLink,
+		connect.WithSchema(fileServiceMethods.ByName("OpenShareLink")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceSearchHandler := connect.NewUnaryHandler(
+		FileServiceSearchProcedure,
+		svc.Search,
+		connect.WithSchema(fileServiceMethods.ByName("Search")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceGetBacklinksHandler := connect.NewUnaryHandler(
+		FileServiceGetBacklinksProcedure,
+		svc.GetBacklinks,
+		connect.WithSchema(fileServiceMethods.ByName("GetBacklinks")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceGetOutgoingLinksHandler := connect.NewUnaryHandler(
+		FileServiceGetOutgoingLinksProcedure,
+		svc.GetOutgoingLinks,
+		connect.WithSchema(fileServiceMethods.ByName("GetOutgoingLinks")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceGetUnresolvedLinksHandler := connect.NewUnaryHandler(
+		FileServiceGetUnresolvedLinksProcedure,
+		svc.GetUnresolvedLinks,
+		connect.WithSchema(fileServiceMethods.ByName("GetUnresolvedLinks")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceQueryNotesHandler := connect.NewUnaryHandler(
+		FileServiceQueryNotesProcedure,
+		svc.QueryNotes,
+		connect.WithSchema(fileServiceMethods.ByName("QueryNotes")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceGetGraphHandler := connect.NewUnaryHandler(
+		FileServiceGetGraphProcedure,
+		svc.GetGraph,
+		connect.WithSchema(fileServiceMethods.ByName("GetGraph")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceGetSyncRulesHandler := connect.NewUnaryHandler(
+		FileServiceGetSyncRulesProcedure,
+		svc.GetSyncRules,
+		connect.WithSchema(fileServiceMethods.ByName("GetSyncRules")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceSetSyncRulesHandler := connect.NewUnaryHandler(
+		FileServiceSetSyncRulesProcedure,
+		svc.SetSyncRules,
+		connect.WithSchema(fileServiceMethods.ByName("SetSyncRules")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceSaveEditHandler := connect.NewUnaryHandler(
+		FileServiceSaveEditProcedure,
+		svc.SaveEdit,
+		connect.WithSchema(fileServiceMethods.ByName("SaveEdit")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceListVersionsHandler := connect.NewUnaryHandler(
+		FileServiceListVersionsProcedure,
+		svc.ListVersions,
+		connect.WithSchema(fileServiceMethods.ByName("ListVersions")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceRestoreVersionHandler := connect.NewUnaryHandler(
+		FileServiceRestoreVersionProcedure,
+		svc.RestoreVersion,
+		connect.WithSchema(fileServiceMethods.ByName("RestoreVersion")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceWatchChangesHandler := connect.NewServerStreamHandler(
+		FileServiceWatchChangesProcedure,
+		svc.WatchChanges,
+		connect.WithSchema(fileServiceMethods.ByName("WatchChanges")),
+		connect.WithHandlerOptions(opts...),
+	)
+	fileServiceExportArchiveHandler := connect.NewServerStreamHandler(
+		FileServiceExportArchiveProcedure,
+		svc.ExportArchive,
+		connect.WithSchema(fileServiceMethods.ByName("ExportArchive")),
+		connect.WithHandlerOptions(opts...),
+	)
 	return "/filetransfer.FileService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case FileServiceControlStreamProcedure:
@@ -169,6 +779,60 @@ func NewFileServiceHandler(svc FileServiceHandler, opts ...connect.HandlerOption
 			fileServiceGreetHandler.ServeHTTP(w, r)
 		case FileServiceRetrieveListOfFilesProcedure:
 			fileServiceRetrieveListOfFilesHandler.ServeHTTP(w, r)
+		case FileServiceFindFileProcedure:
+			fileServiceFindFileHandler.ServeHTTP(w, r)
+		case FileServiceDownloadFileProcedure:
+			fileServiceDownloadFileHandler.ServeHTTP(w, r)
+		case FileServiceGetKeyInfoProcedure:
+			fileServiceGetKeyInfoHandler.ServeHTTP(w, r)
+		case FileServiceSetKeyInfoProcedure:
+			fileServiceSetKeyInfoHandler.ServeHTTP(w, r)
+		case FileServiceCreateVaultProcedure:
+			fileServiceCreateVaultHandler.ServeHTTP(w, r)
+		case FileServiceListVaultsProcedure:
+			fileServiceListVaultsHandler.ServeHTTP(w, r)
+		case FileServiceGetAccessProcedure:
+			fileServiceGetAccessHandler.ServeHTTP(w, r)
+		case FileServiceSetMembershipProcedure:
+			fileServiceSetMembershipHandler.ServeHTTP(w, r)
+		case FileServiceListMembersProcedure:
+			fileServiceListMembersHandler.ServeHTTP(w, r)
+		case FileServiceCreateDeviceTokenProcedure:
+			fileServiceCreateDeviceTokenHandler.ServeHTTP(w, r)
+		case FileServiceCreateShareLinkProcedure:
+			fileServiceCreateShareLinkHandler.ServeHTTP(w, r)
+		case FileServiceListShareLinksProcedure:
+			fileServiceListShareLinksHandler.ServeHTTP(w, r)
+		case FileServiceRevokeShareLinkProcedure:
+			fileServiceRevokeShareLinkHandler.ServeHTTP(w, r)
+		case FileServiceOpenShareLinkProcedure:
+			fileServiceOpenShareLinkHandler.ServeHTTP(w, r)
+		case FileServiceSearchProcedure:
+			fileServiceSearchHandler.ServeHTTP(w, r)
+		case FileServiceGetBacklinksProcedure:
+			fileServiceGetBacklinksHandler.ServeHTTP(w, r)
+		case FileServiceGetOutgoingLinksProcedure:
+			fileServiceGetOutgoingLinksHandler.ServeHTTP(w, r)
+		case FileServiceGetUnresolvedLinksProcedure:
+			fileServiceGetUnresolvedLinksHandler.ServeHTTP(w, r)
+		case FileServiceQueryNotesProcedure:
+			fileServiceQueryNotesHandler.ServeHTTP(w, r)
+		case FileServiceGetGraphProcedure:
+			fileServiceGetGraphHandler.ServeHTTP(w, r)
+		case FileServiceGetSyncRulesProcedure:
+			fileServiceGetSyncRulesHandler.ServeHTTP(w, r)
+		case FileServiceSetSyncRulesProcedure:
+			fileServiceSetSyncRulesHandler.ServeHTTP(w, r)
+		case FileServiceSaveEditProcedure:
+			fileServiceSaveEditHandler.ServeHTTP(w, r)
+		case FileServiceListVersionsProcedure:
+			fileServiceListVersionsHandler.ServeHTTP(w, r)
+		case FileServiceRestoreVersionProcedure:
+			fileServiceRestoreVersionHandler.ServeHTTP(w, r)
+		case FileServiceWatchChangesProcedure:
+			fileServiceWatchChangesHandler.ServeHTTP(w, r)
+		case FileServiceExportArchiveProcedure:
+			fileServiceExportArchiveHandler.ServeHTTP(w, r)
 		default:
 			http.NotFound(w, r)
 		}
@@ -193,3 +857,111 @@ func (UnimplementedFileServiceHandler) Greet(context.Context, *connect.Request[f
 func (UnimplementedFileServiceHandler) RetrieveListOfFiles(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.FileList], error) {
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.RetrieveListOfFiles is not implemented"))
 }
+
+func (UnimplementedFileServiceHandler) FindFile(context.Context, *connect.Request[filetransfer.FileLookup]) (*connect.Response[filetransfer.File], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.FindFile is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) DownloadFile(context.Context, *connect.Request[filetransfer.FileRequest], *connect.ServerStream[filetransfer.FileVersionData]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.DownloadFile is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) GetKeyInfo(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.KeyInfo], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.GetKeyInfo is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) SetKeyInfo(context.Context, *connect.Request[filetransfer.KeyInfo]) (*connect.Response[filetransfer.ActionResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.SetKeyInfo is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) CreateVault(context.Context, *connect.Request[filetransfer.Vault]) (*connect.Response[filetransfer.Vault], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.CreateVault is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) ListVaults(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.VaultList], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.ListVaults is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) GetAccess(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.Membership], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.GetAccess is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) SetMembership(context.Context, *connect.Request[filetransfer.Membership]) (*connect.Response[filetransfer.ActionResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.SetMembership is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) ListMembers(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.MemberList], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.ListMembers is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) CreateDeviceToken(context.Context, *connect.Request[filetransfer.DeviceToken]) (*connect.Response[filetransfer.DeviceToken], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.CreateDeviceToken is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) CreateShareLink(context.Context, *connect.Request[filetransfer.ShareLink]) (*connect.Response[filetransfer.ShareLink], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.CreateShareLink is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) ListShareLinks(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.ShareLinkList], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.ListShareLinks is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) RevokeShareLink(context.Context, *connect.Request[filetransfer.ShareLink]) (*connect.Response[filetransfer.ActionResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.RevokeShareLink is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) OpenShareLink(context.Context, *connect.Request[filetransfer.ShareRequest]) (*connect.Response[filetransfer.SharedContent], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.OpenShareLink is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) Search(context.Context, *connect.Request[filetransfer.SearchRequest]) (*connect.Response[filetransfer.SearchResults], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.Search is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) GetBacklinks(context.Context, *connect.Request[filetransfer.LinkRequest]) (*connect.Response[filetransfer.LinkList], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.GetBacklinks is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) GetOutgoingLinks(context.Context, *connect.Request[filetransfer.LinkRequest]) (*connect.Response[filetransfer.LinkList], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.GetOutgoingLinks is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) GetUnresolvedLinks(context.Context, *connect.Request[filetransfer.ActionRequest]) (*connect.Response[filetransfer.LinkList], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.GetUnresolvedLinks is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) QueryNotes(context.Context, *connect.Request[filetransfer.NoteQuery]) (*connect.Response[filetransfer.NoteList], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.QueryNotes is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) GetGraph(context.Context, *connect.Request[filetransfer.GraphRequest]) (*connect.Response[filetransfer.Graph], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.GetGraph is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) GetSyncRules(context.Context, *connect.Request[filetransfer.SyncRules]) (*connect.Response[filetransfer.SyncRules], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.GetSyncRules is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) SetSyncRules(context.Context, *connect.Request[filetransfer.SyncRules]) (*connect.Response[filetransfer.SyncRules], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.SetSyncRules is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) SaveEdit(context.Context, *connect.Request[filetransfer.EditRequest]) (*connect.Response[filetransfer.FileVersionData], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.SaveEdit is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) ListVersions(context.Context, *connect.Request[filetransfer.FileRequest]) (*connect.Response[filetransfer.VersionList], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.ListVersions is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) RestoreVersion(context.Context, *connect.Request[filetransfer.RestoreRequest]) (*connect.Response[filetransfer.FileVersionData], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.RestoreVersion is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) WatchChanges(context.Context, *connect.Request[filetransfer.ActionRequest], *connect.ServerStream[filetransfer.ControlMessage]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.WatchChanges is not implemented"))
+}
+
+func (UnimplementedFileServiceHandler) ExportArchive(context.Context, *connect.Request[filetransfer.ExportRequest], *connect.ServerStream[filetransfer.ArchiveChunk]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("filetransfer.FileService.ExportArchive is not implemented"))
+}
@@ -28,17 +28,42 @@ func ConnectSQLite(dbPath string) (*gorm.DB, error) {
 	}
 
 	// Auto Migrate the schema
-	err = db.AutoMigrate(&File{}, &FileVersion{})
+	err = db.AutoMigrate(&File{}, &FileVersion{}, &VaultKey{}, &OutboxEntry{}, &Conflict{}, &SyncState{}, &Link{}, &NoteTag{}, &NoteField{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := backfillVaultIDs(db, &File{}, &FileVersion{}, &VaultKey{}); err != nil {
+		return nil, err
+	}
+	if err := SetupSearch(db); err != nil {
+		return nil, err
+	}
+	if err := BackfillLinks(db, false); err != nil {
+		return nil, fmt.Errorf("failed to index links: %w", err)
+	}
+	if err := BackfillMeta(db, false); err != nil {
+		return nil, fmt.Errorf("failed to index note metadata: %w", err)
+	}
+
 	return db, nil
 }
 
-func ConnectPostgres() (*gorm.DB, error) {
-	dsn := "host=localhost user=postgres password=postgres dbname=myapp port=5432 sslmode=disable"
+// OpenSQLiteReadOnly opens an existing client database without migrating or
+// indexing it, every write fails. It is for looking at a database without
+// changing it, a database of an older version may miss tables.
+func OpenSQLiteReadOnly(dbPath string) (*gorm.DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db, err := gorm.Open(sqlite.Open("file:"+dbPath+"?mode=ro"), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+	return db, nil
+}
 
+func ConnectPostgres(dsn string) (*gorm.DB, error) {
 	// Open connection with retry logic
 	var db *gorm.DB
 	var err error
@@ -80,16 +105,53 @@ func ConnectPostgres() (*gorm.DB, error) {
 	// 	return nil, fmt.Errorf("failed to drop tables: %v", err)
 	// }
 
+	if err := MigrateServer(db); err != nil {
+		return nil, err
+	}
+
+	log.Println("Successfully connected to PostgreSQL database")
+	return db, nil
+}
+
+// MigrateServer creates the server schema and the default vault.
+func MigrateServer(db *gorm.DB) error {
+	if err := migrateSessionKey(db); err != nil {
+		return err
+	}
 	// Migrate all models at once
 	if err := db.AutoMigrate(
+		&Vault{},
 		&ClientSession{},
 		&File{},
 		&FileVersion{},
+		&VaultKey{},
+		&User{},
+		&DeviceToken{},
+		&Membership{},
+		&ShareLink{},
+		&Link{},
+		&Tombstone{},
+		&NoteTag{},
+		&NoteField{},
 		// Add other models here
 	); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %v", err)
+		return fmt.Errorf("failed to migrate database: %v", err)
 	}
-
-	log.Println("Successfully connected to PostgreSQL database")
-	return db, nil
+	// the backfills below look files and versions up by vault
+	if err := EnsureDefaultVault(db); err != nil {
+		return err
+	}
+	if err := backfillHeads(db); err != nil {
+		return err
+	}
+	if err := SetupSearch(db); err != nil {
+		return err
+	}
+	if err := BackfillLinks(db, true); err != nil {
+		return fmt.Errorf("failed to index links: %v", err)
+	}
+	if err := BackfillMeta(db, true); err != nil {
+		return fmt.Errorf("failed to index note metadata: %v", err)
+	}
+	return nil
 }
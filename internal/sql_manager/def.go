@@ -8,33 +8,191 @@ import (
 )
 
 const (
-	Directory = "content"
-	ChunkSize = 64 * 1024
+	Directory    = "content"
+	ChunkSize    = 64 * 1024
+	DefaultVault = "default"
 )
 
 type FileBase struct {
-	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ID string `gorm:"type:uuid;primaryKey"`
+}
+
+// Vault is a namespace of files, the ID is a short name chosen on creation
+// so clients can refer to it offline.
+type Vault struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string
+	CreatedAt time.Time
 }
 
 type File struct {
 	FileBase
-	Active   bool
-	Location string
-	Content  string
+	VaultID   string `gorm:"index"`
+	Active    bool
+	Location  string
+	Content   string
+	Timestamp time.Time
+	// Blob files are attachments whose content is kept out of the database,
+	// on disk next to the client and in the content directory on the server.
+	// Size and Hash describe it.
+	Blob bool
+	Size int64
+	Hash string
+	// Remote marks attachments a client has not downloaded yet
+	Remote bool
+	// HeadVersionID is the version Content holds on the server. Writers
+	// move it with a compare-and-set, see StoreVersionServer.
+	HeadVersionID string
 }
 type FileVersion struct {
 	FileBase
+	VaultID   string `gorm:"index"`
 	Timestamp time.Time
 	Client    string
 	Location  string
 	Content   string
 	FileID    string `gorm:"type:uuid"`
+	Blob      bool
+	Size      int64
+	Hash      string
+}
+
+// VaultKey describes how to derive the end-to-end encryption key. Only the
+// latest version is needed, older keys are wrapped inside it.
+type VaultKey struct {
+	VaultID      string `gorm:"primaryKey"`
+	Version      uint32 `gorm:"primaryKey;autoIncrement:false"`
+	Salt         []byte
+	KdfTime      uint32
+	KdfMemory    uint32
+	KdfThreads   uint32
+	Check        []byte
+	WrappedKeys  []byte
+	EncryptPaths bool
+	CreatedAt    time.Time
+}
+
+type User struct {
+	FileBase
+	Name      string `gorm:"uniqueIndex"`
+	CreatedAt time.Time
+}
+
+// DeviceToken authenticates one device of a user, only the hash is stored.
+type DeviceToken struct {
+	TokenHash string `gorm:"primaryKey"`
+	UserID    string `gorm:"type:uuid;index"`
+	Name      string
+	CreatedAt time.Time
+}
+
+type Membership struct {
+	VaultID string `gorm:"primaryKey"`
+	UserID  string `gorm:"primaryKey;type:uuid"`
+	Role    string
+}
+
+// ShareLink grants read access to a file or folder without an account.
+// Only hashes of the token and password are stored.
+type ShareLink struct {
+	FileBase
+	TokenHash    string `gorm:"uniqueIndex"`
+	VaultID      string `gorm:"index"`
+	Location     string
+	Folder       bool
+	ExpiresAt    *time.Time
+	PasswordHash string
+	PinnedAt     *time.Time
+	CreatedBy    string
+	CreatedAt    time.Time
+}
+
+// Tombstone remembers where a file used to be. The server writes one when
+// an upload moves a file, the file keeps its ID across the move.
+type Tombstone struct {
+	FileBase
+	VaultID   string `gorm:"index"`
+	FileID    string `gorm:"type:uuid;index"`
+	Location  string
+	RenamedTo string
+	CreatedAt time.Time
+}
+
+// Link is an edge of a vault's link graph. TargetID is empty while the
+// target name does not match any file.
+type Link struct {
+	ID         uint   `gorm:"primaryKey"`
+	VaultID    string `gorm:"index"`
+	SourceID   string `gorm:"type:uuid;index"`
+	TargetID   string `gorm:"index"`
+	TargetName string
+	Heading    string
+	Alias      string
+	Embed      bool
+	Line       int
+}
+
+// NoteTag and NoteField hold the parsed metadata of a note, replaced on
+// every new version.
+type NoteTag struct {
+	ID      uint   `gorm:"primaryKey"`
+	VaultID string `gorm:"index"`
+	FileID  string `gorm:"type:uuid;index"`
+	Tag     string `gorm:"index"` // lowercase, without #
+}
+
+type NoteField struct {
+	ID      uint   `gorm:"primaryKey"`
+	VaultID string `gorm:"index"`
+	FileID  string `gorm:"type:uuid;index"`
+	Key     string `gorm:"index"` // lowercase
+	Value   string
+}
+
+// OutboxEntry is a local version that has not been accepted by the server
+// yet. Entries are uploaded oldest first once the vault has caught up.
+type OutboxEntry struct {
+	VersionID string `gorm:"primaryKey;type:uuid"`
+	VaultID   string `gorm:"index"`
+	FileID    string `gorm:"type:uuid;index"`
+	Location  string
+	CreatedAt time.Time
+	Attempts  int
+	LastError string
+}
+
+// Conflict records a local change that lost against a remote one. The local
+// content is kept next to the file at CopyLocation.
+type Conflict struct {
+	FileBase
+	VaultID      string `gorm:"index"`
+	FileID       string `gorm:"type:uuid"`
+	Location     string
+	CopyLocation string
+	RemoteClient string
+	CreatedAt    time.Time
+}
+
+// SyncState is the client's cursor for a vault, the last time it caught up
+// with the server.
+// The sync rules are cached with it, see SyncRules.
+type SyncState struct {
+	VaultID        string `gorm:"primaryKey"`
+	LastSync       time.Time
+	IncludeFolders string // one folder per line
+	ExcludeFolders string
 }
 
 type ClientSession struct {
-	SessionID    string `gorm:"primaryKey"`
+	SessionID string `gorm:"primaryKey"`
+	VaultID   string `gorm:"primaryKey"`
+	// the user the session belongs to, empty on servers without users
+	UserID       string `gorm:"index"`
 	LastSyncTime time.Time
 	IsActive     bool
+	// folders the client mirrors, one per line, see SyncRules
+	IncludeFolders string
+	ExcludeFolders string
 }
 
 func (f *FileBase) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,103 @@
+package sql_manager
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// GraphNode is a note of the link graph, Links and Backlinks count its edges
+// within the graph.
+type GraphNode struct {
+	FileID    string
+	Location  string
+	Tags      []string
+	Links     int
+	Backlinks int
+}
+
+// GraphEdge joins two notes, Count is how many links the source has to the
+// target.
+type GraphEdge struct {
+	SourceID string
+	TargetID string
+	Count    int
+}
+
+// GetGraph returns the active notes below folder that carry tag, or a tag
+// nested below it, and the links between them. Either filter may be empty.
+// Embeds count as links, links of a note to itself and to attachments are
+// left out.
+func GetGraph(db *gorm.DB, vaultID, folder, tag string) ([]GraphNode, []GraphEdge, error) {
+	tx := db.Model(&File{}).
+		Select("files.id AS file_id, files.location").
+		Where("files.vault_id = ? AND files.active = ? AND lower(files.location) LIKE ?", vaultID, true, "%.md")
+	if folder = strings.Trim(folder, "/"); folder != "" {
+		clause, args := queryTerm{key: "path", op: ":", value: folder}.sql()
+		tx = tx.Where(clause, args...)
+	}
+	if tag = strings.Trim(strings.TrimPrefix(tag, "#"), "/"); tag != "" {
+		clause, args := queryTerm{key: "tag", op: ":", value: tag}.sql()
+		tx = tx.Where(clause, args...)
+	}
+	var nodes []GraphNode
+	if err := tx.Order("files.location").Scan(&nodes).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(nodes) == 0 {
+		return nodes, nil, nil
+	}
+
+	byID := make(map[string]*GraphNode, len(nodes))
+	ids := make([]string, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].FileID] = &nodes[i]
+		ids[i] = nodes[i].FileID
+	}
+	var tags []NoteTag
+	if err := db.Where("file_id IN ?", ids).Order("tag").Find(&tags).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, t := range tags {
+		byID[t.FileID].Tags = append(byID[t.FileID].Tags, t.Tag)
+	}
+
+	var infos []LinkInfo
+	err := linkInfos(db).
+		Where("links.vault_id = ? AND links.target_id <> '' AND links.source_id <> links.target_id", vaultID).
+		Scan(&infos).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	counts := make(map[[2]string]int)
+	for _, info := range infos {
+		source, target := byID[info.SourceID], byID[info.TargetID]
+		if source == nil || target == nil || info.TargetLocation == "" {
+			continue
+		}
+		counts[[2]string{source.FileID, target.FileID}]++
+	}
+
+	edges := make([]GraphEdge, 0, len(counts))
+	for pair, n := range counts {
+		edges = append(edges, GraphEdge{SourceID: pair[0], TargetID: pair[1], Count: n})
+		byID[pair[0]].Links++
+		byID[pair[1]].Backlinks++
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := byID[edges[i].SourceID].Location, byID[edges[j].SourceID].Location
+		if a != b {
+			return a < b
+		}
+		return byID[edges[i].TargetID].Location < byID[edges[j].TargetID].Location
+	})
+	return nodes, edges, nil
+}
+
+// GetVaultTags lists every tag used in a vault once.
+func GetVaultTags(db *gorm.DB, vaultID string) ([]string, error) {
+	var tags []string
+	err := db.Model(&NoteTag{}).Where("vault_id = ?", vaultID).Distinct("tag").Order("tag").Pluck("tag", &tags).Error
+	return tags, err
+}
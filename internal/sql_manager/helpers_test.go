@@ -0,0 +1,46 @@
+package sql_manager
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// serverDB opens a fresh server schema on SQLite.
+func serverDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "server.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateServer(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// store uploads a version of a file in the default vault and returns its id.
+func store(t *testing.T, db *gorm.DB, fileID, location, content string, at time.Time, base string) string {
+	t.Helper()
+	id := uuid.NewString()
+	stored, err := StoreVersionServer(db, &ft.FileVersionData{
+		Id:        id,
+		VaultId:   DefaultVault,
+		FileId:    fileID,
+		Location:  location,
+		Content:   []byte(content),
+		Timestamp: timestamppb.New(at),
+		Client:    "test",
+	}, base)
+	if err != nil || !stored {
+		t.Fatalf("StoreVersionServer = %v, %v", stored, err)
+	}
+	return id
+}
@@ -0,0 +1,64 @@
+package sql_manager
+
+import (
+	"fmt"
+
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"gorm.io/gorm"
+)
+
+func VaultKeyFromInfo(info *ft.KeyInfo) *VaultKey {
+	return &VaultKey{
+		VaultID:      info.VaultId,
+		Version:      info.Version,
+		Salt:         info.Salt,
+		KdfTime:      info.KdfTime,
+		KdfMemory:    info.KdfMemory,
+		KdfThreads:   info.KdfThreads,
+		Check:        info.Check,
+		WrappedKeys:  info.WrappedKeys,
+		EncryptPaths: info.EncryptPaths,
+	}
+}
+
+func (k *VaultKey) Info() *ft.KeyInfo {
+	return &ft.KeyInfo{
+		VaultId:      k.VaultID,
+		Version:      k.Version,
+		Salt:         k.Salt,
+		KdfTime:      k.KdfTime,
+		KdfMemory:    k.KdfMemory,
+		KdfThreads:   k.KdfThreads,
+		Check:        k.Check,
+		WrappedKeys:  k.WrappedKeys,
+		EncryptPaths: k.EncryptPaths,
+	}
+}
+
+func GetCurrentVaultKey(db *gorm.DB, vaultID string) (*VaultKey, error) {
+	var key VaultKey
+	err := db.Where("vault_id = ?", vaultID).Order("version desc").First(&key).Error
+	return &key, err
+}
+
+// CreateVaultKey stores a new key version. Versions must be consecutive so
+// two clients rotating at the same time cannot overwrite each other.
+func CreateVaultKey(db *gorm.DB, key *VaultKey) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current uint32
+		if err := tx.Model(&VaultKey{}).Where("vault_id = ?", key.VaultID).
+			Select("COALESCE(MAX(version), 0)").Scan(&current).Error; err != nil {
+			return err
+		}
+		if key.Version != current+1 {
+			return fmt.Errorf("key version %d does not follow current version %d", key.Version, current)
+		}
+		return tx.Create(key).Error
+	})
+}
+
+// CacheVaultKey keeps a client side copy of the key info so the vault can be
+// unlocked while offline.
+func CacheVaultKey(db *gorm.DB, key *VaultKey) error {
+	return db.Save(key).Error
+}
@@ -0,0 +1,309 @@
+package sql_manager
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itsrobel/sync/internal/links"
+	"gorm.io/gorm"
+)
+
+// LinkInfo is a link with both ends resolved to their current locations.
+type LinkInfo struct {
+	Link
+	SourceLocation string
+	TargetLocation string // empty while unresolved
+}
+
+// IndexLinks replaces the outgoing links of a file with the ones in its
+// current content, then resolves links elsewhere in the vault that may now
+// point at it. Callers run it after every new version.
+func IndexLinks(db *gorm.DB, vaultID, fileID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		file, err := FindFileById(tx, vaultID, fileID)
+		if err != nil {
+			return err
+		}
+		locations, err := activeLocations(tx, vaultID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("source_id = ?", file.ID).Delete(&Link{}).Error; err != nil {
+			return err
+		}
+		if file.Active && links.IsNote(file.Location) {
+			var rows []Link
+			for _, parsed := range links.Parse(file.Content) {
+				target := parsed.Target
+				if target == "" {
+					// [[#heading]] points into the note itself
+					target = strings.TrimSuffix(file.Location, path.Ext(file.Location))
+				}
+				row := Link{
+					VaultID:    vaultID,
+					SourceID:   file.ID,
+					TargetName: target,
+					Heading:    parsed.Heading,
+					Alias:      parsed.Alias,
+					Embed:      parsed.Embed,
+					Line:       parsed.Line,
+				}
+				row.TargetID = resolveLink(target, locations)
+				rows = append(rows, row)
+			}
+			if len(rows) > 0 {
+				if err := tx.Create(&rows).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return resolveLinksTo(tx, vaultID, file.ID, file.Location, locations)
+	})
+}
+
+// resolveLinksTo re-resolves the links that are unresolved, name the file's
+// base name or pointed at the file, those are the only ones a new or moved
+// file can change.
+func resolveLinksTo(tx *gorm.DB, vaultID, fileID, location string, locations map[string]string) error {
+	name := strings.ToLower(strings.TrimSuffix(path.Base(location), path.Ext(location)))
+	var candidates []Link
+	err := tx.Where("vault_id = ? AND (target_id = '' OR target_id = ? OR lower(target_name) LIKE ? ESCAPE '\\')", vaultID, fileID, "%"+escapeLike(name)+"%").
+		Find(&candidates).Error
+	if err != nil {
+		return err
+	}
+
+	for _, link := range candidates {
+		targetID := resolveLink(link.TargetName, locations)
+		if targetID == link.TargetID {
+			continue
+		}
+		if err := tx.Model(&Link{}).Where("id = ?", link.ID).Update("target_id", targetID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveLink(target string, locations map[string]string) string {
+	if location, ok := links.Resolve(target, keys(locations)); ok {
+		return locations[location]
+	}
+	return ""
+}
+
+// activeLocations maps the location of every active file to its id.
+func activeLocations(db *gorm.DB, vaultID string) (map[string]string, error) {
+	var files []File
+	if err := db.Select("id, location").Where("vault_id = ? AND active = ?", vaultID, true).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	locations := make(map[string]string, len(files))
+	for _, file := range files {
+		locations[file.Location] = file.ID
+	}
+	return locations, nil
+}
+
+// BackfillLinks indexes every note once when the link table is still empty,
+// for databases created before links were tracked. Encrypted vaults are
+// skipped when skipEncrypted is set, the server only holds their ciphertext.
+func BackfillLinks(db *gorm.DB, skipEncrypted bool) error {
+	var count int64
+	if err := db.Model(&Link{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+
+	var files []File
+	query := db.Select("id, vault_id, location").Where("active = ? AND lower(location) LIKE ?", true, "%.md")
+	if skipEncrypted {
+		query = query.Where("vault_id NOT IN (?)", db.Model(&VaultKey{}).Select("vault_id"))
+	}
+	if err := query.Find(&files).Error; err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := IndexLinks(db, file.VaultID, file.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkInfos loads links with the locations of both ends. Links to files that
+// were deleted count as unresolved.
+func linkInfos(db *gorm.DB) *gorm.DB {
+	return db.Model(&Link{}).
+		Select(`links.*, source.location AS source_location,
+			CASE WHEN target.active THEN target.location ELSE '' END AS target_location`).
+		Joins("JOIN files AS source ON source.id = links.source_id AND source.active = ?", true).
+		Joins("LEFT JOIN files AS target ON CAST(target.id AS TEXT) = links.target_id AND links.target_id <> ''")
+}
+
+// GetOutgoingLinks lists the links in a file in the order they appear.
+func GetOutgoingLinks(db *gorm.DB, vaultID, fileID string) ([]LinkInfo, error) {
+	var infos []LinkInfo
+	err := linkInfos(db).
+		Where("links.vault_id = ? AND links.source_id = ?", vaultID, fileID).
+		Order("links.line, links.id").
+		Scan(&infos).Error
+	return infos, err
+}
+
+// GetBacklinks lists the links from other files pointing at a file.
+func GetBacklinks(db *gorm.DB, vaultID, fileID string) ([]LinkInfo, error) {
+	var infos []LinkInfo
+	err := linkInfos(db).
+		Where("links.vault_id = ? AND links.target_id = ? AND links.source_id <> ? AND target.active = ?", vaultID, fileID, fileID, true).
+		Order("source.location, links.line").
+		Scan(&infos).Error
+	return infos, err
+}
+
+// GetUnresolvedLinks lists links in a vault whose target does not exist,
+// grouped by the name they use.
+func GetUnresolvedLinks(db *gorm.DB, vaultID string) ([]LinkInfo, error) {
+	var infos []LinkInfo
+	err := linkInfos(db).
+		Where("links.vault_id = ? AND (links.target_id = '' OR target.id IS NULL OR NOT target.active)", vaultID).
+		Order("lower(links.target_name), source.location, links.line").
+		Scan(&infos).Error
+	return infos, err
+}
+
+// RewriteLinks updates the notes linking to a renamed file so the links keep
+// working under its new name. The links are the ones that led to the file
+// while it was at from, every changed note gets a new version from client.
+// It returns the notes that changed.
+func RewriteLinks(db *gorm.DB, vaultID, fileID, from, client string) ([]File, error) {
+	var changed []File
+	err := db.Transaction(func(tx *gorm.DB) error {
+		file, err := FindFileById(tx, vaultID, fileID)
+		if err != nil {
+			return err
+		}
+		locations, err := activeLocations(tx, vaultID)
+		if err != nil {
+			return err
+		}
+		// the vault as it was before the rename
+		before := make(map[string]string, len(locations))
+		for location, id := range locations {
+			if id != fileID {
+				before[location] = id
+			}
+		}
+		before[from] = fileID
+
+		name := strings.ToLower(strings.TrimSuffix(path.Base(from), path.Ext(from)))
+		var candidates []LinkInfo
+		err = linkInfos(tx).
+			Where("links.vault_id = ? AND links.source_id <> ? AND lower(links.target_name) LIKE ? ESCAPE '\\'", vaultID, fileID, "%"+escapeLike(name)+"%").
+			Scan(&candidates).Error
+		if err != nil {
+			return err
+		}
+
+		// names that no longer lead to the file, per linking note
+		stale := make(map[string]map[string]bool)
+		for _, link := range candidates {
+			if resolveLink(link.TargetName, before) != fileID || resolveLink(link.TargetName, locations) == fileID {
+				continue
+			}
+			if stale[link.SourceID] == nil {
+				stale[link.SourceID] = make(map[string]bool)
+			}
+			stale[link.SourceID][strings.ToLower(link.TargetName)] = true
+		}
+
+		newName := linkName(file.Location, locations)
+		for sourceID, names := range stale {
+			source, err := FindFileById(tx, vaultID, sourceID)
+			if err != nil {
+				return err
+			}
+			content, n := links.Rewrite(source.Content, func(link links.Link) (string, bool) {
+				if !names[strings.ToLower(link.Target)] {
+					return "", false
+				}
+				if strings.Contains(link.Target, "/") {
+					return linkPath(file.Location), true
+				}
+				return newName, true
+			})
+			if n == 0 {
+				continue
+			}
+
+			version := FileVersion{
+				FileBase:  FileBase{ID: uuid.NewString()},
+				VaultID:   vaultID,
+				Timestamp: time.Now(),
+				Client:    client,
+				Location:  source.Location,
+				Content:   content,
+				FileID:    source.ID,
+				Size:      int64(len(content)),
+			}
+			// a note stored since it was read keeps its new content, its
+			// links are looked at again when the next rename happens
+			result := tx.Model(&File{}).
+				Where("id = ? AND vault_id = ? AND COALESCE(head_version_id, '') = ?", source.ID, vaultID, source.HeadVersionID).
+				Updates(map[string]interface{}{
+					"content":         content,
+					"size":            version.Size,
+					"timestamp":       version.Timestamp,
+					"head_version_id": version.ID,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			if err := tx.Create(&version).Error; err != nil {
+				return err
+			}
+			if err := IndexLinks(tx, vaultID, source.ID); err != nil {
+				return err
+			}
+			if err := IndexMeta(tx, vaultID, source.ID); err != nil {
+				return err
+			}
+			source.Content = content
+			changed = append(changed, *source)
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// linkName is the shortest link text that resolves to location: the bare
+// note name unless another file claims it first.
+func linkName(location string, locations map[string]string) string {
+	name := linkPath(path.Base(location))
+	if resolved, ok := links.Resolve(name, keys(locations)); ok && resolved == location {
+		return name
+	}
+	return linkPath(location)
+}
+
+// linkPath drops the .md extension, links to notes are written without it.
+func linkPath(location string) string {
+	if links.IsNote(location) {
+		return strings.TrimSuffix(location, path.Ext(location))
+	}
+	return location
+}
+
+func keys(locations map[string]string) []string {
+	all := make([]string, 0, len(locations))
+	for location := range locations {
+		all = append(all, location)
+	}
+	return all
+}
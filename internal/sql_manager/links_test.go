@@ -0,0 +1,64 @@
+package sql_manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// storeNote stores a version of a note and indexes its links.
+func storeNote(t *testing.T, db *gorm.DB, fileID, location, content string) {
+	t.Helper()
+	store(t, db, fileID, location, content, time.Now(), "")
+	if err := IndexLinks(db, DefaultVault, fileID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameResolvesLinksAgain(t *testing.T) {
+	db := serverDB(t)
+	target, source := uuid.NewString(), uuid.NewString()
+	storeNote(t, db, target, "a.md", "target")
+	storeNote(t, db, source, "b.md", "see [[a]]")
+	if backlinks, err := GetBacklinks(db, DefaultVault, target); err != nil || len(backlinks) != 1 {
+		t.Fatalf("backlinks before the rename = %v, %v", backlinks, err)
+	}
+
+	storeNote(t, db, target, "c.md", "target")
+	if backlinks, err := GetBacklinks(db, DefaultVault, target); err != nil || len(backlinks) != 0 {
+		t.Errorf("[[a]] still leads to c.md: %v, %v", backlinks, err)
+	}
+	unresolved, err := GetUnresolvedLinks(db, DefaultVault)
+	if err != nil || len(unresolved) != 1 || unresolved[0].TargetName != "a" {
+		t.Errorf("unresolved links = %v, %v", unresolved, err)
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	db := serverDB(t)
+	target, source := uuid.NewString(), uuid.NewString()
+	storeNote(t, db, target, "a.md", "target")
+	storeNote(t, db, source, "b.md", "see [[a]] and [[a#top|here]]")
+	storeNote(t, db, target, "notes/c.md", "target")
+
+	changed, err := RewriteLinks(db, DefaultVault, target, "a.md", "system")
+	if err != nil || len(changed) != 1 {
+		t.Fatalf("RewriteLinks = %v, %v", changed, err)
+	}
+	file, err := FindFileById(db, DefaultVault, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "see [[c]] and [[c#top|here]]"; file.Content != want {
+		t.Errorf("b.md holds %q, want %q", file.Content, want)
+	}
+	head, err := GetHeadVersion(db, file)
+	if err != nil || head.Client != "system" || head.Size != int64(len(file.Content)) {
+		t.Errorf("rewritten version = %+v, %v", head, err)
+	}
+	if backlinks, err := GetBacklinks(db, DefaultVault, target); err != nil || len(backlinks) != 2 {
+		t.Errorf("backlinks after the rewrite = %v, %v", backlinks, err)
+	}
+}
@@ -0,0 +1,275 @@
+package sql_manager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itsrobel/sync/internal/frontmatter"
+	"github.com/itsrobel/sync/internal/links"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// NoteInfo is a note matched by QueryNotes with its metadata.
+type NoteInfo struct {
+	FileID    string
+	Location  string
+	Timestamp time.Time
+	Tags      []string
+	Fields    []frontmatter.Field
+}
+
+// IndexMeta replaces the tags and front matter fields of a file with the ones
+// in its current content. Callers run it after every new version.
+func IndexMeta(db *gorm.DB, vaultID, fileID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		file, err := FindFileById(tx, vaultID, fileID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&NoteTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&NoteField{}).Error; err != nil {
+			return err
+		}
+		if !file.Active || !links.IsNote(file.Location) {
+			return nil
+		}
+
+		meta := frontmatter.Parse(file.Content)
+		if len(meta.Tags) > 0 {
+			tags := make([]NoteTag, len(meta.Tags))
+			for i, tag := range meta.Tags {
+				tags[i] = NoteTag{VaultID: vaultID, FileID: file.ID, Tag: tag}
+			}
+			if err := tx.Create(&tags).Error; err != nil {
+				return err
+			}
+		}
+		if len(meta.Fields) > 0 {
+			fields := make([]NoteField, len(meta.Fields))
+			for i, field := range meta.Fields {
+				fields[i] = NoteField{VaultID: vaultID, FileID: file.ID, Key: field.Key, Value: field.Value}
+			}
+			if err := tx.Create(&fields).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BackfillMeta indexes every note once when no metadata is stored yet, for
+// databases created before it was tracked. Encrypted vaults are skipped when
+// skipEncrypted is set.
+func BackfillMeta(db *gorm.DB, skipEncrypted bool) error {
+	var tags, fields int64
+	if err := db.Model(&NoteTag{}).Count(&tags).Error; err != nil || tags > 0 {
+		return err
+	}
+	if err := db.Model(&NoteField{}).Count(&fields).Error; err != nil || fields > 0 {
+		return err
+	}
+
+	var files []File
+	query := db.Select("id, vault_id").Where("active = ? AND lower(location) LIKE ?", true, "%.md")
+	if skipEncrypted {
+		query = query.Where("vault_id NOT IN (?)", db.Model(&VaultKey{}).Select("vault_id"))
+	}
+	if err := query.Find(&files).Error; err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := IndexMeta(db, file.VaultID, file.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NoteQuery is a parsed query, every term has to match.
+type NoteQuery struct {
+	terms []queryTerm
+}
+
+type queryTerm struct {
+	key    string // tag, path, modified, a front matter key, or empty for a name
+	op     string // ":", ">", ">=", "<" or "<="
+	value  string
+	negate bool
+}
+
+// ParseQuery reads a query like `tag:project status:done modified>2026-01-01`.
+// Terms are
+//
+//	tag:name        the note has the tag or one nested below it
+//	path:folder     the note is below folder
+//	modified>date   last change, also >=, < and <=
+//	key:value       a front matter field equals value, ignoring case
+//	key>value       a field compares as text, which orders ISO dates
+//	word            the location contains word
+//
+// A leading - negates a term, values with spaces go in double quotes.
+func ParseQuery(query string) (*NoteQuery, error) {
+	words, err := splitQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("query is empty")
+	}
+
+	parsed := &NoteQuery{}
+	for _, word := range words {
+		term := queryTerm{}
+		if strings.HasPrefix(word, "-") && len(word) > 1 {
+			term.negate = true
+			word = word[1:]
+		}
+		if i := strings.IndexAny(word, ":<>"); i > 0 {
+			term.key = strings.ToLower(word[:i])
+			term.op = word[i : i+1]
+			rest := word[i+1:]
+			if term.op != ":" && strings.HasPrefix(rest, "=") {
+				term.op += "="
+				rest = rest[1:]
+			}
+			term.value = strings.Trim(rest, `"`)
+			if term.value == "" {
+				return nil, fmt.Errorf("%q has no value", word)
+			}
+		} else {
+			term.value = strings.Trim(word, `"`)
+		}
+
+		switch {
+		case term.key == "modified":
+			if term.op == ":" {
+				return nil, fmt.Errorf("modified needs >, >=, < or <=")
+			}
+			if _, err := parseQueryTime(term.value); err != nil {
+				return nil, err
+			}
+		case (term.key == "tag" || term.key == "path") && term.op != ":":
+			return nil, fmt.Errorf("%s only supports %s:", term.key, term.key)
+		}
+		parsed.terms = append(parsed.terms, term)
+	}
+	return parsed, nil
+}
+
+// splitQuery splits on spaces outside of double quotes.
+func splitQuery(query string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			word.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func parseQueryTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, use 2006-01-02 or RFC 3339", value)
+}
+
+// QueryNotes lists the active notes of a vault matching a query, ordered by
+// location, with their tags and fields.
+func QueryNotes(db *gorm.DB, vaultID string, query *NoteQuery, limit int) ([]NoteInfo, error) {
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+
+	tx := db.Model(&File{}).
+		Select("files.id AS file_id, files.location, files.timestamp").
+		Where("files.vault_id = ? AND files.active = ? AND lower(files.location) LIKE ?", vaultID, true, "%.md")
+	for _, term := range query.terms {
+		clause, args := term.sql()
+		if term.negate {
+			clause = "NOT (" + clause + ")"
+		}
+		tx = tx.Where(clause, args...)
+	}
+
+	var notes []NoteInfo
+	if err := tx.Order("files.location").Limit(limit).Scan(&notes).Error; err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	if len(notes) == 0 {
+		return notes, nil
+	}
+
+	byID := make(map[string]*NoteInfo, len(notes))
+	ids := make([]string, len(notes))
+	for i := range notes {
+		byID[notes[i].FileID] = &notes[i]
+		ids[i] = notes[i].FileID
+	}
+	var tags []NoteTag
+	if err := db.Where("file_id IN ?", ids).Order("tag").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		byID[tag.FileID].Tags = append(byID[tag.FileID].Tags, tag.Tag)
+	}
+	var fields []NoteField
+	if err := db.Where("file_id IN ?", ids).Order("id").Find(&fields).Error; err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		byID[field.FileID].Fields = append(byID[field.FileID].Fields, frontmatter.Field{Key: field.Key, Value: field.Value})
+	}
+	return notes, nil
+}
+
+func (term queryTerm) sql() (string, []interface{}) {
+	value := strings.ToLower(term.value)
+	switch term.key {
+	case "":
+		return "lower(files.location) LIKE ? ESCAPE '\\'", []interface{}{"%" + escapeLike(value) + "%"}
+	case "tag":
+		value = strings.Trim(strings.TrimPrefix(value, "#"), "/")
+		return "EXISTS (SELECT 1 FROM note_tags WHERE note_tags.file_id = files.id AND (note_tags.tag = ? OR note_tags.tag LIKE ? ESCAPE '\\'))",
+			[]interface{}{value, escapeLike(value) + "/%"}
+	case "path":
+		folder := strings.TrimSuffix(strings.TrimPrefix(term.value, "/"), "/") + "/"
+		return "files.location LIKE ? ESCAPE '\\'", []interface{}{escapeLike(folder) + "%"}
+	case "modified":
+		t, _ := parseQueryTime(term.value)
+		return "files.timestamp " + term.op + " ?", []interface{}{t}
+	}
+	if term.op == ":" {
+		return "EXISTS (SELECT 1 FROM note_fields WHERE note_fields.file_id = files.id AND note_fields.key = ? AND lower(note_fields.value) = ?)",
+			[]interface{}{term.key, value}
+	}
+	return "EXISTS (SELECT 1 FROM note_fields WHERE note_fields.file_id = files.id AND note_fields.key = ? AND note_fields.value " + term.op + " ?)",
+		[]interface{}{term.key, term.value}
+}
@@ -1,136 +1,376 @@
 package sql_manager
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/google/uuid"
 	ft "github.com/itsrobel/sync/internal/services/filetransfer"
 	"gorm.io/gorm"
 )
 
-func CreateFileInitial(db *gorm.DB, location string) (*File, error) {
+func CreateFileInitial(db *gorm.DB, vaultID, location string) (*File, error) {
 	file := &File{
-		Location: location,
-		Active:   true,
-		Content:  "",
+		VaultID:   vaultID,
+		Location:  location,
+		Active:    true,
+		Content:   "",
+		Timestamp: time.Now(),
+	}
+
+	result := db.Create(file)
+	return file, result.Error
+}
+
+// CreateFileRemote records a file that was first created on another client,
+// keeping the server assigned ID.
+func CreateFileRemote(db *gorm.DB, vaultID, id, location string) (*File, error) {
+	file := &File{
+		FileBase:  FileBase{ID: id},
+		VaultID:   vaultID,
+		Location:  location,
+		Active:    true,
+		Content:   "",
+		Timestamp: time.Now(),
 	}
 
 	result := db.Create(file)
 	return file, result.Error
 }
 
-func CreateFileVersion(db *gorm.DB, file *File, newContent string) (*FileVersion, error) {
+func CreateFileVersion(db *gorm.DB, file *File, newContent, client string) (*FileVersion, error) {
 	fileVersion := &FileVersion{
+		VaultID:   file.VaultID,
 		Timestamp: time.Now(),
+		Client:    client,
 		Location:  file.Location,
 		Content:   newContent,
 		FileID:    file.ID,
+		Size:      int64(len(newContent)),
 	}
+	return fileVersion, saveVersion(db, file, fileVersion)
+}
 
-	err := db.Transaction(func(tx *gorm.DB) error {
+// CreateBlobVersion records a new version of an attachment, its content
+// stays on disk.
+func CreateBlobVersion(db *gorm.DB, file *File, size int64, hash, client string) (*FileVersion, error) {
+	fileVersion := &FileVersion{
+		VaultID:   file.VaultID,
+		Timestamp: time.Now(),
+		Client:    client,
+		Location:  file.Location,
+		FileID:    file.ID,
+		Blob:      true,
+		Size:      size,
+		Hash:      hash,
+	}
+	return fileVersion, saveVersion(db, file, fileVersion)
+}
+
+func saveVersion(db *gorm.DB, file *File, fileVersion *FileVersion) error {
+	return db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(fileVersion).Error; err != nil {
 			return err
 		}
-		return tx.Model(file).Update("content", newContent).Error
+		if err := tx.Model(file).Updates(map[string]interface{}{
+			"content":   fileVersion.Content,
+			"timestamp": fileVersion.Timestamp,
+			"blob":      fileVersion.Blob,
+			"size":      fileVersion.Size,
+			"hash":      fileVersion.Hash,
+			"remote":    false,
+		}).Error; err != nil {
+			return err
+		}
+		if err := IndexLinks(tx, file.VaultID, file.ID); err != nil {
+			return err
+		}
+		return IndexMeta(tx, file.VaultID, file.ID)
 	})
+}
 
-	return fileVersion, err
+// SetRemoteBlob records what the server holds for an attachment that is only
+// downloaded on demand. No version is recorded, so fetching it later is seen
+// as a new download.
+func SetRemoteBlob(db *gorm.DB, file *File, size int64, hash string) error {
+	return db.Model(file).Updates(map[string]interface{}{
+		"content":   "",
+		"timestamp": time.Now(),
+		"blob":      true,
+		"size":      size,
+		"hash":      hash,
+		"remote":    true,
+	}).Error
 }
 
-func CreateFileVersionServer(db *gorm.DB, file *ft.FileVersionData) error {
-	fileVersion := FileVersion{
-		// FileBase:  FileBase{ID: uuid.NewString()},
-		Timestamp: file.Timestamp.AsTime(),
-		Location:  file.Location,
-		Content:   string(file.Content),
-		FileID:    file.FileId,
+// GetRemoteFiles lists the attachments at or below location that were not
+// downloaded yet, "." lists all of them.
+func GetRemoteFiles(db *gorm.DB, vaultID, location string) ([]File, error) {
+	var files []File
+	query := db.Where("vault_id = ? AND active = ? AND remote = ?", vaultID, true, true)
+	if location != "." {
+		query = query.Where("(location = ? OR location LIKE ? ESCAPE '\\')", location, escapeLike(location)+"/%")
 	}
+	err := query.Order("location").Find(&files).Error
+	return files, err
+}
 
-	result := db.Create(&fileVersion)
-	if result.Error != nil {
-		log.Printf("Error creating file version: %v", result.Error)
-		return result.Error
-	}
+// RenameFile moves a file record to a new location, keeping its ID so
+// versions and links follow it.
+func RenameFile(db *gorm.DB, file *File, location string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(file).Update("location", location).Error; err != nil {
+			return err
+		}
+		file.Location = location
+		return IndexLinks(tx, file.VaultID, file.ID)
+	})
+}
 
-	log.Printf("Created file version with ID: %s at %s", fileVersion.ID, file.Location)
-	return nil
+func CreateTombstone(db *gorm.DB, vaultID, fileID, location, renamedTo string) error {
+	return db.Create(&Tombstone{
+		VaultID:   vaultID,
+		FileID:    fileID,
+		Location:  location,
+		RenamedTo: renamedTo,
+	}).Error
 }
 
-func UpdateFileServer(db *gorm.DB, file *File) error {
-	var existingFile File
+// ErrHeadMoved is returned when a file changed after the version a write
+// started from.
+var ErrHeadMoved = errors.New("file changed since its base version")
+
+// StoreVersionServer stores an uploaded version under the id the client gave
+// it, so the client can recognise its own versions when they come back, and
+// makes it the head of its file in the same transaction. With base set the
+// head has to still be base or nothing is stored and ErrHeadMoved returned,
+// files without a recorded head compare their newest version like
+// GetHeadVersion does. Uploads from devices pass no base, the newest upload
+// becomes the head. Uploading the same version twice stores nothing and
+// reports false.
+func StoreVersionServer(db *gorm.DB, data *ft.FileVersionData, base string) (bool, error) {
+	if _, err := uuid.Parse(data.Id); err != nil {
+		data.Id = uuid.NewString()
+	}
+	stored := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if exists, err := VersionExists(tx, data.Id); err != nil || exists {
+			return err
+		}
 
-	log.Printf("Trying to update file with ID :%s", file.ID)
-	result := db.Where("id = ?", file.ID).First(&existingFile)
-	if result.Error == gorm.ErrRecordNotFound {
-		// Create new file if not found
-		newFile := File{
-			FileBase: FileBase{ID: file.ID},
-			Location: file.Location,
-			Content:  file.Content,
-			Active:   file.Active,
+		// the head is read before the new version exists, the update below
+		// only applies while it is still the same
+		current := ""
+		if base != "" {
+			file, err := FindFileById(tx, data.VaultId, data.FileId)
+			if err == gorm.ErrRecordNotFound {
+				return ErrHeadMoved
+			} else if err != nil {
+				return err
+			}
+			head, err := GetHeadVersion(tx, file)
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+			if err != nil || head.ID != base {
+				return ErrHeadMoved
+			}
+			current = file.HeadVersionID
 		}
-		if err := db.Create(&newFile).Error; err != nil {
-			return fmt.Errorf("failed to create file: %v", err)
+
+		version := FileVersion{
+			FileBase:  FileBase{ID: data.Id},
+			VaultID:   data.VaultId,
+			Timestamp: data.Timestamp.AsTime(),
+			Client:    data.Client,
+			Location:  data.Location,
+			Content:   string(data.Content),
+			FileID:    data.FileId,
+			Blob:      data.Blob,
+			Size:      data.TotalSize,
+			Hash:      data.Hash,
+		}
+		if data.Blob {
+			version.Content = ""
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return fmt.Errorf("failed to create file version: %w", err)
 		}
-		log.Printf("Created new file with ID: %s at %s", file.ID, file.Location)
-		return nil
-	} else if result.Error != nil {
-		return fmt.Errorf("failed to query file: %v", result.Error)
-	}
 
-	// Update existing file
-	result = db.Model(&existingFile).Updates(map[string]interface{}{
-		"location": file.Location,
-		"content":  file.Content,
-		"active":   file.Active,
+		update := tx.Model(&File{}).Where("id = ? AND vault_id = ?", data.FileId, data.VaultId)
+		if base != "" {
+			update = update.Where("COALESCE(head_version_id, '') = ?", current)
+		}
+		result := update.Updates(map[string]interface{}{
+			"location":        version.Location,
+			"content":         version.Content,
+			"active":          true,
+			"timestamp":       time.Now(),
+			"blob":            version.Blob,
+			"size":            version.Size,
+			"hash":            version.Hash,
+			"head_version_id": version.ID,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update file: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			if base != "" {
+				return ErrHeadMoved
+			}
+			if err := tx.Create(&File{
+				FileBase:      FileBase{ID: data.FileId},
+				VaultID:       data.VaultId,
+				Location:      version.Location,
+				Content:       version.Content,
+				Active:        true,
+				Timestamp:     time.Now(),
+				Blob:          version.Blob,
+				Size:          version.Size,
+				Hash:          version.Hash,
+				HeadVersionID: version.ID,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to create file: %w", err)
+			}
+		}
+		stored = true
+		return nil
 	})
+	if stored {
+		log.Printf("Stored version %s of %s", data.Id, data.Location)
+	}
+	return stored, err
+}
 
-	if result.Error != nil {
-		return fmt.Errorf("failed to update file: %v", result.Error)
+// GetHeadVersion returns the version a file holds on the server. Files
+// without a recorded head fall back to the newest version.
+func GetHeadVersion(db *gorm.DB, file *File) (*FileVersion, error) {
+	if file.HeadVersionID == "" {
+		return GetLatestVersion(db, file.VaultID, file.ID)
 	}
+	return GetVersionById(db, file.HeadVersionID)
+}
 
-	log.Printf("Updated file with ID: %s at %s", file.ID, file.Location)
+// backfillHeads points files stored before heads were recorded at their
+// newest version.
+func backfillHeads(db *gorm.DB) error {
+	err := db.Exec(`UPDATE files SET head_version_id = (
+		SELECT v.id FROM file_versions v
+		WHERE v.vault_id = files.vault_id AND v.file_id = files.id
+		ORDER BY v.timestamp DESC, v.id DESC LIMIT 1
+	) WHERE COALESCE(head_version_id, '') = ''`).Error
+	if err != nil {
+		return fmt.Errorf("failed to backfill file heads: %w", err)
+	}
 	return nil
 }
 
-func GetLatestVersion(db *gorm.DB, fileID string) (*FileVersion, error) {
+func GetLatestVersion(db *gorm.DB, vaultID, fileID string) (*FileVersion, error) {
 	var version FileVersion
-	err := db.Where("file_id = ?", fileID).
+	err := db.Where("vault_id = ? AND file_id = ?", vaultID, fileID).
 		Order("timestamp desc").
 		First(&version).Error
 	return &version, err
 }
 
-func FindFileById(db *gorm.DB, id string) (*File, error) {
+func FindFileById(db *gorm.DB, vaultID, id string) (*File, error) {
 	var file File
-	err := db.First(&file, "id = ?", id).Error
+	err := db.First(&file, "vault_id = ? AND id = ?", vaultID, id).Error
 	return &file, err
 }
 
 // TODO: make sure to create before trying to find by location
-func FindFileByLocation(db *gorm.DB, location string) (*File, error) {
+func FindFileByLocation(db *gorm.DB, vaultID, location string) (*File, error) {
 	var file File
-	err := db.First(&file, "location = ?", location).Error
+	err := db.First(&file, "vault_id = ? AND location = ?", vaultID, location).Error
 	return &file, err
 }
 
-func GetAllFiles(db *gorm.DB) ([]File, error) {
+// FindActiveFileByLocation skips files that were deleted at location.
+func FindActiveFileByLocation(db *gorm.DB, vaultID, location string) (*File, error) {
+	var file File
+	err := db.First(&file, "vault_id = ? AND location = ? AND active = ?", vaultID, location, true).Error
+	return &file, err
+}
+
+func GetAllFiles(db *gorm.DB, vaultID string) ([]File, error) {
 	var files []File
-	err := db.Find(&files).Error
+	err := db.Where("vault_id = ?", vaultID).Find(&files).Error
 	return files, err
 }
 
-func GetAllFileVersions(db *gorm.DB, fileID string) ([]FileVersion, error) {
+// GetActiveFiles returns the active files of a vault whose location include
+// accepts, as they are now. GetVersionsAt looks back in time.
+func GetActiveFiles(db *gorm.DB, vaultID string, include func(location string) bool) ([]FileVersion, error) {
+	files, err := GetAllFiles(db, vaultID)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []FileVersion
+	for _, file := range files {
+		if !file.Active || !include(file.Location) {
+			continue
+		}
+		found = append(found, FileVersion{
+			VaultID:   file.VaultID,
+			Timestamp: file.Timestamp,
+			Location:  file.Location,
+			Content:   file.Content,
+			FileID:    file.ID,
+			Blob:      file.Blob,
+			Size:      file.Size,
+			Hash:      file.Hash,
+		})
+	}
+	return found, nil
+}
+
+// GetVersionsAt returns, for every file of a vault, the newest version
+// stored at or before at, when its location at the time is one include
+// accepts. It goes by the history alone, so files moved or deleted since
+// are found where they were, and files moved into place since are not.
+func GetVersionsAt(db *gorm.DB, vaultID string, at time.Time, include func(location string) bool) ([]FileVersion, error) {
+	var heads []FileVersion
+	err := db.Model(&FileVersion{}).
+		Select("id, location").
+		Where("vault_id = ? AND timestamp <= ?", vaultID, at).
+		Where(`NOT EXISTS (SELECT 1 FROM file_versions AS newer
+			WHERE newer.vault_id = file_versions.vault_id AND newer.file_id = file_versions.file_id AND newer.timestamp <= ?
+			AND (newer.timestamp > file_versions.timestamp OR (newer.timestamp = file_versions.timestamp AND newer.id > file_versions.id)))`, at).
+		Find(&heads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, head := range heads {
+		if include(head.Location) {
+			ids = append(ids, head.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	// contents are only loaded for the versions that are kept
+	var found []FileVersion
+	err = db.Where("id IN ?", ids).Find(&found).Error
+	return found, err
+}
+
+// GetAllFileVersions returns the history of a file, oldest first.
+func GetAllFileVersions(db *gorm.DB, vaultID, fileID string) ([]FileVersion, error) {
 	var versions []FileVersion
-	err := db.Where("file_id = ?", fileID).Find(&versions).Error
+	err := db.Where("vault_id = ? AND file_id = ?", vaultID, fileID).Order("timestamp").Find(&versions).Error
 	return versions, err
 }
 
-func DeleteAllFiles(db *gorm.DB) error {
-	return db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&File{}).Error
+func DeleteAllFiles(db *gorm.DB, vaultID string) error {
+	return db.Where("vault_id = ?", vaultID).Delete(&File{}).Error
 }
 
-func DeleteAllFileVersions(db *gorm.DB) error {
-	return db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&FileVersion{}).Error
+func DeleteAllFileVersions(db *gorm.DB, vaultID string) error {
+	return db.Where("vault_id = ?", vaultID).Delete(&FileVersion{}).Error
 }
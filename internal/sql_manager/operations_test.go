@@ -0,0 +1,137 @@
+package sql_manager
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestStoreVersionServer(t *testing.T) {
+	db := serverDB(t)
+	fileID := uuid.NewString()
+	first := store(t, db, fileID, "a.md", "one", time.Now(), "")
+	second := store(t, db, fileID, "a.md", "two", time.Now(), first)
+
+	// a writer that started from the first version lost the race
+	stale := &ft.FileVersionData{Id: uuid.NewString(), VaultId: DefaultVault, FileId: fileID, Location: "a.md", Content: []byte("stale"), Timestamp: timestamppb.Now()}
+	if _, err := StoreVersionServer(db, stale, first); !errors.Is(err, ErrHeadMoved) {
+		t.Fatalf("stale write: %v, want ErrHeadMoved", err)
+	}
+	if exists, _ := VersionExists(db, stale.Id); exists {
+		t.Error("the version of a stale write was kept")
+	}
+
+	// the same upload again stores nothing
+	again := &ft.FileVersionData{Id: second, VaultId: DefaultVault, FileId: fileID, Location: "a.md", Content: []byte("other"), Timestamp: timestamppb.Now()}
+	if stored, err := StoreVersionServer(db, again, ""); err != nil || stored {
+		t.Errorf("repeated upload = %v, %v", stored, err)
+	}
+
+	file, err := FindFileById(db, DefaultVault, fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Content != "two" || file.HeadVersionID != second {
+		t.Errorf("file holds %q at %s, want the second version", file.Content, file.HeadVersionID)
+	}
+	if head, err := GetHeadVersion(db, file); err != nil || head.ID != second {
+		t.Errorf("GetHeadVersion = %v, %v", head, err)
+	}
+}
+
+func TestStoreVersionServerNewFileWithBase(t *testing.T) {
+	db := serverDB(t)
+	data := &ft.FileVersionData{Id: uuid.NewString(), VaultId: DefaultVault, FileId: uuid.NewString(), Location: "a.md", Timestamp: timestamppb.Now()}
+	if _, err := StoreVersionServer(db, data, uuid.NewString()); !errors.Is(err, ErrHeadMoved) {
+		t.Errorf("write based on a file that does not exist: %v", err)
+	}
+}
+
+func TestStoreVersionServerWithoutHead(t *testing.T) {
+	db := serverDB(t)
+	fileID := uuid.NewString()
+	first := store(t, db, fileID, "a.md", "one", time.Now(), "")
+	// files stored before heads were recorded
+	if err := db.Exec("UPDATE files SET head_version_id = ''").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stale := &ft.FileVersionData{Id: uuid.NewString(), VaultId: DefaultVault, FileId: fileID, Location: "a.md", Timestamp: timestamppb.Now()}
+	if _, err := StoreVersionServer(db, stale, uuid.NewString()); !errors.Is(err, ErrHeadMoved) {
+		t.Errorf("write based on another version: %v, want ErrHeadMoved", err)
+	}
+	second := store(t, db, fileID, "a.md", "two", time.Now(), first)
+	if file, err := FindFileById(db, DefaultVault, fileID); err != nil || file.HeadVersionID != second {
+		t.Errorf("head after the edit = %v, %v", file, err)
+	}
+}
+
+func TestMigrateServerBackfillsHeadsByVault(t *testing.T) {
+	db := serverDB(t)
+	fileID := uuid.NewString()
+	first := store(t, db, fileID, "a.md", "one", time.Now(), "")
+	// a database from before vaults and heads
+	for _, stmt := range []string{
+		"UPDATE files SET vault_id = NULL, head_version_id = NULL",
+		"UPDATE file_versions SET vault_id = NULL",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MigrateServer(db); err != nil {
+		t.Fatal(err)
+	}
+	file, err := FindFileById(db, DefaultVault, fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.HeadVersionID != first {
+		t.Errorf("head after the upgrade = %q, want %s", file.HeadVersionID, first)
+	}
+}
+
+func TestGetVersionsAt(t *testing.T) {
+	db := serverDB(t)
+	start := time.Now().Add(-time.Hour)
+	a, b := uuid.NewString(), uuid.NewString()
+	store(t, db, a, "notes/a.md", "a1", start, "")
+	store(t, db, b, "notes/b.md", "b1", start.Add(time.Minute), "")
+	pinned := start.Add(2 * time.Minute)
+	head, _ := FindFileById(db, DefaultVault, a)
+	store(t, db, a, "notes/a.md", "a2", start.Add(3*time.Minute), head.HeadVersionID)
+	head, _ = FindFileById(db, DefaultVault, b)
+	store(t, db, b, "elsewhere/b.md", "b2", start.Add(4*time.Minute), head.HeadVersionID)
+	store(t, db, uuid.NewString(), "notes/c.md", "c1", start.Add(5*time.Minute), "")
+
+	inNotes := func(location string) bool { return filepath.Dir(location) == "notes" }
+	versions, err := GetVersionsAt(db, DefaultVault, pinned, inNotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, v := range versions {
+		got[v.Location] = v.Content
+	}
+	// b is found where it was, c did not exist yet
+	if len(got) != 2 || got["notes/a.md"] != "a1" || got["notes/b.md"] != "b1" {
+		t.Errorf("GetVersionsAt = %v", got)
+	}
+
+	active, err := GetActiveFiles(db, DefaultVault, inNotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = make(map[string]string)
+	for _, v := range active {
+		got[v.Location] = v.Content
+	}
+	if len(got) != 2 || got["notes/a.md"] != "a2" || got["notes/c.md"] != "c1" {
+		t.Errorf("GetActiveFiles = %v", got)
+	}
+}
@@ -0,0 +1,88 @@
+package sql_manager
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func EnqueueVersion(db *gorm.DB, version *FileVersion) error {
+	return db.Create(&OutboxEntry{
+		VersionID: version.ID,
+		VaultID:   version.VaultID,
+		FileID:    version.FileID,
+		Location:  version.Location,
+	}).Error
+}
+
+func GetPending(db *gorm.DB, vaultID string) ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+	err := db.Where("vault_id = ?", vaultID).Order("created_at").Find(&entries).Error
+	return entries, err
+}
+
+func HasPending(db *gorm.DB, vaultID, fileID string) (bool, error) {
+	var count int64
+	err := db.Model(&OutboxEntry{}).Where("vault_id = ? AND file_id = ?", vaultID, fileID).Count(&count).Error
+	return count > 0, err
+}
+
+func MarkUploaded(db *gorm.DB, versionID string) error {
+	return db.Delete(&OutboxEntry{}, "version_id = ?", versionID).Error
+}
+
+func MarkFailed(db *gorm.DB, versionID string, cause error) error {
+	return db.Model(&OutboxEntry{}).Where("version_id = ?", versionID).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": cause.Error(),
+	}).Error
+}
+
+// DropPending discards queued versions of a file, used once they have been
+// moved aside as a conflict copy.
+func DropPending(db *gorm.DB, vaultID, fileID string) error {
+	return db.Delete(&OutboxEntry{}, "vault_id = ? AND file_id = ?", vaultID, fileID).Error
+}
+
+func GetVersionById(db *gorm.DB, id string) (*FileVersion, error) {
+	var version FileVersion
+	err := db.First(&version, "id = ?", id).Error
+	return &version, err
+}
+
+// VersionExists reports whether a version id is already stored.
+func VersionExists(db *gorm.DB, id string) (bool, error) {
+	var count int64
+	err := db.Model(&FileVersion{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func CreateConflict(db *gorm.DB, conflict *Conflict) error {
+	return db.Create(conflict).Error
+}
+
+func GetConflicts(db *gorm.DB, vaultID string) ([]Conflict, error) {
+	var conflicts []Conflict
+	err := db.Where("vault_id = ?", vaultID).Order("created_at").Find(&conflicts).Error
+	return conflicts, err
+}
+
+func DeleteConflict(db *gorm.DB, id string) error {
+	return db.Delete(&Conflict{}, "id = ?", id).Error
+}
+
+// GetSyncState returns the zero time when the vault never caught up.
+func GetSyncState(db *gorm.DB, vaultID string) (time.Time, error) {
+	var state SyncState
+	err := db.Where("vault_id = ?", vaultID).Limit(1).Find(&state).Error
+	return state.LastSync, err
+}
+
+func SetSyncState(db *gorm.DB, vaultID string, at time.Time) error {
+	// updated in place, the row also holds the cached sync rules
+	result := db.Model(&SyncState{}).Where("vault_id = ?", vaultID).Update("last_sync", at)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return db.Create(&SyncState{VaultID: vaultID, LastSync: at}).Error
+}
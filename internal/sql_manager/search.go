@@ -0,0 +1,200 @@
+package sql_manager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const DefaultSearchLimit = 20
+
+// SearchFilter narrows a search, zero values mean no restriction.
+type SearchFilter struct {
+	Folder         string
+	Tag            string // the note has the tag or one nested below it
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	Limit          int
+}
+
+type SearchHit struct {
+	FileID    string
+	Location  string
+	Snippet   string // matches wrapped in <mark></mark>
+	Rank      float64
+	Timestamp time.Time
+}
+
+// SetupSearch prepares full-text search over the files table. Postgres gets a
+// generated tsvector column, SQLite an FTS table kept up to date by triggers.
+// Both follow every write to files, so callers never index anything.
+func SetupSearch(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return setupPostgresSearch(db)
+	case "sqlite":
+		return setupSQLiteSearch(db)
+	}
+	return fmt.Errorf("full-text search is not supported on %s", db.Dialector.Name())
+}
+
+func setupPostgresSearch(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			`ALTER TABLE files ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', coalesce(location, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(content, '')), 'B')
+			) STORED`,
+			`CREATE INDEX IF NOT EXISTS idx_files_search ON files USING GIN (search)`,
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to set up search: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// SQLite builds without the sqlite_fts5 tag only ship FTS4, which is used as
+// a fallback with the same table layout.
+func setupSQLiteSearch(db *gorm.DB) error {
+	var exists int64
+	if err := db.Raw(`SELECT count(*) FROM sqlite_master WHERE name = 'file_search'`).Scan(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`CREATE VIRTUAL TABLE file_search USING fts5(file_id UNINDEXED, location, content)`).Error
+		if err != nil && strings.Contains(err.Error(), "no such module") {
+			err = tx.Exec(`CREATE VIRTUAL TABLE file_search USING fts4(file_id, location, content, notindexed=file_id)`).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set up search: %w", err)
+		}
+
+		for _, stmt := range []string{
+			`CREATE TRIGGER file_search_insert AFTER INSERT ON files BEGIN
+				INSERT INTO file_search (file_id, location, content) VALUES (new.id, new.location, new.content);
+			END`,
+			`CREATE TRIGGER file_search_update AFTER UPDATE OF location, content ON files BEGIN
+				DELETE FROM file_search WHERE file_id = old.id;
+				INSERT INTO file_search (file_id, location, content) VALUES (new.id, new.location, new.content);
+			END`,
+			`CREATE TRIGGER file_search_delete AFTER DELETE ON files BEGIN
+				DELETE FROM file_search WHERE file_id = old.id;
+			END`,
+			`INSERT INTO file_search (file_id, location, content) SELECT id, location, content FROM files`,
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to set up search: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// SearchFiles runs a full-text query over the active files of a vault, best
+// matches first.
+func SearchFiles(db *gorm.DB, vaultID, query string, filter SearchFilter) ([]SearchHit, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultSearchLimit
+	}
+
+	var hits []SearchHit
+	var err error
+	if db.Dialector.Name() == "postgres" {
+		hits, err = searchPostgres(db, vaultID, query, filter)
+	} else {
+		hits, err = searchSQLite(db, vaultID, query, filter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	return hits, nil
+}
+
+func searchPostgres(db *gorm.DB, vaultID, query string, filter SearchFilter) ([]SearchHit, error) {
+	tx := db.Table("files, websearch_to_tsquery('english', ?) AS q", query).
+		Select(`files.id AS file_id, files.location, files.timestamp,
+			ts_rank(files.search, q) AS rank,
+			ts_headline('english', files.content, q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet`).
+		Where("files.vault_id = ? AND files.active AND files.search @@ q", vaultID)
+	tx = applySearchFilter(tx, filter)
+
+	var hits []SearchHit
+	err := tx.Order("rank DESC").Limit(filter.Limit).Scan(&hits).Error
+	return hits, err
+}
+
+func searchSQLite(db *gorm.DB, vaultID, query string, filter SearchFilter) ([]SearchHit, error) {
+	var module string
+	if err := db.Raw(`SELECT sql FROM sqlite_master WHERE name = 'file_search'`).Scan(&module).Error; err != nil {
+		return nil, err
+	}
+
+	// FTS5 has bm25 (lower is better), FTS4 only offers matching
+	selectRank, snippet := "-bm25(file_search)", "snippet(file_search, 2, '<mark>', '</mark>', '…', 16)"
+	if !strings.Contains(strings.ToLower(module), "fts5") {
+		selectRank, snippet = "0", "snippet(file_search, '<mark>', '</mark>', '…', 2, 16)"
+	}
+
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	tx := db.Table("file_search").
+		Joins("JOIN files ON files.id = file_search.file_id").
+		Select("files.id AS file_id, files.location, files.timestamp, "+selectRank+" AS rank, "+snippet+" AS snippet").
+		Where("file_search MATCH ? AND files.vault_id = ? AND files.active", match, vaultID)
+	tx = applySearchFilter(tx, filter)
+
+	var hits []SearchHit
+	err := tx.Order("rank DESC, files.location").Limit(filter.Limit).Scan(&hits).Error
+	return hits, err
+}
+
+func applySearchFilter(tx *gorm.DB, filter SearchFilter) *gorm.DB {
+	if filter.Folder != "" {
+		folder := strings.TrimSuffix(filter.Folder, "/") + "/"
+		tx = tx.Where("files.location LIKE ? ESCAPE '\\'", escapeLike(folder)+"%")
+	}
+	if tag := strings.Trim(strings.TrimPrefix(filter.Tag, "#"), "/"); tag != "" {
+		clause, args := queryTerm{key: "tag", op: ":", value: tag}.sql()
+		tx = tx.Where(clause, args...)
+	}
+	if !filter.ModifiedAfter.IsZero() {
+		tx = tx.Where("files.timestamp > ?", filter.ModifiedAfter)
+	}
+	if !filter.ModifiedBefore.IsZero() {
+		tx = tx.Where("files.timestamp < ?", filter.ModifiedBefore)
+	}
+	return tx
+}
+
+// ftsQuery turns user input into an FTS query matching every word, the last
+// one as a prefix so results show up while typing. Only letters and digits
+// are kept and words are lowercased, so nothing in the input is read as an
+// FTS operator.
+func ftsQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,80 @@
+package sql_manager
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSearchFiles(t *testing.T) {
+	db := serverDB(t)
+	now := time.Now()
+	tagged := uuid.NewString()
+	store(t, db, tagged, "work/plan.md", "the quarterly roadmap #project/alpha", now, "")
+	store(t, db, uuid.NewString(), "home/list.md", "a roadmap for the garden", now, "")
+	gone := uuid.NewString()
+	store(t, db, gone, "old.md", "roadmap from last year", now, "")
+	if err := db.Model(&File{}).Where("id = ?", gone).Update("active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := IndexMeta(db, DefaultVault, tagged); err != nil {
+		t.Fatal(err)
+	}
+
+	locations := func(query string, filter SearchFilter) []string {
+		t.Helper()
+		hits, err := SearchFiles(db, DefaultVault, query, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []string
+		for _, hit := range hits {
+			found = append(found, hit.Location)
+		}
+		return found
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		filter SearchFilter
+		want   string
+	}{
+		{"deleted files are left out", "roadmap", SearchFilter{}, "home/list.md work/plan.md"},
+		{"last word is a prefix", "quarter", SearchFilter{}, "work/plan.md"},
+		{"every word has to match", "roadmap garden", SearchFilter{}, "home/list.md"},
+		{"folder", "roadmap", SearchFilter{Folder: "work/"}, "work/plan.md"},
+		{"nested tag", "roadmap", SearchFilter{Tag: "#project"}, "work/plan.md"},
+		{"modified later", "roadmap", SearchFilter{ModifiedAfter: now.Add(time.Hour)}, ""},
+		{"operators are plain words", `roadmap" OR "x`, SearchFilter{}, ""},
+		{"empty query", "  ", SearchFilter{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(locations(tt.query, tt.filter), " "); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	hits, _ := SearchFiles(db, DefaultVault, "garden", SearchFilter{})
+	if len(hits) != 1 || !strings.Contains(hits[0].Snippet, "<mark>garden</mark>") {
+		t.Errorf("hits %+v, want the match marked", hits)
+	}
+}
+
+func TestSearchFollowsEdits(t *testing.T) {
+	db := serverDB(t)
+	fileID := uuid.NewString()
+	first := store(t, db, fileID, "a.md", "apples", time.Now(), "")
+	store(t, db, fileID, "b.md", "pears", time.Now(), first)
+
+	if hits, _ := SearchFiles(db, DefaultVault, "apples", SearchFilter{}); len(hits) != 0 {
+		t.Errorf("old content still found: %+v", hits)
+	}
+	if hits, _ := SearchFiles(db, DefaultVault, "pears", SearchFilter{}); len(hits) != 1 || hits[0].Location != "b.md" {
+		t.Errorf("new content not found: %+v", hits)
+	}
+}
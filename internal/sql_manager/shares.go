@@ -0,0 +1,55 @@
+package sql_manager
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func CreateShareLink(db *gorm.DB, link *ShareLink) error {
+	return db.Create(link).Error
+}
+
+// FindShareLink resolves a token hash, ignoring links that have expired.
+func FindShareLink(db *gorm.DB, tokenHash string) (*ShareLink, error) {
+	var link ShareLink
+	if err := db.First(&link, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &link, nil
+}
+
+func GetShareLinks(db *gorm.DB, vaultID string) ([]ShareLink, error) {
+	var links []ShareLink
+	err := db.Where("vault_id = ?", vaultID).Order("created_at").Find(&links).Error
+	return links, err
+}
+
+func DeleteShareLink(db *gorm.DB, vaultID, id string) error {
+	res := db.Delete(&ShareLink{}, "vault_id = ? AND id = ?", vaultID, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return res.Error
+}
+
+// Covers reports whether location is reachable through the link.
+func (l *ShareLink) Covers(location string) bool {
+	if !l.Folder {
+		return location == l.Location
+	}
+	return l.Location == "" || strings.HasPrefix(location, strings.TrimSuffix(l.Location, "/")+"/")
+}
+
+// GetSharedFiles returns the files visible through a link. A pinned link
+// shows the files that were under it at PinnedAt, as they were then.
+func GetSharedFiles(db *gorm.DB, link *ShareLink) ([]FileVersion, error) {
+	if link.PinnedAt != nil {
+		return GetVersionsAt(db, link.VaultID, *link.PinnedAt, link.Covers)
+	}
+	return GetActiveFiles(db, link.VaultID, link.Covers)
+}
@@ -0,0 +1,76 @@
+package sql_manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestShareLinkCovers(t *testing.T) {
+	tests := []struct {
+		link     ShareLink
+		location string
+		want     bool
+	}{
+		{ShareLink{Location: "a.md"}, "a.md", true},
+		{ShareLink{Location: "a.md"}, "a.md/b", false},
+		{ShareLink{Location: "dir", Folder: true}, "dir/a.md", true},
+		{ShareLink{Location: "dir/", Folder: true}, "dir/sub/a.md", true},
+		{ShareLink{Location: "dir", Folder: true}, "directory/a.md", false},
+		{ShareLink{Folder: true}, "a.md", true},
+	}
+	for _, tt := range tests {
+		if got := tt.link.Covers(tt.location); got != tt.want {
+			t.Errorf("%+v covers %q = %v", tt.link, tt.location, got)
+		}
+	}
+}
+
+func TestFindShareLinkExpired(t *testing.T) {
+	db := serverDB(t)
+	past := time.Now().Add(-time.Minute)
+	if err := CreateShareLink(db, &ShareLink{TokenHash: "old", VaultID: DefaultVault, ExpiresAt: &past}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateShareLink(db, &ShareLink{TokenHash: "open", VaultID: DefaultVault}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindShareLink(db, "old"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expired link: %v", err)
+	}
+	if _, err := FindShareLink(db, "open"); err != nil {
+		t.Errorf("open link: %v", err)
+	}
+}
+
+func TestGetSharedFilesPinned(t *testing.T) {
+	db := serverDB(t)
+	start := time.Now().Add(-time.Hour)
+	fileID := uuid.NewString()
+	first := store(t, db, fileID, "shared/a.md", "before", start, "")
+	pinned := start.Add(time.Minute)
+	store(t, db, fileID, "shared/a.md", "after", start.Add(2*time.Minute), first)
+	store(t, db, uuid.NewString(), "shared/new.md", "new", start.Add(3*time.Minute), "")
+	store(t, db, uuid.NewString(), "private.md", "secret", start, "")
+
+	live := &ShareLink{VaultID: DefaultVault, Location: "shared", Folder: true}
+	files, err := GetSharedFiles(db, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("live link shows %d files, want 2", len(files))
+	}
+
+	live.PinnedAt = &pinned
+	files, err = GetSharedFiles(db, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Content != "before" {
+		t.Errorf("pinned link shows %+v, want the file as it was", files)
+	}
+}
@@ -0,0 +1,243 @@
+package sql_manager
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SyncRules pick the folders of a vault a client mirrors. A location is
+// synced when it is below an included folder, or nothing is included, and
+// not below an excluded one. Folders are vault relative without slashes at
+// either end.
+type SyncRules struct {
+	Include []string
+	Exclude []string
+}
+
+// CleanSyncFolder normalizes a folder for the rules, the vault root cannot
+// be selected.
+func CleanSyncFolder(folder string) (string, error) {
+	clean := strings.Trim(path.Clean("/"+strings.TrimSpace(folder)), "/")
+	if clean == "" || clean == "." {
+		return "", fmt.Errorf("%q is not a folder of the vault", folder)
+	}
+	return clean, nil
+}
+
+// NewSyncRules cleans and sorts both lists, dropping duplicates and folders
+// already covered by another entry of the same list.
+func NewSyncRules(include, exclude []string) (SyncRules, error) {
+	var rules SyncRules
+	var err error
+	if rules.Include, err = cleanFolders(include); err != nil {
+		return rules, err
+	}
+	rules.Exclude, err = cleanFolders(exclude)
+	return rules, err
+}
+
+func cleanFolders(folders []string) ([]string, error) {
+	var clean []string
+	for _, folder := range folders {
+		folder, err := CleanSyncFolder(folder)
+		if err != nil {
+			return nil, err
+		}
+		clean = append(clean, folder)
+	}
+	sort.Strings(clean)
+
+	var kept []string
+	for _, folder := range clean {
+		if len(kept) > 0 && below(folder, kept[len(kept)-1]) {
+			continue
+		}
+		kept = append(kept, folder)
+	}
+	return kept, nil
+}
+
+// below reports whether location is folder or inside it.
+func below(location, folder string) bool {
+	return location == folder || strings.HasPrefix(location, folder+"/")
+}
+
+// Empty reports whether the rules sync everything.
+func (r SyncRules) Empty() bool {
+	return len(r.Include) == 0 && len(r.Exclude) == 0
+}
+
+// Syncs reports whether a location is mirrored under the rules.
+func (r SyncRules) Syncs(location string) bool {
+	for _, folder := range r.Exclude {
+		if below(location, folder) {
+			return false
+		}
+	}
+	if len(r.Include) == 0 {
+		return true
+	}
+	for _, folder := range r.Include {
+		if below(location, folder) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add mirrors a folder again: excludes of it or below it are dropped, and it
+// is included when the rules only include some folders. A folder inside an
+// excluded one cannot be added on its own. The lists stay as NewSyncRules
+// leaves them.
+func (r *SyncRules) Add(folder string) error {
+	folder, err := CleanSyncFolder(folder)
+	if err != nil {
+		return err
+	}
+	for _, excluded := range r.Exclude {
+		if excluded != folder && below(folder, excluded) {
+			return fmt.Errorf("%s is inside the excluded folder %s", folder, excluded)
+		}
+	}
+	r.Exclude = dropBelow(r.Exclude, folder)
+	if len(r.Include) > 0 && !r.Syncs(folder) {
+		r.Include, err = cleanFolders(append(r.Include, folder))
+	}
+	return err
+}
+
+// Remove stops mirroring a folder. Includes of it are dropped as long as
+// others remain, since no includes would mean everything.
+func (r *SyncRules) Remove(folder string) {
+	if kept := dropBelow(r.Include, folder); len(kept) > 0 {
+		r.Include = kept
+	}
+	if r.Syncs(folder) {
+		r.Exclude = append(r.Exclude, folder)
+	}
+}
+
+func dropBelow(folders []string, folder string) []string {
+	var kept []string
+	for _, other := range folders {
+		if !below(other, folder) {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
+
+func joinFolders(folders []string) string {
+	return strings.Join(folders, "\n")
+}
+
+func splitFolders(joined string) []string {
+	if joined == "" {
+		return nil
+	}
+	return strings.Split(joined, "\n")
+}
+
+// ErrSessionTaken is returned for a session id another user holds.
+var ErrSessionTaken = errors.New("session belongs to another user")
+
+// ClaimSession makes sure no other user holds a session id and records
+// userID on the vaults of the session that were stored without a user.
+func ClaimSession(db *gorm.DB, sessionID, userID string) error {
+	var taken int64
+	if err := db.Model(&ClientSession{}).
+		Where("session_id = ? AND COALESCE(user_id, '') NOT IN ('', ?)", sessionID, userID).
+		Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrSessionTaken
+	}
+	if userID == "" {
+		return nil
+	}
+	return db.Model(&ClientSession{}).
+		Where("session_id = ? AND COALESCE(user_id, '') = ''", sessionID).
+		Update("user_id", userID).Error
+}
+
+// GetSessionRules returns the rules a session set for a vault, none when it
+// never did.
+func GetSessionRules(db *gorm.DB, sessionID, vaultID string) (SyncRules, error) {
+	var session ClientSession
+	err := db.Where("session_id = ? AND vault_id = ?", sessionID, vaultID).Limit(1).Find(&session).Error
+	return SyncRules{Include: splitFolders(session.IncludeFolders), Exclude: splitFolders(session.ExcludeFolders)}, err
+}
+
+// SetSessionRules stores the rules of a session, creating the session for
+// userID when it never connected so a new device can choose before its first
+// sync. Callers check the session is the user's with ClaimSession.
+func SetSessionRules(db *gorm.DB, sessionID, vaultID, userID string, rules SyncRules) error {
+	result := db.Model(&ClientSession{}).
+		Where("session_id = ? AND vault_id = ?", sessionID, vaultID).
+		Updates(map[string]interface{}{
+			"include_folders": joinFolders(rules.Include),
+			"exclude_folders": joinFolders(rules.Exclude),
+		})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return db.Create(&ClientSession{
+		SessionID:      sessionID,
+		VaultID:        vaultID,
+		UserID:         userID,
+		IncludeFolders: joinFolders(rules.Include),
+		ExcludeFolders: joinFolders(rules.Exclude),
+	}).Error
+}
+
+// GetLocalRules returns the rules a client last got from the server.
+func GetLocalRules(db *gorm.DB, vaultID string) (SyncRules, error) {
+	var state SyncState
+	err := db.Where("vault_id = ?", vaultID).Limit(1).Find(&state).Error
+	return SyncRules{Include: splitFolders(state.IncludeFolders), Exclude: splitFolders(state.ExcludeFolders)}, err
+}
+
+// CacheLocalRules keeps a copy of the rules so the client respects them
+// before it connects.
+func CacheLocalRules(db *gorm.DB, vaultID string, rules SyncRules) error {
+	result := db.Model(&SyncState{}).
+		Where("vault_id = ?", vaultID).
+		Updates(map[string]interface{}{
+			"include_folders": joinFolders(rules.Include),
+			"exclude_folders": joinFolders(rules.Exclude),
+		})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return db.Create(&SyncState{
+		VaultID:        vaultID,
+		IncludeFolders: joinFolders(rules.Include),
+		ExcludeFolders: joinFolders(rules.Exclude),
+	}).Error
+}
+
+// ForgetFile drops a file and its history from a client that stopped
+// mirroring it. The server keeps both.
+func ForgetFile(db *gorm.DB, file *File) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", file.ID).Delete(&FileVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("source_id = ?", file.ID).Delete(&Link{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&NoteTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&NoteField{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(file).Error
+	})
+}
@@ -0,0 +1,87 @@
+package sql_manager
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewSyncRules(t *testing.T) {
+	rules, err := NewSyncRules([]string{"/work/", "work/2024", "home", "home"}, []string{"work/private"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"home", "work"}; !reflect.DeepEqual(rules.Include, want) {
+		t.Errorf("Include = %q, want %q", rules.Include, want)
+	}
+	if _, err := NewSyncRules([]string{"/"}, nil); err == nil {
+		t.Error("the vault root was accepted as a folder")
+	}
+
+	for location, want := range map[string]bool{
+		"work/a.md": true, "home/b.md": true, "work/private/c.md": false, "workshop/d.md": false, "e.md": false,
+	} {
+		if rules.Syncs(location) != want {
+			t.Errorf("Syncs(%q) = %v", location, !want)
+		}
+	}
+}
+
+func TestSyncRulesAddRemove(t *testing.T) {
+	rules := SyncRules{Include: []string{"work"}}
+	rules.Remove("work/private")
+	if !reflect.DeepEqual(rules.Exclude, []string{"work/private"}) {
+		t.Errorf("Exclude = %q", rules.Exclude)
+	}
+	if err := rules.Add("work/private/keep"); err == nil {
+		t.Error("a folder inside an excluded one was added")
+	}
+	if err := rules.Add("work/private"); err != nil || len(rules.Exclude) != 0 {
+		t.Errorf("Add = %v, Exclude = %q", err, rules.Exclude)
+	}
+	// the last include stays, none would mean everything
+	rules.Remove("work")
+	if !reflect.DeepEqual(rules.Include, []string{"work"}) || !reflect.DeepEqual(rules.Exclude, []string{"work"}) {
+		t.Errorf("rules = %+v", rules)
+	}
+}
+
+func TestSyncRulesAddKeepsOrder(t *testing.T) {
+	rules := SyncRules{Include: []string{"work"}}
+	for _, folder := range []string{"notes/", "home", "home", "home/photos"} {
+		if err := rules.Add(folder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"home", "notes", "work"}; !reflect.DeepEqual(rules.Include, want) {
+		t.Errorf("Include = %q, want %q", rules.Include, want)
+	}
+}
+
+func TestClaimSession(t *testing.T) {
+	db := serverDB(t)
+	// stored before sessions had users
+	if err := SetSessionRules(db, "s", DefaultVault, "", SyncRules{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClaimSession(db, "s", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClaimSession(db, "s", "alice"); err != nil {
+		t.Errorf("claiming an own session again: %v", err)
+	}
+	if err := ClaimSession(db, "s", "bob"); !errors.Is(err, ErrSessionTaken) {
+		t.Errorf("claiming another user's session: %v", err)
+	}
+	if err := ClaimSession(db, "s", ""); !errors.Is(err, ErrSessionTaken) {
+		t.Errorf("claiming a user's session without a user: %v", err)
+	}
+	if err := ClaimSession(db, "new", "bob"); err != nil {
+		t.Errorf("claiming a new session: %v", err)
+	}
+
+	var session ClientSession
+	if err := db.First(&session, "session_id = ?", "s").Error; err != nil || session.UserID != "alice" {
+		t.Errorf("session user %q, %v", session.UserID, err)
+	}
+}
@@ -0,0 +1,79 @@
+package sql_manager
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+func CreateUser(db *gorm.DB, name string) (*User, error) {
+	if name == "" {
+		return nil, fmt.Errorf("user name is required")
+	}
+	user := &User{Name: name}
+	return user, db.Create(user).Error
+}
+
+func FindUserByName(db *gorm.DB, name string) (*User, error) {
+	var user User
+	err := db.First(&user, "name = ?", name).Error
+	return &user, err
+}
+
+func FindUserById(db *gorm.DB, id string) (*User, error) {
+	var user User
+	err := db.First(&user, "id = ?", id).Error
+	return &user, err
+}
+
+func CountUsers(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&User{}).Count(&count).Error
+	return count, err
+}
+
+func CreateDeviceToken(db *gorm.DB, userID, name, tokenHash string) error {
+	return db.Create(&DeviceToken{
+		TokenHash: tokenHash,
+		UserID:    userID,
+		Name:      name,
+	}).Error
+}
+
+// FindUserByToken resolves the user owning a device token hash.
+func FindUserByToken(db *gorm.DB, tokenHash string) (*User, error) {
+	var token DeviceToken
+	if err := db.First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	return FindUserById(db, token.UserID)
+}
+
+// SetMembership grants or changes a role, an empty role removes the member.
+func SetMembership(db *gorm.DB, vaultID, userID, role string) error {
+	if role == "" {
+		return db.Delete(&Membership{}, "vault_id = ? AND user_id = ?", vaultID, userID).Error
+	}
+	return db.Save(&Membership{VaultID: vaultID, UserID: userID, Role: role}).Error
+}
+
+func GetMembership(db *gorm.DB, vaultID, userID string) (*Membership, error) {
+	var membership Membership
+	err := db.First(&membership, "vault_id = ? AND user_id = ?", vaultID, userID).Error
+	return &membership, err
+}
+
+func GetVaultMembers(db *gorm.DB, vaultID string) ([]Membership, error) {
+	var members []Membership
+	err := db.Where("vault_id = ?", vaultID).Find(&members).Error
+	return members, err
+}
+
+func GetUserVaults(db *gorm.DB, userID string) ([]Vault, error) {
+	var vaults []Vault
+	err := db.Joins("JOIN memberships ON memberships.vault_id = vaults.id").
+		Where("memberships.user_id = ?", userID).
+		Order("vaults.id").
+		Find(&vaults).Error
+	return vaults, err
+}
@@ -0,0 +1,103 @@
+package sql_manager
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+var vaultIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+func ValidVaultID(id string) bool {
+	return vaultIDPattern.MatchString(id)
+}
+
+func CreateVault(db *gorm.DB, id, name string) (*Vault, error) {
+	if !ValidVaultID(id) {
+		return nil, fmt.Errorf("invalid vault id %q: use lowercase letters, digits, - and _", id)
+	}
+	if name == "" {
+		name = id
+	}
+	vault := &Vault{ID: id, Name: name}
+	return vault, db.Create(vault).Error
+}
+
+func FindVault(db *gorm.DB, id string) (*Vault, error) {
+	var vault Vault
+	err := db.First(&vault, "id = ?", id).Error
+	return &vault, err
+}
+
+func GetAllVaults(db *gorm.DB) ([]Vault, error) {
+	var vaults []Vault
+	err := db.Order("id").Find(&vaults).Error
+	return vaults, err
+}
+
+// EnsureDefaultVault creates the default vault and moves rows written before
+// vaults existed into it.
+func EnsureDefaultVault(db *gorm.DB) error {
+	if _, err := FindVault(db, DefaultVault); err == gorm.ErrRecordNotFound {
+		if _, err := CreateVault(db, DefaultVault, "Default"); err != nil {
+			return fmt.Errorf("failed to create default vault: %v", err)
+		}
+		log.Printf("Created vault: %s", DefaultVault)
+	} else if err != nil {
+		return err
+	}
+
+	return backfillVaultIDs(db, &File{}, &FileVersion{}, &VaultKey{}, &ClientSession{})
+}
+
+// migrateSessionKey moves client sessions of a server from before vaults to
+// their key of session and vault. AutoMigrate adds columns but never changes
+// an existing primary key, a session joining a second vault would collide.
+// SQLite servers create the table fresh, only Postgres is migrated.
+func migrateSessionKey(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" || !db.Migrator().HasTable(&ClientSession{}) {
+		return nil
+	}
+	var columns []string
+	if err := db.Raw(`SELECT a.attname FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = 'client_sessions'::regclass AND i.indisprimary`).Scan(&columns).Error; err != nil {
+		return fmt.Errorf("failed to read the client session key: %v", err)
+	}
+	if len(columns) != 1 {
+		return nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var constraint string
+		if err := tx.Raw(`SELECT conname FROM pg_constraint
+			WHERE conrelid = 'client_sessions'::regclass AND contype = 'p'`).Scan(&constraint).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("ALTER TABLE client_sessions ADD COLUMN IF NOT EXISTS vault_id text").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE client_sessions SET vault_id = ? WHERE vault_id IS NULL OR vault_id = ''", DefaultVault).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf(`ALTER TABLE client_sessions DROP CONSTRAINT %q, ADD PRIMARY KEY (session_id, vault_id)`, constraint)).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate the client session key: %v", err)
+	}
+	log.Printf("Migrated client sessions to a key of session and vault")
+	return nil
+}
+
+func backfillVaultIDs(db *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if err := db.Model(model).
+			Where("vault_id IS NULL OR vault_id = ''").
+			Update("vault_id", DefaultVault).Error; err != nil {
+			return fmt.Errorf("failed to backfill vault ids: %v", err)
+		}
+	}
+	return nil
+}
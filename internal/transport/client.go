@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// NewHTTPClient returns an HTTP/2 client for the connect services. Without
+// TLS it speaks cleartext h2c, matching the server's default.
+func NewHTTPClient(c ClientTLS) (*http.Client, error) {
+	if !c.Enabled {
+		return &http.Client{
+			Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+					return net.Dial(network, addr)
+				},
+			},
+		}, nil
+	}
+
+	cfg, err := ClientConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &http2.Transport{TLSClientConfig: cfg},
+	}, nil
+}
+
+// BaseURL turns a host:port into the URL used by the generated clients.
+func BaseURL(addr string, secure bool) string {
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return addr
+	}
+	if secure {
+		return "https://" + addr
+	}
+	return "http://" + addr
+}
@@ -0,0 +1,21 @@
+package transport
+
+import "testing"
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		addr   string
+		secure bool
+		want   string
+	}{
+		{"localhost:50051", false, "http://localhost:50051"},
+		{"localhost:50051", true, "https://localhost:50051"},
+		{"https://sync.example.com", false, "https://sync.example.com"},
+		{"http://sync.example.com", true, "http://sync.example.com"},
+	}
+	for _, tt := range tests {
+		if got := BaseURL(tt.addr, tt.secure); got != tt.want {
+			t.Errorf("BaseURL(%q, %v) = %q, want %q", tt.addr, tt.secure, got, tt.want)
+		}
+	}
+}
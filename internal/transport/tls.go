@@ -0,0 +1,220 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ServerTLS describes how the sync server terminates TLS. When neither a
+// certificate nor SelfSigned is set the server keeps serving cleartext h2c.
+type ServerTLS struct {
+	CertFile   string
+	KeyFile    string
+	SelfSigned bool     // generate CertFile/KeyFile on first start if they are missing
+	Hosts      []string // DNS names / IPs written into a self-signed certificate
+}
+
+func (c ServerTLS) Enabled() bool {
+	return c.SelfSigned || c.CertFile != "" || c.KeyFile != ""
+}
+
+// ClientTLS describes how a client verifies the sync server.
+type ClientTLS struct {
+	Enabled    bool
+	CAFile     string   // PEM bundle used instead of the system roots
+	Pins       []string // hex SHA-256 fingerprints of the accepted leaf certificates
+	ServerName string
+}
+
+const (
+	defaultSelfSignedCert = "./certs/server.crt"
+	defaultSelfSignedKey  = "./certs/server.key"
+)
+
+// ServerConfig loads (or bootstraps) the certificate for the server and
+// returns a tls.Config that negotiates HTTP/2.
+func ServerConfig(c ServerTLS) (*tls.Config, error) {
+	if c.CertFile == "" && c.KeyFile == "" && c.SelfSigned {
+		c.CertFile, c.KeyFile = defaultSelfSignedCert, defaultSelfSignedKey
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("tls requires both a certificate and a key file")
+	}
+
+	if c.SelfSigned {
+		if _, err := os.Stat(c.CertFile); errors.Is(err, os.ErrNotExist) {
+			if err := GenerateSelfSigned(c.CertFile, c.KeyFile, c.Hosts); err != nil {
+				return nil, err
+			}
+			log.Printf("Generated self-signed certificate: %s", c.CertFile)
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	log.Printf("TLS certificate fingerprint (sha256): %s", Fingerprint(leaf))
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2"},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientConfig builds the tls.Config used by the client transport. Pins are
+// checked on top of normal chain verification; when only pins are given the
+// chain is not verified so a self-signed server can be trusted by fingerprint.
+func ClientConfig(c ClientTLS) (*tls.Config, error) {
+	cfg := &tls.Config{
+		NextProtos: []string{"h2"},
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+
+	if c.CAFile != "" {
+		pemData, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(c.Pins) == 0 {
+		return cfg, nil
+	}
+
+	pins := make(map[string]bool, len(c.Pins))
+	for _, pin := range c.Pins {
+		pins[normalizeFingerprint(pin)] = true
+	}
+
+	verifyChain := c.CAFile != ""
+	roots := cfg.RootCAs
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("server presented no certificate")
+		}
+		leaf := cs.PeerCertificates[0]
+		if verifyChain {
+			intermediates := x509.NewCertPool()
+			for _, cert := range cs.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			if _, err := leaf.Verify(x509.VerifyOptions{
+				Roots:         roots,
+				Intermediates: intermediates,
+				DNSName:       cs.ServerName,
+			}); err != nil {
+				return err
+			}
+		}
+		if !pins[Fingerprint(leaf)] {
+			return fmt.Errorf("certificate fingerprint %s is not pinned", Fingerprint(leaf))
+		}
+		return nil
+	}
+	return cfg, nil
+}
+
+// GenerateSelfSigned writes a new ECDSA key and a self-signed certificate
+// valid for the given hosts (localhost when empty).
+func GenerateSelfSigned(certFile, keyFile string, hosts []string) error {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"sync"}, CommonName: hosts[0]},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+	return writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0600)
+}
+
+// Fingerprint returns the hex SHA-256 of the DER encoded certificate, the
+// format accepted by ClientTLS.Pins.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeFingerprint(pin string) string {
+	pin = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(pin)), "sha256:")
+	return strings.ReplaceAll(pin, ":", "")
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	return pem.Encode(f, &pem.Block{Type: blockType, Bytes: der})
+}
@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tlsServer serves "ok" over HTTP/2 with a certificate generated for
+// 127.0.0.1 and returns the server and the certificate file.
+func tlsServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	cfg, err := ServerConfig(ServerTLS{CertFile: certFile, KeyFile: keyFile, SelfSigned: true, Hosts: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("request over %s, want HTTP/2", r.Proto)
+		}
+		io.WriteString(w, "ok")
+	}))
+	srv.TLS = cfg
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, certFile
+}
+
+func leafFingerprint(t *testing.T, certFile string) string {
+	t.Helper()
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Fingerprint(cert)
+}
+
+func TestGenerateSelfSignedKeyIsPrivate(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "certs", "server.key")
+	if err := GenerateSelfSigned(filepath.Join(dir, "certs", "server.crt"), keyFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key file mode %o, want 600", perm)
+	}
+}
+
+func TestServerConfigKeepsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	c := ServerTLS{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key"), SelfSigned: true}
+	if _, err := ServerConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	first := leafFingerprint(t, c.CertFile)
+	if _, err := ServerConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if again := leafFingerprint(t, c.CertFile); again != first {
+		t.Error("a second start replaced the certificate")
+	}
+}
+
+func TestServerConfigNeedsKeyPair(t *testing.T) {
+	if _, err := ServerConfig(ServerTLS{CertFile: "server.crt"}); err == nil {
+		t.Error("a certificate without a key was accepted")
+	}
+}
+
+func TestClientVerifiesServer(t *testing.T) {
+	srv, certFile := tlsServer(t)
+	pin := leafFingerprint(t, certFile)
+	// pins are accepted the way people copy them
+	colons := strings.ToUpper(pin[:2]) + ":" + pin[2:]
+
+	tests := []struct {
+		name string
+		tls  ClientTLS
+		ok   bool
+	}{
+		{"system roots", ClientTLS{Enabled: true}, false},
+		{"ca file", ClientTLS{Enabled: true, CAFile: certFile}, true},
+		{"pin", ClientTLS{Enabled: true, Pins: []string{pin}}, true},
+		{"pin with prefix", ClientTLS{Enabled: true, Pins: []string{"sha256:" + colons}}, true},
+		{"ca file and pin", ClientTLS{Enabled: true, CAFile: certFile, Pins: []string{pin}}, true},
+		{"other pin", ClientTLS{Enabled: true, Pins: []string{strings.Repeat("ab", 32)}}, false},
+		{"ca file and other pin", ClientTLS{Enabled: true, CAFile: certFile, Pins: []string{strings.Repeat("ab", 32)}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tt.tls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Get(srv.URL)
+			if !tt.ok {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("connected to an untrusted server")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+				t.Errorf("got %q", body)
+			}
+		})
+	}
+}
+
+func TestClientConfigRejectsEmptyCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, []byte("not a certificate"), 0644)
+	if _, err := ClientConfig(ClientTLS{Enabled: true, CAFile: caFile}); err == nil {
+		t.Error("a ca file without certificates was accepted")
+	}
+}
@@ -0,0 +1,368 @@
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/drawing"
+	"github.com/itsrobel/sync/internal/e2e"
+	"github.com/itsrobel/sync/internal/links"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errGone is returned when a queued attachment was deleted before its
+// upload, the content only ever lived on disk.
+var errGone = errors.New("attachment is no longer on disk")
+
+// IsAttachment reports whether a location is synced as an attachment: its
+// content is streamed from disk and never stored in the database. Drawings
+// are synced like notes so concurrent changes can be merged.
+func IsAttachment(location string) bool {
+	return !links.IsNote(location) && !drawing.IsDrawing(location)
+}
+
+// fileLimit is the size limit of a folder in bytes, the smaller one of its
+// own and the server's, 0 when there is none.
+func (fw *FileWatcher) fileLimit(folder *folderState) int64 {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	limit := folder.MaxFileSize
+	if folder.serverLimit > 0 && (limit == 0 || folder.serverLimit < limit) {
+		limit = folder.serverLimit
+	}
+	return limit
+}
+
+// tooLarge reports files over the folder's limit, they are not recorded or
+// uploaded.
+func (fw *FileWatcher) tooLarge(folder *folderState, path, location string) bool {
+	limit := fw.fileLimit(folder)
+	if limit == 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() <= limit {
+		return false
+	}
+	log.Printf("Skipping %s: %d bytes is over the limit of %d", path, info.Size(), limit)
+	fw.noteSync(folder.Vault, func(r *VaultSyncResult) { r.Skipped = append(r.Skipped, location) })
+	return true
+}
+
+// newHash returns the checksum used for attachments, keyed with the vault key
+// in encrypted vaults so the server learns nothing from it.
+func (fw *FileWatcher) newHash(folder *folderState) hash.Hash {
+	if ring := fw.getKeyring(folder); ring != nil {
+		return ring.NewHash()
+	}
+	return sha256.New()
+}
+
+func (fw *FileWatcher) hashFile(folder *folderState, path string) (int64, string, error) {
+	content, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer content.Close()
+
+	sum := fw.newHash(folder)
+	size, err := io.Copy(sum, content)
+	return size, hex.EncodeToString(sum.Sum(nil)), err
+}
+
+// recordBlob queues a new version of an attachment unless the file on disk
+// still matches the last one.
+func (fw *FileWatcher) recordBlob(folder *folderState, path string, file *sql_manager.File) (*sql_manager.FileVersion, error) {
+	size, sum, err := fw.hashFile(folder, path)
+	if err != nil {
+		return nil, err
+	}
+	if file.Blob && !file.Remote && file.Hash == sum {
+		return nil, nil
+	}
+
+	fileVersion, err := sql_manager.CreateBlobVersion(fw.db, file, size, sum, fw.sessionID)
+	if err != nil {
+		return fileVersion, err
+	}
+	return fileVersion, sql_manager.EnqueueVersion(fw.db, fileVersion)
+}
+
+// uploadBlob streams an attachment from disk. The file is sent as it is now,
+// a newer version recorded meanwhile follows in the outbox anyway.
+func (fw *FileWatcher) uploadBlob(folder *folderState, fileVersion *sql_manager.FileVersion) error {
+	path, err := folder.localPath(fileVersion.Location)
+	if err != nil {
+		return err
+	}
+	content, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", errGone, fileVersion.Location)
+	} else if err != nil {
+		return err
+	}
+	defer content.Close()
+	info, err := content.Stat()
+	if err != nil {
+		return err
+	}
+
+	location := fileVersion.Location
+	ring := fw.getKeyring(folder)
+	if folder.Passphrase != "" {
+		if ring == nil {
+			return fmt.Errorf("refusing to upload %s: vault is locked", location)
+		}
+		if location, err = ring.EncryptPath(location, folder.Vault, fileVersion.FileID); err != nil {
+			return err
+		}
+	}
+
+	stream := fw.client.SendFileToServer(context.Background())
+	sender := &chunkSender{
+		stream: stream,
+		meta: &ft.FileVersionData{
+			Id:        fileVersion.ID,
+			Location:  location,
+			FileId:    fileVersion.FileID,
+			VaultId:   fileVersion.VaultID,
+			Timestamp: timestamppb.New(fileVersion.Timestamp),
+			Client:    fw.sessionID,
+			TotalSize: info.Size(),
+			Blob:      true,
+			Hash:      fileVersion.Hash,
+		},
+	}
+	var dst io.Writer = sender
+	var seal io.WriteCloser
+	if ring != nil {
+		seal = ring.NewSealWriter(sender, folder.Vault, fileVersion.FileID)
+		dst = seal
+	}
+	_, err = io.Copy(dst, content)
+	if err == nil && seal != nil {
+		err = seal.Close()
+	}
+	if err == nil {
+		err = sender.Close()
+	}
+	return closeUpload(stream, err)
+}
+
+// closeUpload ends an upload stream. A server that refuses an upload ends the
+// stream early, its reason comes with the response rather than from Send.
+func closeUpload(stream *connect.ClientStreamForClient[ft.FileVersionData, ft.ActionResponse], sendErr error) error {
+	res, err := stream.CloseAndReceive()
+	if err != nil {
+		return fmt.Errorf("error closing stream: %w", err)
+	}
+	if sendErr != nil {
+		return fmt.Errorf("error sending string data: %w", sendErr)
+	}
+	log.Printf("Upload completed: %v", res)
+	return nil
+}
+
+// chunkSender sends what is written to it as ChunkSize upload messages, each
+// carrying a copy of meta.
+type chunkSender struct {
+	stream *connect.ClientStreamForClient[ft.FileVersionData, ft.ActionResponse]
+	meta   *ft.FileVersionData
+	buf    []byte
+	offset int64
+	sent   bool
+}
+
+func (c *chunkSender) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if c.buf == nil {
+			c.buf = make([]byte, 0, sql_manager.ChunkSize)
+		}
+		n := copy(c.buf[len(c.buf):cap(c.buf)], p)
+		c.buf = c.buf[:len(c.buf)+n]
+		p = p[n:]
+		written += n
+		if len(c.buf) == cap(c.buf) {
+			if err := c.send(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close sends what is left, an empty file still sends its metadata once.
+func (c *chunkSender) Close() error {
+	if len(c.buf) > 0 || !c.sent {
+		return c.send()
+	}
+	return nil
+}
+
+func (c *chunkSender) send() error {
+	msg := proto.Clone(c.meta).(*ft.FileVersionData)
+	msg.Content = c.buf
+	msg.Offset = c.offset
+	c.offset += int64(len(c.buf))
+	c.buf = nil
+	c.sent = true
+	return c.stream.Send(msg)
+}
+
+// downloadReader turns the chunks of a download into a reader.
+type downloadReader struct {
+	stream *connect.ServerStreamForClient[ft.FileVersionData]
+	buf    []byte
+}
+
+func (d *downloadReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if !d.stream.Receive() {
+			if err := d.stream.Err(); err != nil {
+				return 0, fmt.Errorf("error receiving file: %w", err)
+			}
+			return 0, io.EOF
+		}
+		d.buf = d.stream.Msg().Content
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// downloadBlob streams an attachment to disk, meta is the first chunk. Unless
+// fetch is set, attachments that are new to a lazy folder or over its limit
+// are only recorded, `fetch` downloads them later.
+func (fw *FileWatcher) downloadBlob(folder *folderState, meta *ft.FileVersionData, stream *connect.ServerStreamForClient[ft.FileVersionData], fetch bool) error {
+	location, err := fw.openLocation(folder, meta.FileId, meta.Location)
+	if err != nil {
+		return err
+	}
+	if !fw.syncs(folder, location) {
+		return nil
+	}
+	path, err := folder.localPath(location)
+	if err != nil {
+		return err
+	}
+	file, created, err := fw.downloadTarget(folder, meta.FileId, location)
+	if err != nil {
+		return err
+	}
+
+	if !fetch {
+		overLimit := folder.MaxFileSize > 0 && meta.TotalSize > folder.MaxFileSize
+		if (folder.LazyAttachments && (created || file.Remote)) || (overLimit && (created || file.Remote)) {
+			log.Printf("Recorded %s without downloading it (%d bytes)", location, meta.TotalSize)
+			fw.noteSync(folder.Vault, func(r *VaultSyncResult) { r.Deferred = append(r.Deferred, location) })
+			return sql_manager.SetRemoteBlob(fw.db, file, meta.TotalSize, meta.Hash)
+		}
+		if overLimit {
+			log.Printf("Skipping the new version of %s: %d bytes is over the limit of %d", location, meta.TotalSize, folder.MaxFileSize)
+			fw.noteSync(folder.Vault, func(r *VaultSyncResult) { r.Skipped = append(r.Skipped, location) })
+			return nil
+		}
+	}
+	if file.Blob && !file.Remote && meta.Hash != "" && file.Hash == meta.Hash {
+		return nil
+	}
+
+	ring := fw.getKeyring(folder)
+	if ring == nil && e2e.IsSealedStream(meta.Content) {
+		return fmt.Errorf("received encrypted content but no passphrase is set")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// written next to the file and moved in place once complete, the
+	// watcher ignores the temporary name
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sync-download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var src io.Reader = &downloadReader{stream: stream, buf: meta.Content}
+	if ring != nil {
+		src = ring.NewOpenReader(src, folder.Vault, meta.FileId)
+	}
+	sum := fw.newHash(folder)
+	size, err := io.Copy(io.MultiWriter(tmp, sum), src)
+	if err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	pending, err := sql_manager.HasPending(fw.db, folder.Vault, file.ID)
+	if err != nil {
+		return err
+	}
+	if pending {
+		if err := fw.keepConflictCopy(folder, file, meta.Client); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if _, err := sql_manager.CreateBlobVersion(fw.db, file, size, hex.EncodeToString(sum.Sum(nil)), meta.Client); err != nil {
+		return err
+	}
+	log.Printf("Downloaded file: %s", path)
+	fw.noteSync(folder.Vault, func(r *VaultSyncResult) { r.Downloaded = append(r.Downloaded, location) })
+	return nil
+}
+
+// Fetch downloads attachments that were only recorded, because the folder
+// syncs them lazily or they are over its limit. Each path is a file or a
+// directory inside a synced folder, it returns the fetched locations.
+func Fetch(ctx context.Context, cfg Config, paths []string) ([]string, error) {
+	fw, err := newFileWatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer fw.Stop()
+
+	fetched := []string{}
+	for _, path := range paths {
+		folder, location, err := fw.folderFor(path)
+		if err != nil {
+			return fetched, err
+		}
+		files, err := sql_manager.GetRemoteFiles(fw.db, folder.Vault, location)
+		if err != nil {
+			return fetched, err
+		}
+		if len(files) == 0 {
+			return fetched, fmt.Errorf("nothing to fetch at %s", path)
+		}
+
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return fetched, err
+			}
+			if err := fw.file_download(folder.Vault, file.ID, true); err != nil {
+				return fetched, fmt.Errorf("failed to fetch %s: %w", file.Location, err)
+			}
+			fetched = append(fetched, file.Location)
+		}
+	}
+	return fetched, nil
+}
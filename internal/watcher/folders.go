@@ -0,0 +1,193 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	"github.com/itsrobel/sync/internal/e2e"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// Folder maps a local directory onto a vault on the server. File locations
+// are stored relative to Path so each client can keep the vault anywhere.
+type Folder struct {
+	Path  string
+	Vault string
+
+	// Passphrase turns on end-to-end encryption of the vault
+	Passphrase string
+	// EncryptPaths is only read when the vault key is first created
+	EncryptPaths bool
+
+	// MaxFileSize in bytes, larger files are neither uploaded nor downloaded.
+	// 0 leaves only the server's limit.
+	MaxFileSize int64
+	// LazyAttachments records new attachments from the server without
+	// downloading them, Fetch gets their content on demand
+	LazyAttachments bool
+}
+
+type folderState struct {
+	Folder
+	keyring *e2e.Keyring
+	role    auth.Role // empty until the server reported our access
+	// serverLimit is the vault's size limit as reported by the server
+	serverLimit int64
+	// rules pick the folders this device mirrors, cached from the server
+	rules sql_manager.SyncRules
+}
+
+func newFolderStates(folders []Folder) (map[string]*folderState, error) {
+	if len(folders) == 0 {
+		return nil, fmt.Errorf("no folders configured")
+	}
+
+	states := make(map[string]*folderState, len(folders))
+	for _, folder := range folders {
+		if folder.Vault == "" {
+			folder.Vault = sql_manager.DefaultVault
+		}
+		if !sql_manager.ValidVaultID(folder.Vault) {
+			return nil, fmt.Errorf("invalid vault id %q", folder.Vault)
+		}
+		if _, ok := states[folder.Vault]; ok {
+			return nil, fmt.Errorf("vault %s is mapped to more than one folder", folder.Vault)
+		}
+
+		path, err := filepath.Abs(folder.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range states {
+			if within(other.Path, path) || within(path, other.Path) {
+				return nil, fmt.Errorf("folders %s and %s overlap", other.Path, path)
+			}
+		}
+		folder.Path = path
+		states[folder.Vault] = &folderState{Folder: folder}
+	}
+	return states, nil
+}
+
+// location converts a path on disk into the vault relative location.
+func (f *folderState) location(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(f.Path, abs)
+	if err != nil || !within(f.Path, abs) {
+		return "", fmt.Errorf("%s is outside of %s", path, f.Path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// localPath converts a vault location into a path on disk, refusing anything
+// that would land outside the folder.
+func (f *folderState) localPath(location string) (string, error) {
+	path := filepath.Join(f.Path, filepath.FromSlash(location))
+	if !within(f.Path, path) || path == f.Path {
+		return "", fmt.Errorf("location %q escapes folder %s", location, f.Path)
+	}
+	return path, nil
+}
+
+func (fw *FileWatcher) folderFor(path string) (*folderState, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, folder := range fw.folders {
+		if within(folder.Path, abs) {
+			location, err := folder.location(abs)
+			return folder, location, err
+		}
+	}
+	return nil, "", fmt.Errorf("%s is not inside a synced folder", path)
+}
+
+// ensureVaults creates any configured vault the server does not know yet and
+// picks up the size limit of each.
+func (fw *FileWatcher) ensureVaults() error {
+	res, err := fw.client.ListVaults(context.Background(), connect.NewRequest(&ft.ActionRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list vaults: %w", err)
+	}
+
+	known := make(map[string]bool, len(res.Msg.Vaults))
+	for _, vault := range res.Msg.Vaults {
+		known[vault.Id] = true
+		fw.setServerLimit(vault)
+	}
+
+	for vaultID := range fw.folders {
+		if known[vaultID] {
+			continue
+		}
+		created, err := fw.client.CreateVault(context.Background(), connect.NewRequest(&ft.Vault{Id: vaultID}))
+		if connect.CodeOf(err) == connect.CodeAlreadyExists {
+			return fmt.Errorf("vault %s exists but this device's user is not a member", vaultID)
+		} else if err != nil {
+			return fmt.Errorf("failed to create vault %s: %w", vaultID, err)
+		}
+		fw.setServerLimit(created.Msg)
+		log.Printf("Created vault on server: %s", vaultID)
+	}
+	return nil
+}
+
+func (fw *FileWatcher) setServerLimit(vault *ft.Vault) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if folder := fw.folders[vault.Id]; folder != nil {
+		folder.serverLimit = vault.MaxFileSize
+	}
+}
+
+// refreshAccess asks the server which role this device's user holds on the
+// folder's vault.
+func (fw *FileWatcher) refreshAccess(folder *folderState) error {
+	res, err := fw.client.GetAccess(context.Background(), connect.NewRequest(&ft.ActionRequest{VaultId: folder.Vault}))
+	if err != nil {
+		return err
+	}
+	role, err := auth.ParseRole(res.Msg.Role)
+	if err != nil {
+		return err
+	}
+
+	fw.mu.Lock()
+	folder.role = role
+	fw.mu.Unlock()
+	if !role.CanWrite() {
+		log.Printf("Vault %s is read-only for this device, local edits will not be uploaded", folder.Vault)
+	}
+	return nil
+}
+
+// Role returns the access level on a vault, empty while it is unknown.
+func (fw *FileWatcher) Role(vaultID string) auth.Role {
+	folder := fw.folders[vaultID]
+	if folder == nil {
+		return ""
+	}
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return folder.role
+}
+
+func (fw *FileWatcher) ReadOnly(vaultID string) bool {
+	role := fw.Role(vaultID)
+	return role != "" && !role.CanWrite()
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,46 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"gorm.io/gorm"
+)
+
+// localDB opens a fresh client database.
+func localDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := sql_manager.ConnectSQLite(filepath.Join(t.TempDir(), "client.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// record stores what the client last knew of a note in the default vault.
+func record(t *testing.T, db *gorm.DB, location, content string) *sql_manager.File {
+	t.Helper()
+	file := &sql_manager.File{
+		VaultID:  sql_manager.DefaultVault,
+		Active:   true,
+		Location: location,
+		Content:  content,
+		Size:     int64(len(content)),
+	}
+	if err := db.Create(file).Error; err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
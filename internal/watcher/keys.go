@@ -0,0 +1,236 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/e2e"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"gorm.io/gorm"
+)
+
+// unlockVault derives the vault key from the folder's passphrase. The server
+// copy of the key info wins; the cached copy is used when the server is
+// unreachable, and a new key is published if the vault has none yet.
+func (fw *FileWatcher) unlockVault(folder *folderState) error {
+	// the vault has to exist before a key can be published for it
+	ensureErr := fw.ensureVaults()
+
+	var info *ft.KeyInfo
+	res, err := fw.client.GetKeyInfo(context.Background(), connect.NewRequest(&ft.ActionRequest{VaultId: folder.Vault}))
+	switch {
+	case err == nil:
+		info = res.Msg
+	case connect.CodeOf(err) == connect.CodeNotFound && ensureErr == nil:
+		return fw.createVaultKey(folder)
+	default:
+		cached, cacheErr := sql_manager.GetCurrentVaultKey(fw.db, folder.Vault)
+		if errors.Is(cacheErr, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("cannot unlock vault before first connecting to the server: %w", err)
+		} else if cacheErr != nil {
+			return cacheErr
+		}
+		log.Printf("Server unreachable, unlocking vault %s from cached key info", folder.Vault)
+		info = cached.Info()
+	}
+
+	ring, err := e2e.Unlock(info, folder.Passphrase)
+	if err != nil {
+		return err
+	}
+	if err := sql_manager.CacheVaultKey(fw.db, sql_manager.VaultKeyFromInfo(info)); err != nil {
+		return err
+	}
+
+	fw.mu.Lock()
+	folder.keyring = ring
+	fw.mu.Unlock()
+	return nil
+}
+
+func (fw *FileWatcher) createVaultKey(folder *folderState) error {
+	info, ring, err := e2e.NewKeyInfo(folder.Passphrase, nil, folder.EncryptPaths)
+	if err != nil {
+		return err
+	}
+	info.VaultId = folder.Vault
+
+	if _, err := fw.client.SetKeyInfo(context.Background(), connect.NewRequest(info)); err != nil {
+		return fmt.Errorf("failed to publish vault key: %w", err)
+	}
+	if err := sql_manager.CacheVaultKey(fw.db, sql_manager.VaultKeyFromInfo(info)); err != nil {
+		return err
+	}
+
+	fw.mu.Lock()
+	folder.keyring = ring
+	fw.mu.Unlock()
+
+	// files uploaded before the vault had a key are still plain on the
+	// server, they are queued again to be sealed on upload
+	count, err := fw.resealFiles(folder)
+	if err != nil {
+		return err
+	}
+	log.Printf("Created key version %d for vault %s, queued %d files for encryption", info.Version, folder.Vault, count)
+	return nil
+}
+
+// RotateKey switches a vault to a key derived from newPassphrase and
+// re-uploads every active file sealed with it. Older keys are wrapped with the
+// new one so history stays readable.
+func (fw *FileWatcher) RotateKey(vaultID, newPassphrase string) error {
+	folder := fw.folders[vaultID]
+	if folder == nil {
+		return fmt.Errorf("no folder is mapped to vault %s", vaultID)
+	}
+	ring := fw.getKeyring(folder)
+	if ring == nil {
+		return fmt.Errorf("vault %s is not unlocked", vaultID)
+	}
+	if !fw.waitConnected(10 * time.Second) {
+		return fmt.Errorf("key rotation requires a server connection")
+	}
+
+	info, newRing, err := e2e.NewKeyInfo(newPassphrase, ring, ring.EncryptPaths)
+	if err != nil {
+		return err
+	}
+	info.VaultId = vaultID
+
+	if _, err := fw.client.SetKeyInfo(context.Background(), connect.NewRequest(info)); err != nil {
+		return fmt.Errorf("failed to publish vault key: %w", err)
+	}
+	if err := sql_manager.CacheVaultKey(fw.db, sql_manager.VaultKeyFromInfo(info)); err != nil {
+		return err
+	}
+
+	fw.mu.Lock()
+	folder.keyring = newRing
+	folder.Passphrase = newPassphrase
+	fw.mu.Unlock()
+
+	count, err := fw.resealFiles(folder)
+	if err != nil {
+		return err
+	}
+	if err := fw.flushOutbox(vaultID); err != nil {
+		return err
+	}
+
+	log.Printf("Rotated vault %s to key version %d, re-encrypted %d files", vaultID, info.Version, count)
+	return nil
+}
+
+// resealFiles queues a new version of every active file of the folder, so
+// the upload seals it with the current key. It returns how many were queued.
+func (fw *FileWatcher) resealFiles(folder *folderState) (int, error) {
+	var files []sql_manager.File
+	if err := fw.db.Where("vault_id = ? AND active = ?", folder.Vault, true).Find(&files).Error; err != nil {
+		return 0, err
+	}
+	count := 0
+	for i := range files {
+		var err error
+		switch {
+		case files[i].Remote:
+			// never downloaded here, there is nothing to seal
+			log.Printf("Not re-encrypting %s, it was never downloaded", files[i].Location)
+			continue
+		case files[i].Blob:
+			var path string
+			if path, err = folder.localPath(files[i].Location); err == nil {
+				_, err = fw.recordBlob(folder, path, &files[i])
+			}
+		default:
+			_, err = fw.recordVersion(&files[i], files[i].Content)
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (fw *FileWatcher) getKeyring(folder *folderState) *e2e.Keyring {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return folder.keyring
+}
+
+// sealFile prepares a location and content for upload. Both are bound to
+// the file, the server cannot pass them off as another file's.
+func (fw *FileWatcher) sealFile(folder *folderState, fileID, location, content string) (string, string, error) {
+	if folder.Passphrase == "" {
+		return location, content, nil
+	}
+	ring := fw.getKeyring(folder)
+	if ring == nil {
+		return "", "", fmt.Errorf("refusing to upload %s: vault is locked", location)
+	}
+
+	sealedLocation, err := ring.EncryptPath(location, folder.Vault, fileID)
+	if err != nil {
+		return "", "", err
+	}
+	sealedContent, err := ring.Encrypt([]byte(content), folder.Vault, fileID)
+	if err != nil {
+		return "", "", err
+	}
+	return sealedLocation, sealedContent, nil
+}
+
+// openFile reverses sealFile for downloaded data. Once the vault has a key,
+// data that is not sealed for the file is refused.
+func (fw *FileWatcher) openFile(folder *folderState, fileID, location, content string) (string, string, error) {
+	ring := fw.getKeyring(folder)
+	if ring == nil {
+		if e2e.IsEncrypted(content) {
+			return "", "", fmt.Errorf("received encrypted content but no passphrase is set")
+		}
+		if err := fw.refuseLocked(folder); err != nil {
+			return "", "", err
+		}
+		return location, content, nil
+	}
+
+	plainLocation, err := ring.DecryptPath(location, folder.Vault, fileID)
+	if err != nil {
+		return "", "", fmt.Errorf("file %s: %w", fileID, err)
+	}
+	plainContent, err := ring.Decrypt(content, folder.Vault, fileID)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", plainLocation, err)
+	}
+	return plainLocation, string(plainContent), nil
+}
+
+// openLocation reverses the path encryption of a downloaded location.
+func (fw *FileWatcher) openLocation(folder *folderState, fileID, location string) (string, error) {
+	ring := fw.getKeyring(folder)
+	if ring == nil {
+		return location, fw.refuseLocked(folder)
+	}
+	plain, err := ring.DecryptPath(location, folder.Vault, fileID)
+	if err != nil {
+		return "", fmt.Errorf("file %s: %w", fileID, err)
+	}
+	return plain, nil
+}
+
+// refuseLocked fails when the vault has a key this client knows of but has
+// not unlocked, its plain data would come from the server alone.
+func (fw *FileWatcher) refuseLocked(folder *folderState) error {
+	if _, err := sql_manager.GetCurrentVaultKey(fw.db, folder.Vault); err == nil {
+		return fmt.Errorf("vault %s is encrypted but locked", folder.Vault)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
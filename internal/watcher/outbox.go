@@ -0,0 +1,204 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// recordVersion stores new local content and queues it for upload. Nothing
+// is sent until the vault has caught up, so remote changes made while we
+// were offline are seen first and turn into conflicts instead of being
+// overwritten.
+func (fw *FileWatcher) recordVersion(file *sql_manager.File, content string) (*sql_manager.FileVersion, error) {
+	fileVersion, err := sql_manager.CreateFileVersion(fw.db, file, content, fw.sessionID)
+	if err != nil {
+		return fileVersion, err
+	}
+	return fileVersion, sql_manager.EnqueueVersion(fw.db, fileVersion)
+}
+
+// flushOutbox uploads the queued versions of a vault in order, stopping at
+// the first failure so later versions never overtake earlier ones.
+func (fw *FileWatcher) flushOutbox(vaultID string) error {
+	if !fw.caughtUp(vaultID) {
+		return nil
+	}
+
+	fw.flushMu.Lock()
+	defer fw.flushMu.Unlock()
+
+	entries, err := sql_manager.GetPending(fw.db, vaultID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		fileVersion, err := sql_manager.GetVersionById(fw.db, entry.VersionID)
+		if err != nil {
+			return err
+		}
+		err = fw.file_upload(fileVersion)
+		if connect.CodeOf(err) == connect.CodeAlreadyExists {
+			// an earlier attempt got through but its answer was lost
+			err = nil
+		}
+		if connect.CodeOf(err) == connect.CodeResourceExhausted || errors.Is(err, errGone) {
+			// retrying cannot help, the entry is dropped so later
+			// versions are not held up
+			log.Printf("Not uploading %s: %v", entry.Location, err)
+			if err := sql_manager.MarkUploaded(fw.db, entry.VersionID); err != nil {
+				return err
+			}
+			fw.noteSync(vaultID, func(r *VaultSyncResult) { r.Skipped = append(r.Skipped, entry.Location) })
+			continue
+		}
+		if err != nil {
+			if markErr := sql_manager.MarkFailed(fw.db, entry.VersionID, err); markErr != nil {
+				log.Printf("Failed to record upload error: %v", markErr)
+			}
+			return err
+		}
+		if err := sql_manager.MarkUploaded(fw.db, entry.VersionID); err != nil {
+			return err
+		}
+		fw.noteSync(vaultID, func(r *VaultSyncResult) { r.Uploaded = append(r.Uploaded, entry.Location) })
+	}
+	return nil
+}
+
+// keepConflictCopy moves a pending local change out of the way of an incoming
+// remote version. The copy is written next to the file and recorded so
+// `conflicts` can list it until the user deletes it.
+func (fw *FileWatcher) keepConflictCopy(folder *folderState, file *sql_manager.File, remoteClient string) error {
+	copyLocation := conflictLocation(file.Location, remoteClient, time.Now())
+	copyPath, err := folder.localPath(copyLocation)
+	if err != nil {
+		return err
+	}
+	if file.Blob {
+		// attachments only live on disk, the local file becomes the copy
+		var path string
+		if path, err = folder.localPath(file.Location); err == nil {
+			err = os.Rename(path, copyPath)
+		}
+	} else {
+		err = os.WriteFile(copyPath, []byte(file.Content), 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := sql_manager.DropPending(fw.db, file.VaultID, file.ID); err != nil {
+		return err
+	}
+	log.Printf("Conflict on %s, kept the local version as %s", file.Location, copyLocation)
+	fw.noteSync(file.VaultID, func(r *VaultSyncResult) {
+		r.Conflicts = append(r.Conflicts, SyncConflict{Location: file.Location, CopyLocation: copyLocation, RemoteClient: remoteClient})
+	})
+	return sql_manager.CreateConflict(fw.db, &sql_manager.Conflict{
+		VaultID:      file.VaultID,
+		FileID:       file.ID,
+		Location:     file.Location,
+		CopyLocation: copyLocation,
+		RemoteClient: remoteClient,
+	})
+}
+
+// conflictLocation turns notes/a.md into notes/a (conflict laptop 2006-01-02 150405).md
+func conflictLocation(location, client string, at time.Time) string {
+	ext := path.Ext(location)
+	if client == "" {
+		client = "remote"
+	}
+	return fmt.Sprintf("%s (conflict %s %s)%s", strings.TrimSuffix(location, ext), client, at.Format("2006-01-02 150405"), ext)
+}
+
+func (fw *FileWatcher) caughtUp(vaultID string) bool {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.synced[vaultID]
+}
+
+func (fw *FileWatcher) setCaughtUp(vaultID string, status bool) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if status {
+		fw.synced[vaultID] = true
+	} else {
+		delete(fw.synced, vaultID)
+	}
+}
+
+// missDownload remembers a download that failed, the catch-up of its vault
+// is then not confirmed, and reports it as an error of the Sync pass.
+func (fw *FileWatcher) missDownload(vaultID, location string, cause error) {
+	fw.mu.Lock()
+	fw.missed[vaultID] = true
+	fw.mu.Unlock()
+	fw.noteSync(vaultID, func(r *VaultSyncResult) {
+		if r.Error != "" {
+			r.Error += "; "
+		}
+		r.Error += fmt.Sprintf("failed to download %s: %v", location, cause)
+	})
+}
+
+// takeMissed reports whether a download of the vault failed since the last
+// call.
+func (fw *FileWatcher) takeMissed(vaultID string) bool {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	missed := fw.missed[vaultID]
+	delete(fw.missed, vaultID)
+	return missed
+}
+
+// WaitSynced blocks until every vault has caught up with the server and its
+// outbox was flushed, then reports versions that are still pending.
+func (fw *FileWatcher) WaitSynced(ctx context.Context) error {
+	for vaultID := range fw.folders {
+		for !fw.caughtUp(vaultID) {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("vault %s did not sync: %w", vaultID, ctx.Err())
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	for vaultID := range fw.folders {
+		if err := fw.flushOutbox(vaultID); err != nil {
+			return fmt.Errorf("vault %s: %w", vaultID, err)
+		}
+	}
+	return nil
+}
+
+// Locate maps a path on disk to its folder and vault relative location using
+// the same rules as the watcher.
+func Locate(folders []Folder, path string) (Folder, string, error) {
+	states, err := newFolderStates(folders)
+	if err != nil {
+		return Folder{}, "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Folder{}, "", err
+	}
+	for _, folder := range states {
+		if within(folder.Path, abs) {
+			location, err := folder.location(abs)
+			return folder.Folder, location, err
+		}
+	}
+	return Folder{}, "", fmt.Errorf("%s is not inside a synced folder", path)
+}
@@ -0,0 +1,340 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"connectrpc.com/connect"
+	"github.com/itsrobel/sync/internal/auth"
+	"github.com/itsrobel/sync/internal/drawing"
+	"github.com/itsrobel/sync/internal/e2e"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"gorm.io/gorm"
+)
+
+type PlanAction string
+
+const (
+	PlanUpload   PlanAction = "upload"
+	PlanDownload PlanAction = "download"
+	PlanDelete   PlanAction = "delete"
+	PlanRename   PlanAction = "rename"
+	PlanConflict PlanAction = "conflict"
+)
+
+// PlanItem is one change a sync would make. Target is the side that changes,
+// "server" for local edits and "local" for remote ones. Deletes are listed so
+// they can be reviewed, sync keeps the copy on the other side.
+type PlanItem struct {
+	Action   PlanAction `json:"action"`
+	Target   string     `json:"target"`
+	Location string     `json:"location"`
+	From     string     `json:"from,omitempty"` // previous location of a rename
+	Size     int64      `json:"size"`
+	Reason   string     `json:"reason,omitempty"`
+}
+
+type VaultPlan struct {
+	Vault string `json:"vault"`
+	Path  string `json:"path"`
+	// CreateVault is set when the server does not know the vault yet
+	CreateVault bool       `json:"create_vault"`
+	ReadOnly    bool       `json:"read_only"`
+	Items       []PlanItem `json:"items"`
+}
+
+type SyncPlan struct {
+	Vaults []VaultPlan `json:"vaults"`
+}
+
+// Plan compares disk, the local database and the server and reports what a
+// sync would do without changing any of them. A file moved on disk with its
+// content unchanged is a rename, as it is for sync.
+func Plan(ctx context.Context, cfg Config) (*SyncPlan, error) {
+	folders, err := newFolderStates(cfg.Folders)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// a database that does not exist yet must not be created by a plan, an
+	// existing one is neither migrated nor indexed
+	var db *gorm.DB
+	if _, err := os.Stat(cfg.DBPath); os.IsNotExist(err) {
+		db, err = sql_manager.ConnectSQLite("file::memory:")
+	} else {
+		db, err = sql_manager.OpenSQLiteReadOnly(cfg.DBPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	fw := &FileWatcher{db: db, client: client, folders: folders}
+
+	res, err := client.ListVaults(ctx, connect.NewRequest(&ft.ActionRequest{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vaults: %w", err)
+	}
+	known := make(map[string]bool, len(res.Msg.Vaults))
+	for _, vault := range res.Msg.Vaults {
+		known[vault.Id] = true
+	}
+
+	plan := &SyncPlan{}
+	for _, folder := range folders {
+		if folder.rules, err = sql_manager.GetLocalRules(db, folder.Vault); err != nil {
+			return nil, err
+		}
+		vault := VaultPlan{Vault: folder.Vault, Path: folder.Path, CreateVault: !known[folder.Vault]}
+		if vault.Items, err = fw.planVault(ctx, folder, vault.CreateVault); err != nil {
+			return nil, fmt.Errorf("vault %s: %w", folder.Vault, err)
+		}
+		if !vault.CreateVault {
+			res, err := client.GetAccess(ctx, connect.NewRequest(&ft.ActionRequest{VaultId: folder.Vault}))
+			if err != nil {
+				return nil, err
+			}
+			role, _ := auth.ParseRole(res.Msg.Role)
+			vault.ReadOnly = !role.CanWrite()
+		}
+		plan.Vaults = append(plan.Vaults, vault)
+	}
+	sort.Slice(plan.Vaults, func(i, j int) bool { return plan.Vaults[i].Vault < plan.Vaults[j].Vault })
+	return plan, nil
+}
+
+func (fw *FileWatcher) planVault(ctx context.Context, folder *folderState, newVault bool) ([]PlanItem, error) {
+	changes, err := ScanChanges(fw.db, folder.Folder)
+	if err != nil {
+		return nil, err
+	}
+	local, err := sql_manager.GetAllFiles(fw.db, folder.Vault)
+	if err != nil {
+		return nil, err
+	}
+	localByID := make(map[string]*sql_manager.File, len(local))
+	localByLocation := make(map[string]*sql_manager.File, len(local))
+	for i := range local {
+		localByID[local[i].ID] = &local[i]
+		localByLocation[local[i].Location] = &local[i]
+	}
+
+	// locations with a local edit the server has not seen, on disk or queued
+	edited := make(map[string]int64)
+	for _, change := range changes {
+		if change.Kind != Deleted {
+			edited[change.Location] = change.Size
+		}
+	}
+	pending, err := sql_manager.GetPending(fw.db, folder.Vault)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range pending {
+		if _, ok := edited[entry.Location]; ok {
+			continue
+		}
+		version, err := sql_manager.GetVersionById(fw.db, entry.VersionID)
+		if err != nil {
+			return nil, err
+		}
+		edited[entry.Location] = versionSize(version)
+	}
+
+	var items []PlanItem
+	handled := make(map[string]bool)
+
+	if !newVault {
+		remote, err := fw.planRemoteFiles(ctx, folder)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range remote {
+			if !folder.rules.Syncs(file.Location) {
+				continue
+			}
+			known := localByID[file.ID]
+			if !file.Active {
+				if known != nil && known.Active {
+					items = append(items, PlanItem{Action: PlanDelete, Target: "local", Location: known.Location, Size: localSize(known), Reason: "deleted on the server, the local copy is kept"})
+				}
+				continue
+			}
+
+			if known == nil {
+				if size, ok := edited[file.Location]; ok {
+					handled[file.Location] = true
+					items = append(items, PlanItem{Action: PlanConflict, Target: "local", Location: file.Location, Size: size, Reason: "created locally and on the server"})
+				} else if other := localByLocation[file.Location]; other == nil {
+					reason := "new on the server"
+					if file.Blob && folder.LazyAttachments {
+						reason = "new on the server, only its metadata is synced"
+					}
+					items = append(items, PlanItem{Action: PlanDownload, Target: "local", Location: file.Location, Size: remoteSize(file), Reason: reason})
+				}
+				continue
+			}
+
+			changed, err := fw.remoteChanged(folder.Vault, known, file)
+			if err != nil {
+				return nil, err
+			}
+			if known.Location != file.Location {
+				items = append(items, PlanItem{Action: PlanRename, Target: "local", Location: file.Location, From: known.Location, Size: remoteSize(file), Reason: "renamed on the server"})
+			}
+			if !changed {
+				continue
+			}
+			if size, ok := edited[known.Location]; ok {
+				handled[known.Location] = true
+				reason := "changed locally and on the server, the local edit is kept as a copy"
+				if drawing.IsDrawing(known.Location) {
+					reason = "changed locally and on the server, the drawings are merged"
+				}
+				items = append(items, PlanItem{Action: PlanConflict, Target: "local", Location: known.Location, Size: size, Reason: reason})
+			} else {
+				reason := "changed on the server"
+				if known.Remote {
+					reason = "changed on the server, only its metadata is synced"
+				}
+				items = append(items, PlanItem{Action: PlanDownload, Target: "local", Location: file.Location, Size: remoteSize(file), Reason: reason})
+			}
+		}
+	}
+
+	// a new file that holds the content of a file gone from disk keeps its
+	// record, sync sends it as a rename
+	moved := make(map[string]bool)
+	for _, change := range changes {
+		if change.Kind != Added || handled[change.Location] {
+			continue
+		}
+		path, err := folder.localPath(change.Location)
+		if err != nil {
+			return nil, err
+		}
+		file, err := fw.findMoved(folder, path)
+		if err != nil {
+			return nil, err
+		}
+		if file == nil || moved[file.ID] {
+			continue
+		}
+		moved[file.ID] = true
+		handled[file.Location] = true
+		handled[change.Location] = true
+		items = append(items, PlanItem{Action: PlanRename, Target: "server", Location: change.Location, From: file.Location, Size: change.Size, Reason: "renamed locally"})
+	}
+
+	for _, change := range changes {
+		if handled[change.Location] {
+			continue
+		}
+		switch change.Kind {
+		case Added:
+			items = append(items, PlanItem{Action: PlanUpload, Target: "server", Location: change.Location, Size: change.Size, Reason: "new locally"})
+		case Modified:
+			items = append(items, PlanItem{Action: PlanUpload, Target: "server", Location: change.Location, Size: change.Size, Reason: "changed locally"})
+		case Deleted:
+			items = append(items, PlanItem{Action: PlanDelete, Target: "server", Location: change.Location, Size: change.Size, Reason: "deleted locally, the server keeps its copy"})
+		}
+		handled[change.Location] = true
+	}
+	for location, size := range edited {
+		if !handled[location] {
+			items = append(items, PlanItem{Action: PlanUpload, Target: "server", Location: location, Size: size, Reason: "queued by an earlier run"})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Location < items[j].Location })
+	if items == nil {
+		items = []PlanItem{}
+	}
+	return items, nil
+}
+
+// planRemoteFiles lists the server's files of a vault with locations and
+// contents decrypted. The key is looked up but never created or cached.
+func (fw *FileWatcher) planRemoteFiles(ctx context.Context, folder *folderState) ([]*ft.File, error) {
+	if folder.Passphrase != "" {
+		res, err := fw.client.GetKeyInfo(ctx, connect.NewRequest(&ft.ActionRequest{VaultId: folder.Vault}))
+		switch {
+		case err == nil:
+			if folder.keyring, err = e2e.Unlock(res.Msg, folder.Passphrase); err != nil {
+				return nil, err
+			}
+		case connect.CodeOf(err) != connect.CodeNotFound:
+			return nil, err
+		}
+	}
+
+	res, err := fw.client.RetrieveListOfFiles(ctx, connect.NewRequest(&ft.ActionRequest{VaultId: folder.Vault}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	for _, file := range res.Msg.Files {
+		if file.Blob {
+			// attachments are listed without content
+			file.Location, err = fw.openLocation(folder, file.ID, file.Location)
+		} else {
+			file.Location, file.Content, err = fw.openFile(folder, file.ID, file.Location, file.Content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", file.ID, err)
+		}
+	}
+	return res.Msg.Files, nil
+}
+
+// remoteChanged reports whether the server holds content this client never
+// had, anything matching a recorded version was uploaded or downloaded here.
+// Attachments are compared by their checksum.
+func (fw *FileWatcher) remoteChanged(vaultID string, file *sql_manager.File, remote *ft.File) (bool, error) {
+	if remote.Blob {
+		if remote.Hash == file.Hash {
+			return false, nil
+		}
+	} else if remote.Content == file.Content {
+		return false, nil
+	}
+	versions, err := sql_manager.GetAllFileVersions(fw.db, vaultID, file.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	for _, version := range versions {
+		if remote.Blob && version.Blob && version.Hash == remote.Hash {
+			return false, nil
+		} else if !remote.Blob && version.Content == remote.Content {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func versionSize(version *sql_manager.FileVersion) int64 {
+	if version.Blob {
+		return version.Size
+	}
+	return int64(len(version.Content))
+}
+
+func localSize(file *sql_manager.File) int64 {
+	if file.Blob {
+		return file.Size
+	}
+	return int64(len(file.Content))
+}
+
+func remoteSize(file *ft.File) int64 {
+	if file.Blob {
+		return file.Size
+	}
+	return int64(len(file.Content))
+}
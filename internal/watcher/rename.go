@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// newFileRecord returns the record for a file that appeared at location. A
+// file whose old location is gone from disk and whose content matches was
+// moved, it keeps its record so the server sees a rename instead of a new
+// file.
+func (fw *FileWatcher) newFileRecord(folder *folderState, path, location string) (*sql_manager.File, error) {
+	if moved, err := fw.findMoved(folder, path); err != nil {
+		return nil, err
+	} else if moved != nil {
+		log.Printf("Renamed %s to %s", moved.Location, location)
+		if err := sql_manager.RenameFile(fw.db, moved, location); err != nil {
+			return nil, fmt.Errorf("failed to rename file record: %w", err)
+		}
+		return moved, nil
+	}
+
+	file, err := sql_manager.CreateFileInitial(fw.db, folder.Vault, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file record: %w", err)
+	}
+	log.Printf("Created new file record: %s", path)
+	return file, nil
+}
+
+func (fw *FileWatcher) findMoved(folder *folderState, path string) (*sql_manager.File, error) {
+	query := fw.db.Where("vault_id = ? AND active = ?", folder.Vault, true)
+	if IsAttachment(path) {
+		// attachments are only on disk, their checksum stands in
+		size, sum, err := fw.hashFile(folder, path)
+		if err != nil || size == 0 {
+			return nil, nil
+		}
+		query = query.Where("blob = ? AND remote = ? AND size = ? AND hash = ?", true, false, size, sum)
+	} else {
+		content, err := os.ReadFile(path)
+		if err != nil || len(content) == 0 {
+			// empty files are too alike to tell apart
+			return nil, nil
+		}
+		query = query.Where("content = ?", string(content))
+	}
+
+	var candidates []sql_manager.File
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		old, err := folder.localPath(candidates[i].Location)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(old); os.IsNotExist(err) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// moveFile applies a rename that came from the server. The record is updated
+// before the file moves, so the watcher finds it at the new location.
+func (fw *FileWatcher) moveFile(folder *folderState, file *sql_manager.File, location string) error {
+	oldPath, err := folder.localPath(file.Location)
+	if err != nil {
+		return err
+	}
+	newPath, err := folder.localPath(location)
+	if err != nil {
+		return err
+	}
+
+	if err := sql_manager.RenameFile(fw.db, file, location); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	log.Printf("Moved %s to %s", oldPath, newPath)
+	return nil
+}
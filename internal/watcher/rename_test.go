@@ -0,0 +1,34 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/itsrobel/sync/internal/sql_manager"
+)
+
+func TestFindMoved(t *testing.T) {
+	db := localDB(t)
+	dir := t.TempDir()
+	fw := &FileWatcher{db: db}
+	folder := &folderState{Folder: Folder{Path: dir, Vault: sql_manager.DefaultVault}}
+	moved := record(t, db, "a.md", "moved note")
+	record(t, db, "copied.md", "copied note")
+	writeFile(t, filepath.Join(dir, "copied.md"), "copied note")
+
+	writeFile(t, filepath.Join(dir, "notes/a.md"), "moved note")
+	if file, err := fw.findMoved(folder, filepath.Join(dir, "notes/a.md")); err != nil || file == nil || file.ID != moved.ID {
+		t.Errorf("findMoved of a moved note = %v, %v", file, err)
+	}
+	// the original is still there, this is a copy
+	writeFile(t, filepath.Join(dir, "copy.md"), "copied note")
+	if file, err := fw.findMoved(folder, filepath.Join(dir, "copy.md")); err != nil || file != nil {
+		t.Errorf("findMoved of a copy = %v, %v", file, err)
+	}
+	// empty files are too alike to tell apart
+	record(t, db, "empty.md", "")
+	writeFile(t, filepath.Join(dir, "other.md"), "")
+	if file, err := fw.findMoved(folder, filepath.Join(dir, "other.md")); err != nil || file != nil {
+		t.Errorf("findMoved of an empty file = %v, %v", file, err)
+	}
+}
@@ -0,0 +1,108 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"gorm.io/gorm"
+)
+
+type ChangeKind string
+
+const (
+	Added    ChangeKind = "added"
+	Modified ChangeKind = "modified"
+	Deleted  ChangeKind = "deleted"
+)
+
+// LocalChange is a difference between the folder on disk and the last
+// recorded state in the local database.
+type LocalChange struct {
+	Location string     `json:"location"`
+	Kind     ChangeKind `json:"kind"`
+	Size     int64      `json:"size"`
+}
+
+// ScanChanges compares a folder with the local database without recording
+// anything, the watcher picks the same changes up on its next start. Folders
+// the sync rules leave out are not looked at.
+func ScanChanges(db *gorm.DB, folder Folder) ([]LocalChange, error) {
+	states, err := newFolderStates([]Folder{folder})
+	if err != nil {
+		return nil, err
+	}
+	state := states[folder.Vault]
+	if state == nil {
+		state = states[sql_manager.DefaultVault]
+	}
+
+	rules, err := sql_manager.GetLocalRules(db, state.Vault)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var changes []LocalChange
+	err = filepath.Walk(state.Path, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == state.Path {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if info.IsDir() || !ValidFileExtension(path) {
+			return nil
+		}
+		location, err := state.location(path)
+		if err != nil {
+			return err
+		}
+		if !rules.Syncs(location) {
+			return nil
+		}
+		seen[location] = true
+
+		file, err := sql_manager.FindFileByLocation(db, state.Vault, location)
+		if err == gorm.ErrRecordNotFound {
+			changes = append(changes, LocalChange{Location: location, Kind: Added, Size: info.Size()})
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if file.Blob {
+			// attachments are not hashed here, size and time are enough
+			// to tell that recordBlob will look at them
+			if file.Remote || info.Size() != file.Size || info.ModTime().After(file.Timestamp) {
+				changes = append(changes, LocalChange{Location: location, Kind: Modified, Size: info.Size()})
+			}
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if string(content) != file.Content {
+			changes = append(changes, LocalChange{Location: location, Kind: Modified, Size: info.Size()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := sql_manager.GetAllFiles(db, state.Vault)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		// attachments that were never downloaded are not missing
+		if file.Active && !file.Remote && !seen[file.Location] && rules.Syncs(file.Location) {
+			changes = append(changes, LocalChange{Location: file.Location, Kind: Deleted, Size: file.Size})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Location < changes[j].Location })
+	return changes, nil
+}
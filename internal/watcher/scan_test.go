@@ -0,0 +1,34 @@
+package watcher
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/itsrobel/sync/internal/sql_manager"
+)
+
+func TestScanChanges(t *testing.T) {
+	db := localDB(t)
+	dir := t.TempDir()
+	record(t, db, "same.md", "same")
+	record(t, db, "edited.md", "before")
+	record(t, db, "gone.md", "gone")
+	writeFile(t, filepath.Join(dir, "same.md"), "same")
+	writeFile(t, filepath.Join(dir, "edited.md"), "after")
+	writeFile(t, filepath.Join(dir, "notes/new.md"), "new")
+	writeFile(t, filepath.Join(dir, "notes/skipped.exe"), "not synced")
+
+	changes, err := ScanChanges(db, Folder{Path: dir, Vault: sql_manager.DefaultVault})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LocalChange{
+		{Location: "edited.md", Kind: Modified, Size: 5},
+		{Location: "gone.md", Kind: Deleted, Size: 4},
+		{Location: "notes/new.md", Kind: Added, Size: 3},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
@@ -0,0 +1,198 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"connectrpc.com/connect"
+	ft "github.com/itsrobel/sync/internal/services/filetransfer"
+	"github.com/itsrobel/sync/internal/sql_manager"
+	"gorm.io/gorm"
+)
+
+// SelectResult reports what changing the sync rules of a vault did on disk.
+type SelectResult struct {
+	Vault      string   `json:"vault"`
+	Include    []string `json:"include"`
+	Exclude    []string `json:"exclude"`
+	Downloaded []string `json:"downloaded"`
+	Deferred   []string `json:"deferred"`
+	Removed    []string `json:"removed"`
+}
+
+// syncs reports whether the folder's rules mirror a location on this device.
+func (fw *FileWatcher) syncs(folder *folderState, location string) bool {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return folder.rules.Syncs(location)
+}
+
+func (fw *FileWatcher) setRules(folder *folderState, rules sql_manager.SyncRules) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	folder.rules = rules
+}
+
+// refreshRules picks up the sync rules the server holds for this device,
+// they may have been changed by another run of the client.
+func (fw *FileWatcher) refreshRules(folder *folderState) error {
+	res, err := fw.client.GetSyncRules(context.Background(), connect.NewRequest(&ft.SyncRules{
+		VaultId:   folder.Vault,
+		SessionId: fw.sessionID,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to get sync rules of vault %s: %w", folder.Vault, err)
+	}
+	rules := sql_manager.SyncRules{Include: res.Msg.Include, Exclude: res.Msg.Exclude}
+	if err := sql_manager.CacheLocalRules(fw.db, folder.Vault, rules); err != nil {
+		return err
+	}
+	fw.setRules(folder, rules)
+	return nil
+}
+
+// SelectFolders changes which folders of a vault this device mirrors. change
+// edits the current rules, then files the new rules cover are downloaded and
+// the local copies of files they leave out are removed. The server keeps
+// those. When a file that would be removed has changes that were not
+// uploaded yet, nothing is changed.
+func SelectFolders(ctx context.Context, cfg Config, vaultID string, change func(*sql_manager.SyncRules) error) (*SelectResult, error) {
+	fw, err := newFileWatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer fw.Stop()
+
+	folder := fw.folders[vaultID]
+	if folder == nil {
+		return nil, fmt.Errorf("no folder is mapped to vault %s", vaultID)
+	}
+	if ring := fw.getKeyring(folder); ring != nil && ring.EncryptPaths {
+		return nil, fmt.Errorf("vault %s encrypts paths, its folders cannot be selected", vaultID)
+	}
+
+	current, err := fw.client.GetSyncRules(ctx, connect.NewRequest(&ft.SyncRules{VaultId: vaultID, SessionId: fw.sessionID}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync rules: %w", err)
+	}
+	rules := sql_manager.SyncRules{Include: current.Msg.Include, Exclude: current.Msg.Exclude}
+	if err := change(&rules); err != nil {
+		return nil, err
+	}
+	if rules, err = sql_manager.NewSyncRules(rules.Include, rules.Exclude); err != nil {
+		return nil, err
+	}
+
+	files, err := sql_manager.GetAllFiles(fw.db, vaultID)
+	if err != nil {
+		return nil, err
+	}
+	var dropped []sql_manager.File
+	for _, file := range files {
+		if rules.Syncs(file.Location) {
+			continue
+		}
+		pending, err := sql_manager.HasPending(fw.db, vaultID, file.ID)
+		if err != nil {
+			return nil, err
+		}
+		if pending {
+			return nil, fmt.Errorf("%s has changes that were not uploaded yet, sync before removing its folder", file.Location)
+		}
+		dropped = append(dropped, file)
+	}
+
+	set, err := fw.client.SetSyncRules(ctx, connect.NewRequest(&ft.SyncRules{
+		VaultId:   vaultID,
+		SessionId: fw.sessionID,
+		Include:   rules.Include,
+		Exclude:   rules.Exclude,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set sync rules: %w", err)
+	}
+	rules = sql_manager.SyncRules{Include: set.Msg.Include, Exclude: set.Msg.Exclude}
+	if err := sql_manager.CacheLocalRules(fw.db, vaultID, rules); err != nil {
+		return nil, err
+	}
+	fw.setRules(folder, rules)
+
+	result := &SelectResult{
+		Vault:      vaultID,
+		Include:    rules.Include,
+		Exclude:    rules.Exclude,
+		Downloaded: []string{},
+		Deferred:   []string{},
+		Removed:    []string{},
+	}
+	for i := range dropped {
+		if err := fw.dropLocal(folder, &dropped[i]); err != nil {
+			return result, err
+		}
+		result.Removed = append(result.Removed, dropped[i].Location)
+	}
+
+	// catch-up only covers recent changes, files of added folders are
+	// fetched here
+	remote, err := fw.client.RetrieveListOfFiles(ctx, connect.NewRequest(&ft.ActionRequest{VaultId: vaultID}))
+	if err != nil {
+		return result, fmt.Errorf("failed to list files: %w", err)
+	}
+	fw.mu.Lock()
+	fw.report = &SyncResult{Vaults: []VaultSyncResult{{Vault: vaultID}}}
+	fw.mu.Unlock()
+	for _, file := range remote.Msg.Files {
+		if !file.Active {
+			continue
+		}
+		location, err := fw.openLocation(folder, file.ID, file.Location)
+		if err != nil {
+			return result, err
+		}
+		if !rules.Syncs(location) {
+			continue
+		}
+		if _, err := sql_manager.FindFileById(fw.db, vaultID, file.ID); err == nil {
+			continue
+		} else if err != gorm.ErrRecordNotFound {
+			return result, err
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if err := fw.file_download(vaultID, file.ID, false); err != nil {
+			return result, fmt.Errorf("failed to download %s: %w", location, err)
+		}
+	}
+	fw.noteSync(vaultID, func(r *VaultSyncResult) {
+		result.Downloaded = append(result.Downloaded, r.Downloaded...)
+		result.Deferred = append(result.Deferred, r.Deferred...)
+	})
+	return result, nil
+}
+
+// dropLocal removes a file this device no longer mirrors, along with folders
+// that are left empty.
+func (fw *FileWatcher) dropLocal(folder *folderState, file *sql_manager.File) error {
+	path, err := folder.localPath(file.Location)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := sql_manager.ForgetFile(fw.db, file); err != nil {
+		return err
+	}
+	log.Printf("Removed %s, its folder is no longer synced here", path)
+
+	for dir := filepath.Dir(path); dir != folder.Path && within(folder.Path, dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
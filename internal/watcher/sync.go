@@ -0,0 +1,177 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/itsrobel/sync/internal/sql_manager"
+)
+
+// SyncResult describes what one Sync pass did, per vault.
+type SyncResult struct {
+	Vaults []VaultSyncResult `json:"vaults"`
+}
+
+type VaultSyncResult struct {
+	Vault      string         `json:"vault"`
+	Uploaded   []string       `json:"uploaded"`
+	Downloaded []string       `json:"downloaded"`
+	Conflicts  []SyncConflict `json:"conflicts"`
+	// Skipped files are over a size limit, Deferred attachments were only
+	// recorded and wait for `fetch`
+	Skipped  []string `json:"skipped"`
+	Deferred []string `json:"deferred"`
+	// Pending counts versions that are still waiting for an upload
+	Pending int    `json:"pending"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SyncConflict is a local edit that lost against a remote version during the
+// pass, the edit was kept at CopyLocation.
+type SyncConflict struct {
+	Location     string `json:"location"`
+	CopyLocation string `json:"copy_location"`
+	RemoteClient string `json:"remote_client"`
+}
+
+// Status codes returned by SyncResult.ExitCode.
+const (
+	SyncOK         = 0
+	SyncFailed     = 1
+	SyncConflicted = 3
+	SyncIncomplete = 4
+)
+
+// ExitCode summarizes the pass for scripts: failures win over conflicts,
+// conflicts over uploads that are still pending.
+func (r *SyncResult) ExitCode() int {
+	code := SyncOK
+	for _, vault := range r.Vaults {
+		switch {
+		case vault.Error != "":
+			return SyncFailed
+		case len(vault.Conflicts) > 0:
+			code = SyncConflicted
+		case vault.Pending > 0 && code == SyncOK:
+			code = SyncIncomplete
+		}
+	}
+	return code
+}
+
+// Sync runs a single pass without watching the folders: local changes since
+// the last run are recorded, the server sends what changed remotely, then the
+// outbox is uploaded. Conflicts are resolved the same way as in watch mode,
+// by keeping the local edit as a copy, and reported in the result.
+//
+// An error means the pass did not complete for any vault, for example when
+// the server is unreachable or ctx expires first.
+func Sync(ctx context.Context, cfg Config) (*SyncResult, error) {
+	fw, err := newFileWatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer fw.Stop()
+
+	result := &SyncResult{}
+	fw.mu.Lock()
+	fw.report = result
+	for vaultID := range fw.folders {
+		result.Vaults = append(result.Vaults, VaultSyncResult{
+			Vault:      vaultID,
+			Uploaded:   []string{},
+			Downloaded: []string{},
+			Conflicts:  []SyncConflict{},
+			Skipped:    []string{},
+			Deferred:   []string{},
+		})
+	}
+	fw.mu.Unlock()
+	sort.Slice(result.Vaults, func(i, j int) bool { return result.Vaults[i].Vault < result.Vaults[j].Vault })
+
+	if err := fw.attemptConnection(); err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	// the watcher flushes on SYNCED, an error there is picked up again below
+	for vaultID := range fw.folders {
+		for !fw.caughtUp(vaultID) {
+			if !fw.streamOpen() {
+				return nil, errors.New("connection to the server was lost")
+			}
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("vault %s did not sync: %w", vaultID, ctx.Err())
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	for vaultID := range fw.folders {
+		flushErr := fw.flushOutbox(vaultID)
+		pending, err := sql_manager.GetPending(fw.db, vaultID)
+		if err != nil {
+			return nil, err
+		}
+		fw.noteSync(vaultID, func(r *VaultSyncResult) {
+			r.Pending = len(pending)
+			// read-only vaults keep their edits queued, that is not a failure
+			if flushErr != nil && !errors.Is(flushErr, ErrReadOnly) {
+				if r.Error != "" {
+					r.Error += "; "
+				}
+				r.Error += flushErr.Error()
+			}
+		})
+		if flushErr != nil {
+			log.Printf("Failed to upload pending changes for vault %s: %v", vaultID, flushErr)
+		}
+	}
+	fw.hangUp(5 * time.Second)
+	return result, nil
+}
+
+// hangUp closes our side of the control stream and waits for the server to
+// end it, so the messages sent last, RECEIVED among them, are handled before
+// the connection is torn down.
+func (fw *FileWatcher) hangUp(timeout time.Duration) {
+	fw.mu.RLock()
+	stream := fw.controlStream
+	fw.mu.RUnlock()
+	if stream == nil {
+		return
+	}
+	if err := stream.CloseRequest(); err != nil {
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	for fw.streamOpen() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// noteSync updates the result of the running Sync pass, it does nothing in
+// watch mode.
+func (fw *FileWatcher) noteSync(vaultID string, update func(*VaultSyncResult)) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.report == nil {
+		return
+	}
+	for i := range fw.report.Vaults {
+		if fw.report.Vaults[i].Vault == vaultID {
+			update(&fw.report.Vaults[i])
+			return
+		}
+	}
+}
+
+func (fw *FileWatcher) streamOpen() bool {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.controlStream != nil
+}
@@ -2,12 +2,10 @@ package watcher
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,75 +16,140 @@ import (
 
 	"connectrpc.com/connect"
 	"github.com/fsnotify/fsnotify"
+	"github.com/itsrobel/sync/internal/auth"
+	"github.com/itsrobel/sync/internal/drawing"
 	ft "github.com/itsrobel/sync/internal/services/filetransfer"
 	"github.com/itsrobel/sync/internal/services/filetransfer/filetransferconnect"
 	"github.com/itsrobel/sync/internal/sql_manager"
+	"github.com/itsrobel/sync/internal/transport"
 
 	// ct "github.com/itsrobel/sync/internal/types"
-	"golang.org/x/net/http2"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"gorm.io/gorm"
 )
 
+// ErrReadOnly is returned when uploading to a vault the user can only read.
+var ErrReadOnly = errors.New("vault is read-only")
+
 type FileWatcher struct {
 	watcher       *fsnotify.Watcher
 	db            *gorm.DB
 	wait          sync.WaitGroup
 	done          chan struct{}
+	ctx           context.Context // cancelled by Stop, ends the control stream
+	cancel        context.CancelFunc
 	client        filetransferconnect.FileServiceClient
 	sessionID     string
 	controlStream *connect.BidiStreamForClient[ft.ControlMessage, ft.ControlMessage]
 	isConnected   bool
 	mu            sync.RWMutex
+	folders       map[string]*folderState // keyed by vault id
+	synced        map[string]bool         // vaults that caught up on this connection
+	missed        map[string]bool         // vaults with a failed download since their last catch-up
+	flushMu       sync.Mutex
+	report        *SyncResult // only set by Sync
+}
+
+type Config struct {
+	DBPath     string
+	ClientName string
+	ServerAddr string
+	TLS        transport.ClientTLS
+	Token      string // device token, not needed by single-user servers
+	Folders    []Folder
 }
 
-func InitFileWatcher(dbPath, watchPath, clientName string) (*FileWatcher, error) {
+func InitFileWatcher(cfg Config) (*FileWatcher, error) {
+	fw, err := newFileWatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create watcher: %w", err)
 	}
+	fw.watcher = watcher
+
+	// Start the connection ticker
+	go fw.connectionTicker()
 
-	db, err := sql_manager.ConnectSQLite(dbPath)
+	log.Println("startWatching")
+	if err := fw.startWatching(); err != nil {
+		return nil, err
+	}
+
+	return fw, nil
+}
+
+// newFileWatcher opens the database, unlocks encrypted vaults and records
+// what changed on disk since the last run. It neither connects nor watches,
+// InitFileWatcher and Sync add those parts.
+func newFileWatcher(cfg Config) (*FileWatcher, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql_manager.ConnectSQLite(cfg.DBPath)
 	if err != nil {
-		watcher.Close()
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	client := filetransferconnect.NewFileServiceClient(
-		&http.Client{
-			Transport: &http2.Transport{
-				AllowHTTP: true,
-				DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
-					return net.Dial(network, addr)
-				},
-			},
-		},
-		"http://localhost:50051",
-	)
+	folders, err := newFolderStates(cfg.Folders)
+	if err != nil {
+		return nil, err
+	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	fw := &FileWatcher{
-		watcher:   watcher,
+		ctx:       ctx,
+		cancel:    cancel,
 		db:        db,
 		client:    client,
-		sessionID: clientName,
+		sessionID: cfg.ClientName,
 		done:      make(chan struct{}),
+		folders:   folders,
+		synced:    make(map[string]bool),
+		missed:    make(map[string]bool),
 	}
-	go fw.connectionTicker()
 
-	// Start the connection ticker
+	for _, folder := range fw.folders {
+		if folder.rules, err = sql_manager.GetLocalRules(db, folder.Vault); err != nil {
+			cancel()
+			return nil, err
+		}
+		if folder.Passphrase == "" {
+			continue
+		}
+		if err := fw.unlockVault(folder); err != nil {
+			cancel()
+			return nil, fmt.Errorf("vault %s: %w", folder.Vault, err)
+		}
+	}
 
-	// Process initial files regardless of connection status
+	// Process initial files regardless of connection status, before
+	// connecting so offline edits are queued ahead of the catch-up
 	log.Println("processInitialFiles")
-	if err := fw.processInitialFiles(watchPath); err != nil {
-		return nil, err
+	for _, folder := range fw.folders {
+		if err := fw.processInitialFiles(folder); err != nil {
+			cancel()
+			return nil, err
+		}
 	}
+	return fw, nil
+}
 
-	log.Println("startWatching")
-	if err := fw.startWatching(watchPath); err != nil {
-		return nil, err
+func newClient(cfg Config) (filetransferconnect.FileServiceClient, error) {
+	httpClient, err := transport.NewHTTPClient(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure transport: %w", err)
 	}
-
-	return fw, nil
+	return filetransferconnect.NewFileServiceClient(
+		httpClient,
+		transport.BaseURL(cfg.ServerAddr, cfg.TLS.Enabled),
+		auth.WithToken(cfg.Token),
+	), nil
 }
 
 func (fw *FileWatcher) connectionTicker() {
@@ -117,18 +180,32 @@ func (fw *FileWatcher) attemptConnection() error {
 	}
 	fw.mu.Unlock()
 
-	stream := fw.client.ControlStream(context.Background())
+	if err := fw.ensureVaults(); err != nil {
+		return err
+	}
+	for _, folder := range fw.folders {
+		if err := fw.refreshRules(folder); err != nil {
+			return err
+		}
+	}
+
+	stream := fw.client.ControlStream(fw.ctx)
 
 	fw.mu.Lock()
 	fw.controlStream = stream
 	fw.mu.Unlock()
 
-	if err := stream.Send(&ft.ControlMessage{
-		SessionId: fw.sessionID,
-		Type:      ft.ControlMessage_READY,
-	}); err != nil {
-		fw.setConnected(false)
-		return fmt.Errorf("failed to send initial message")
+	// one READY per vault, the server answers each with its own READY and
+	// the files that changed in that vault
+	for vaultID := range fw.folders {
+		if err := stream.Send(&ft.ControlMessage{
+			SessionId: fw.sessionID,
+			Type:      ft.ControlMessage_READY,
+			VaultId:   vaultID,
+		}); err != nil {
+			fw.setConnected(false)
+			return fmt.Errorf("failed to send initial message")
+		}
 	}
 
 	go fw.handleControlStream()
@@ -139,6 +216,8 @@ func (fw *FileWatcher) handleControlStream() {
 	defer func() {
 		fw.mu.Lock()
 		fw.controlStream = nil
+		fw.synced = make(map[string]bool)
+		fw.missed = make(map[string]bool)
 		fw.mu.Unlock()
 		fw.setConnected(false)
 	}()
@@ -157,25 +236,71 @@ func (fw *FileWatcher) handleControlStream() {
 		switch msg.Type {
 		case ft.ControlMessage_READY:
 			fw.setConnected(true)
-			log.Printf("Server connection established for session: %s", fw.sessionID)
+			log.Printf("Server connection established for session: %s, vault: %s", fw.sessionID, msg.VaultId)
+			folder := fw.folders[msg.VaultId]
+			if folder == nil {
+				continue
+			}
+			if err := fw.refreshAccess(folder); err != nil {
+				log.Printf("Failed to fetch access for vault %s: %v", folder.Vault, err)
+			}
+			if folder.Passphrase != "" {
+				if err := fw.unlockVault(folder); err != nil {
+					log.Printf("Failed to refresh vault key: %v", err)
+				}
+			}
+		case ft.ControlMessage_SYNCED:
+			if fw.folders[msg.VaultId] == nil {
+				continue
+			}
+			// the server keeps offering the files of a catch-up until it
+			// is confirmed, so a failed download is retried next time
+			if fw.takeMissed(msg.VaultId) {
+				log.Printf("Not all changes of vault %s were downloaded, they are fetched again on the next sync", msg.VaultId)
+			} else {
+				if err := sql_manager.SetSyncState(fw.db, msg.VaultId, time.Now()); err != nil {
+					log.Printf("Failed to store sync cursor: %v", err)
+				}
+				if err := fw.sendControlMessage(&ft.ControlMessage{
+					SessionId: fw.sessionID,
+					Type:      ft.ControlMessage_RECEIVED,
+					VaultId:   msg.VaultId,
+				}); err != nil {
+					log.Printf("Failed to confirm the catch-up of vault %s: %v", msg.VaultId, err)
+				}
+			}
+			fw.setCaughtUp(msg.VaultId, true)
+			if err := fw.flushOutbox(msg.VaultId); err != nil {
+				log.Printf("Failed to upload pending changes for vault %s: %v", msg.VaultId, err)
+			}
 		case ft.ControlMessage_NEW_FILE:
 			log.Printf("New file available on server: %s", msg.Filename)
+			if msg.FileId == "" {
+				continue
+			}
+			if err := fw.file_download(msg.VaultId, msg.FileId, false); err != nil {
+				log.Printf("Failed to download %s: %v", msg.Filename, err)
+				fw.missDownload(msg.VaultId, msg.Filename, err)
+			}
 		}
 	}
 }
 
 func (fw *FileWatcher) startControlStream() error {
-	stream := fw.client.ControlStream(context.Background())
+	stream := fw.client.ControlStream(fw.ctx)
 
 	fw.mu.Lock()
 	fw.controlStream = stream
 	fw.mu.Unlock()
 
-	if err := stream.Send(&ft.ControlMessage{
-		SessionId: fw.sessionID,
-		Type:      ft.ControlMessage_READY,
-	}); err != nil {
-		return fmt.Errorf("failed to send initial message: %w", err)
+	for vaultID := range fw.folders {
+		if err := stream.Send(&ft.ControlMessage{
+			SessionId: fw.sessionID,
+			Type:      ft.ControlMessage_READY,
+			VaultId:   vaultID,
+		}); err != nil {
+			return fmt.Errorf("failed to send initial message: %w", err)
+		}
 	}
 
 	go fw.handleControlStream()
@@ -185,53 +310,180 @@ func (fw *FileWatcher) startControlStream() error {
 // NOTE: this now uploads via the information returned in the database
 func (fw *FileWatcher) file_upload(fileVersion *sql_manager.FileVersion) error {
 	log.Println("uploading file: ", fileVersion.Location)
+	folder := fw.folders[fileVersion.VaultID]
+	if folder == nil {
+		return fmt.Errorf("no folder is mapped to vault %s", fileVersion.VaultID)
+	}
+	if fw.ReadOnly(fileVersion.VaultID) {
+		return fmt.Errorf("%w: %s was changed locally but not uploaded", ErrReadOnly, fileVersion.Location)
+	}
+
 	if err := fw.sendControlMessage(&ft.ControlMessage{
 		SessionId: fw.sessionID,
 		Type:      ft.ControlMessage_START_TRANSFER,
 		Filename:  filepath.Base(fileVersion.Location),
+		VaultId:   fileVersion.VaultID,
 	}); err != nil {
 		return err
 	}
 
-	// file, err := os.Open(fileVersion.)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to open file: %w", err)
-	// }
-	// defer file.Close()
-
-	stream := fw.client.SendFileToServer(context.Background())
-	buffer := []byte(fileVersion.Content)
-	chunkSize := sql_manager.ChunkSize
+	if fileVersion.Blob {
+		return fw.uploadBlob(folder, fileVersion)
+	}
 
-	for i := 0; i < len(buffer); i += chunkSize {
-		end := i + chunkSize
-		if end > len(buffer) {
-			end = len(buffer)
-		}
+	location, content, err := fw.sealFile(folder, fileVersion.FileID, fileVersion.Location, fileVersion.Content)
+	if err != nil {
+		return err
+	}
 
-		chunk := buffer[i:end]
-		if err := stream.Send(&ft.FileVersionData{
+	stream := fw.client.SendFileToServer(context.Background())
+	sender := &chunkSender{
+		stream: stream,
+		meta: &ft.FileVersionData{
 			Id:        fileVersion.ID,
-			Location:  fileVersion.Location, // or any identifier you want to use
-			FileId:    fileVersion.FileID,   // or any identifier you want to use
+			Location:  location,
+			FileId:    fileVersion.FileID,
+			VaultId:   fileVersion.VaultID,
 			Timestamp: timestamppb.New(fileVersion.Timestamp),
 			Client:    fw.sessionID,
-			Content:   chunk,
-			Offset:    int64(len(chunk)),
-		}); err != nil {
-			return fmt.Errorf("error sending string data: %v", err)
+			TotalSize: int64(len(content)),
+		},
+	}
+	_, err = sender.Write([]byte(content))
+	if err == nil {
+		// also sends empty notes, which have no chunk of their own
+		err = sender.Close()
+	}
+	return closeUpload(stream, err)
+}
+
+// file_download fetches the latest server copy of a file and writes it to
+// disk, recording it as a local version so the watcher does not re-upload it.
+// Attachments a folder only records are downloaded when fetch is set.
+func (fw *FileWatcher) file_download(vaultID, fileID string, fetch bool) error {
+	folder := fw.folders[vaultID]
+	if folder == nil {
+		return fmt.Errorf("no folder is mapped to vault %s", vaultID)
+	}
+
+	stream, err := fw.client.DownloadFile(context.Background(), connect.NewRequest(&ft.FileRequest{
+		FileId:  fileID,
+		VaultId: vaultID,
+	}))
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	if !stream.Receive() {
+		if err := stream.Err(); err != nil {
+			return fmt.Errorf("error receiving file: %w", err)
+		}
+		return fmt.Errorf("no data received for file %s", fileID)
+	}
+	meta := stream.Msg()
+
+	// our own uploads come back on the next catch-up
+	if known, err := sql_manager.VersionExists(fw.db, meta.Id); err != nil || known {
+		return err
+	}
+	if meta.Blob {
+		return fw.downloadBlob(folder, meta, stream, fetch)
+	}
+
+	buffer := meta.Content
+	for stream.Receive() {
+		buffer = append(buffer, stream.Msg().Content...)
+	}
+	if err := stream.Err(); err != nil {
+		return fmt.Errorf("error receiving file: %w", err)
+	}
+
+	location, content, err := fw.openFile(folder, fileID, meta.Location, string(buffer))
+	if err != nil {
+		return err
+	}
+	if !fw.syncs(folder, location) {
+		return nil
+	}
+	path, err := folder.localPath(location)
+	if err != nil {
+		return err
+	}
+
+	file, created, err := fw.downloadTarget(folder, fileID, location)
+	if err != nil {
+		return err
+	}
+	// a new record is empty too, so empty notes still get written. The disk
+	// is checked as well, an earlier write may have failed after recording
+	if !created && file.Content == content {
+		if current, err := os.ReadFile(path); err == nil && string(current) == content {
+			return nil
 		}
 	}
 
-	res, err := stream.CloseAndReceive()
+	pending, err := sql_manager.HasPending(fw.db, vaultID, fileID)
 	if err != nil {
-		return fmt.Errorf("error closing stream: %v", err)
+		return err
+	}
+	// a drawing changed on both sides is merged rather than copied
+	merged := ""
+	if pending && drawing.IsDrawing(location) {
+		if merged, err = drawing.Merge(location, file.Content, content); err != nil {
+			log.Printf("Failed to merge %s: %v", location, err)
+			merged = ""
+		} else if err := sql_manager.DropPending(fw.db, vaultID, fileID); err != nil {
+			return err
+		}
+	}
+	if pending && merged == "" {
+		if err := fw.keepConflictCopy(folder, file, meta.Client); err != nil {
+			return err
+		}
 	}
 
-	log.Printf("Upload completed: %v", res)
+	if _, err := sql_manager.CreateFileVersion(fw.db, file, content, meta.Client); err != nil {
+		return err
+	}
+	if merged != "" {
+		log.Printf("Merged the local changes to %s with those of %s", location, meta.Client)
+		if _, err := fw.recordVersion(file, merged); err != nil {
+			return err
+		}
+		content = merged
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	log.Printf("Downloaded file: %s", path)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	fw.noteSync(vaultID, func(r *VaultSyncResult) { r.Downloaded = append(r.Downloaded, location) })
 	return nil
 }
 
+// downloadTarget returns the record of a downloaded file, creating it for
+// files new to this device and moving it when it was renamed elsewhere.
+func (fw *FileWatcher) downloadTarget(folder *folderState, fileID, location string) (*sql_manager.File, bool, error) {
+	created := false
+	file, err := sql_manager.FindFileById(fw.db, folder.Vault, fileID)
+	if err == gorm.ErrRecordNotFound {
+		file, err = sql_manager.CreateFileRemote(fw.db, folder.Vault, fileID, location)
+		created = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if file.Location != location && file.Location != "" {
+		if err := fw.moveFile(folder, file, location); err != nil {
+			return nil, false, err
+		}
+	}
+	return file, created, nil
+}
+
 func (fw *FileWatcher) sendControlMessage(msg *ft.ControlMessage) error {
 	fw.mu.RLock()
 	defer fw.mu.RUnlock()
@@ -254,47 +506,66 @@ func (fw *FileWatcher) IsConnected() bool {
 	return fw.isConnected
 }
 
-func (fw *FileWatcher) processInitialFiles(watchPath string) error {
-	return filepath.Walk(watchPath, func(path string, info os.FileInfo, err error) error {
+// waitConnected polls the connection state until the control stream is ready
+// or the timeout passes.
+func (fw *FileWatcher) waitConnected(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !fw.IsConnected() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
+func (fw *FileWatcher) processInitialFiles(folder *folderState) error {
+	return filepath.Walk(folder.Path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if path != watchPath {
+		if path != folder.Path && !info.IsDir() && ValidFileExtension(path) {
+			location, err := folder.location(path)
+			if err != nil {
+				return err
+			}
+			if !fw.syncs(folder, location) || fw.tooLarge(folder, path, location) {
+				return nil
+			}
+
 			// TODO: find files by location is likely broken
-			file, err := sql_manager.FindFileByLocation(fw.db, path)
+			file, err := sql_manager.FindFileByLocation(fw.db, folder.Vault, location)
 
 			if err == gorm.ErrRecordNotFound {
 				var err error
-				file, err = sql_manager.CreateFileInitial(fw.db, path)
+				file, err = fw.newFileRecord(folder, path, location)
 				if err != nil {
-					return fmt.Errorf("failed to create file record: %w", err)
+					return err
 				}
-				log.Printf("Created new file record: %s", path)
 
-			} else {
+			} else if err != nil {
 				return err
+			} else if !IsAttachment(location) {
+				if current, err := os.ReadFile(path); err == nil && string(current) == file.Content {
+					// unchanged since the last run
+					return nil
+				}
 			}
 
-			// TODO: have proccesfilecontent return file to then upload
-			fileVersion, err := fw.processFileContent(path, file)
-			if err != nil {
+			// queued, the outbox is flushed once the vault caught up
+			if _, err := fw.processFileContent(folder, path, file); err != nil {
 				return err
 			}
-
-			log.Println("connection status: ", fw.IsConnected())
-			if fw.IsConnected() {
-
-				log.Println("connected to server and uploading file: ", fileVersion.Location)
-				if err := fw.file_upload(fileVersion); err != nil {
-					return err
-				}
-			}
 		}
 		return nil
 	})
 }
 
-func (fw *FileWatcher) processFileContent(path string, file *sql_manager.File) (*sql_manager.FileVersion, error) {
+func (fw *FileWatcher) processFileContent(folder *folderState, path string, file *sql_manager.File) (*sql_manager.FileVersion, error) {
+	if IsAttachment(file.Location) {
+		return fw.recordBlob(folder, path, file)
+	}
+
 	raw_file, err := os.Open(path)
 	tmpFV := &sql_manager.FileVersion{}
 	if err != nil {
@@ -315,16 +586,15 @@ func (fw *FileWatcher) processFileContent(path string, file *sql_manager.File) (
 		content.Write(buffer[:n])
 	}
 
-	fileVersion, err := sql_manager.CreateFileVersion(fw.db, file, content.String())
-	if err != nil {
-		return fileVersion, err
-	}
-	return fileVersion, nil
+	return fw.recordVersion(file, content.String())
 }
 
-func (fw *FileWatcher) startWatching(path string) error {
-	if err := fw.watcher.Add(path); err != nil {
-		return fmt.Errorf("failed to add path to watcher: %w", err)
+func (fw *FileWatcher) startWatching() error {
+	for _, folder := range fw.folders {
+		if err := fw.watcher.Add(folder.Path); err != nil {
+			return fmt.Errorf("failed to add path to watcher: %w", err)
+		}
+		log.Printf("Started watching directory: %s (vault %s)", folder.Path, folder.Vault)
 	}
 
 	fw.wait.Add(1)
@@ -350,7 +620,6 @@ func (fw *FileWatcher) startWatching(path string) error {
 		}
 	}()
 
-	log.Printf("Started watching directory: %s", path)
 	return nil
 }
 
@@ -359,40 +628,63 @@ func (fw *FileWatcher) handleEvent(event fsnotify.Event) error {
 		return nil
 	}
 
+	folder, location, err := fw.folderFor(event.Name)
+	if err != nil {
+		return err
+	}
+	// files in folders this device does not mirror stay local
+	if !fw.syncs(folder, location) || fw.tooLarge(folder, event.Name, location) {
+		return nil
+	}
+
 	switch {
 	case event.Op&fsnotify.Create == fsnotify.Create:
-		file, _ := sql_manager.FindFileByLocation(fw.db, event.Name)
+		_, err := sql_manager.FindFileByLocation(fw.db, folder.Vault, location)
 
-		if file == nil {
-			file, err := sql_manager.CreateFileInitial(fw.db, event.Name)
+		if err == gorm.ErrRecordNotFound {
+			file, err := fw.newFileRecord(folder, event.Name, location)
 			if err != nil {
-				return fmt.Errorf("failed to create file record: %w", err)
+				return err
 			}
-			log.Printf("Created new file: %s", event.Name)
-			_, err = fw.processFileContent(event.Name, file)
-			return err
+			if _, err := fw.processFileContent(folder, event.Name, file); err != nil {
+				return err
+			}
+			return fw.flushOutbox(folder.Vault)
 		}
 
 	case event.Op&fsnotify.Write == fsnotify.Write:
-		isFile, _ := sql_manager.FindFileByLocation(fw.db, event.Name)
-		if isFile == nil {
+		isFile, err := sql_manager.FindFileByLocation(fw.db, folder.Vault, location)
+		if err != nil {
 			return fmt.Errorf("file not found in database: %s", event.Name)
 		}
 
-		fileVersion, err := fw.processFileContent(event.Name, isFile)
-		if err != nil {
+		// writes made by file_download already have a version, attachments
+		// are compared by recordBlob
+		if !IsAttachment(location) {
+			if current, err := os.ReadFile(event.Name); err == nil && string(current) == isFile.Content {
+				return nil
+			}
+		}
+
+		if _, err := fw.processFileContent(folder, event.Name, isFile); err != nil {
 			return err
 		}
-		log.Printf("fileVersion: %v", fileVersion)
-		return fw.file_upload(fileVersion)
+		return fw.flushOutbox(folder.Vault)
 	}
 	return nil
 }
 
+// ValidFileExtension reports whether a file is synced: notes and the
+// attachment types Obsidian can show.
 func ValidFileExtension(location string) bool {
-	extensions := []string{".md", ".pdf"}
+	extensions := []string{
+		".md", ".pdf", ".excalidraw",
+		".png", ".jpg", ".jpeg", ".gif", ".bmp", ".svg", ".webp",
+		".mp3", ".wav", ".m4a", ".ogg", ".flac",
+		".mp4", ".webm", ".ogv", ".mov", ".mkv",
+	}
 	for _, ext := range extensions {
-		if strings.HasSuffix(location, ext) {
+		if strings.EqualFold(filepath.Ext(location), ext) {
 			return true
 		}
 	}
@@ -400,6 +692,9 @@ func ValidFileExtension(location string) bool {
 }
 
 func (fw *FileWatcher) Stop() {
+	if fw.cancel != nil {
+		fw.cancel()
+	}
 	if fw.done != nil {
 		close(fw.done)
 		fw.wait.Wait()
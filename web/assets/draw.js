@@ -1,37 +1,88 @@
 window.process = {
   env: {
     IS_PREACT: "false",
-    NODE_ENV: "development",
+    NODE_ENV: "production",
   },
 };
 
 import React from "react";
-import { Excalidraw } from "@excalidraw/excalidraw";
+import ReactDOM from "react-dom/client";
+import { Excalidraw, serializeAsJSON } from "@excalidraw/excalidraw";
 
-const App = () => {
-  return (
-    <div style={{ height: "500px" }}>
-      <Excalidraw
-        initialData={{
-          elements: [
-            {
-              type: "rectangle",
-              x: 100,
-              y: 100,
-              width: 200,
-              height: 100,
-              strokeColor: "#000000",
-            },
-          ],
-          appState: {
-            viewBackgroundColor: "#ffffff",
-          },
-        }}
-      />
-    </div>
-  );
-};
+const holder = document.getElementById("draw");
+const status = document.getElementById("save-status");
+const scene = JSON.parse(document.getElementById("scene").value);
+let baseVersion = holder.dataset.versionId;
+let api = null;
+let dirty = false;
+// onChange also fires for selection and scrolling, only element changes count
+let savedVersion = 0;
+
+const sceneVersion = (elements) => elements.reduce((sum, el) => sum + el.version, 0);
+
+// save sends the scene with the version the drawing was loaded at, the
+// server merges it with any version saved meanwhile and answers with the
+// result, which replaces what is on screen.
+async function save() {
+  if (!api) return;
+  const elements = api.getSceneElementsIncludingDeleted();
+  status.textContent = "Saving…";
+  const resp = await fetch("/draw", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      id: holder.dataset.fileId,
+      base: baseVersion,
+      scene: serializeAsJSON(elements, api.getAppState(), api.getFiles(), "local"),
+    }),
+  });
+  const result = await resp.json().catch(() => ({ error: resp.statusText }));
+  if (!resp.ok) {
+    status.textContent = result.error;
+    return;
+  }
+  baseVersion = result.version;
+  if (result.scene) {
+    const stored = JSON.parse(result.scene);
+    if (stored.files) api.addFiles(Object.values(stored.files));
+    api.updateScene({ elements: stored.elements || [] });
+  }
+  savedVersion = sceneVersion(api.getSceneElementsIncludingDeleted());
+  dirty = false;
+  document.getElementById("changed-elsewhere").hidden = true;
+  status.textContent = `Saved at ${new Date(result.timestamp).toLocaleTimeString()}`;
+}
+
+const App = () =>
+  React.createElement(Excalidraw, {
+    initialData: {
+      elements: scene.elements || [],
+      appState: scene.appState || {},
+      files: scene.files || {},
+    },
+    excalidrawAPI: (excalidrawAPI) => {
+      api = excalidrawAPI;
+    },
+    onChange: (elements) => {
+      const version = sceneVersion(elements);
+      if (savedVersion === 0) {
+        savedVersion = version;
+      } else if (version !== savedVersion && !dirty) {
+        dirty = true;
+        status.textContent = "Unsaved changes";
+      }
+    },
+  });
+
+ReactDOM.createRoot(holder).render(React.createElement(App));
 
-const excalidrawWrapper = document.getElementById("draw");
-const root = ReactDOM.createRoot(excalidrawWrapper);
-root.render(React.createElement(App));
+document.getElementById("save").addEventListener("click", save);
+document.addEventListener("keydown", (event) => {
+  if ((event.ctrlKey || event.metaKey) && event.key === "s") {
+    event.preventDefault();
+    save();
+  }
+});
+window.addEventListener("beforeunload", (event) => {
+  if (dirty) event.preventDefault();
+});
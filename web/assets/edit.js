@@ -8,13 +8,31 @@ import Table from "@editorjs/table";
 import Paragraph from "editorjs-paragraph-with-alignment";
 // import LinkTool from "@editorjs/link";
 import EJLaTeX from "editorjs-latex";
+import CodeTool from "@editorjs/code";
+import Delimiter from "@editorjs/delimiter";
+import { toBlocks, toMarkdown } from "./markdown.js";
+
+const holder = document.getElementById("editor");
+const source = document.getElementById("note-source");
+const status = document.getElementById("save-status");
+const { frontMatter, blocks } = toBlocks(source.value);
+let baseVersion = holder.dataset.versionId;
+let dirty = false;
+
 const editor = new EditorJS({
   holder: "editor",
+  data: { blocks },
   onReady: () => {
     new Undo({ editor });
     new DragDrop(editor);
   },
+  onChange: () => {
+    dirty = true;
+    status.textContent = "Unsaved changes";
+  },
   tools: {
+    code: CodeTool,
+    delimiter: Delimiter,
     Math: {
       class: EJLaTeX,
       shortcut: "CMD+SHIFT+L",
@@ -48,8 +66,8 @@ const editor = new EditorJS({
       class: Header,
       config: {
         placeholder: "Enter a header",
-        levels: [2, 3, 4],
-        defaultLevel: 3,
+        levels: [1, 2, 3, 4, 5, 6],
+        defaultLevel: 2,
       },
       shortcut: "CMD+SHIFT+H",
     },
@@ -71,3 +89,41 @@ const editor = new EditorJS({
     },
   },
 });
+
+// save sends the note as markdown along with the version the edit started
+// from, the server refuses it when a device saved a newer one meanwhile.
+async function save() {
+  const output = await editor.save();
+  status.textContent = "Saving…";
+  const resp = await fetch("/edit", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      id: holder.dataset.fileId,
+      base: baseVersion,
+      content: toMarkdown(frontMatter, output.blocks),
+    }),
+  });
+  const result = await resp.json().catch(() => ({ error: resp.statusText }));
+  if (!resp.ok) {
+    status.textContent = result.error;
+    if (resp.status === 409) {
+      document.getElementById("save-conflict").hidden = false;
+    }
+    return;
+  }
+  baseVersion = result.version;
+  dirty = false;
+  status.textContent = `Saved at ${new Date(result.timestamp).toLocaleTimeString()}`;
+}
+
+document.getElementById("save").addEventListener("click", save);
+document.addEventListener("keydown", (event) => {
+  if ((event.ctrlKey || event.metaKey) && event.key === "s") {
+    event.preventDefault();
+    save();
+  }
+});
+window.addEventListener("beforeunload", (event) => {
+  if (dirty) event.preventDefault();
+});
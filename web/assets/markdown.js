@@ -0,0 +1,220 @@
+// Conversion between a markdown note and Editor.js blocks. Constructs the
+// editor has no tool for (fenced code, html, front matter) are kept verbatim
+// in code blocks, so saving a note that was only opened changes little more
+// than whitespace.
+
+const escapeHTML = (text) =>
+  text.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+
+// inline markdown to the html Editor.js keeps in paragraphs
+export function inlineToHTML(text) {
+  const codes = [];
+  let html = escapeHTML(text).replace(/`([^`]+)`/g, (_, code) => {
+    codes.push(code);
+    return `\u0000${codes.length - 1}\u0000`;
+  });
+  html = html
+    .replace(/\[([^\]]+)\]\(([^)\s]+)\)/g, (_, label, href) =>
+      /^(javascript|data):/i.test(href) ? _ : `<a href="${href.replace(/"/g, "&quot;")}">${label}</a>`,
+    )
+    .replace(/\*\*([^*]+)\*\*/g, "<b>$1</b>")
+    .replace(/__([^_]+)__/g, "<b>$1</b>")
+    .replace(/(^|[^*])\*([^*\s][^*]*)\*/g, "$1<i>$2</i>")
+    .replace(/(^|[^\w_])_([^_\s][^_]*)_(?!\w)/g, "$1<i>$2</i>");
+  return html.replace(/\u0000(\d+)\u0000/g, (_, i) => `<code class="inline-code">${codes[i]}</code>`);
+}
+
+// the html of a paragraph back to inline markdown
+export function inlineToMarkdown(html) {
+  const root = document.createElement("div");
+  root.innerHTML = html;
+  const walk = (node) => {
+    if (node.nodeType === Node.TEXT_NODE) {
+      return node.textContent;
+    }
+    const inner = Array.from(node.childNodes).map(walk).join("");
+    switch (node.nodeName) {
+      case "B":
+      case "STRONG":
+        return inner ? `**${inner}**` : "";
+      case "I":
+      case "EM":
+        return inner ? `*${inner}*` : "";
+      case "CODE":
+        return `\`${node.textContent}\``;
+      case "A":
+        return `[${inner}](${node.getAttribute("href") || ""})`;
+      case "BR":
+        return "  \n";
+      default:
+        return inner;
+    }
+  };
+  return Array.from(root.childNodes).map(walk).join("").replace(/ /g, " ");
+}
+
+const listItem = /^(\s*)([-*+]|\d+[.)])\s+(\[( |x|X)\]\s+)?(.*)$/;
+const fence = /^(```|~~~)/;
+
+// parseList reads list lines into nested Editor.js list items, indentation
+// decides the nesting.
+function parseList(lines) {
+  const first = lines[0].match(listItem);
+  const style = first[4] !== undefined ? "checklist" : /\d/.test(first[2]) ? "ordered" : "unordered";
+  const root = { indent: -1, items: [] };
+  const stack = [root];
+  for (const line of lines) {
+    const m = line.match(listItem);
+    if (!m) {
+      // a continuation line of the previous item
+      const parent = stack[stack.length - 1];
+      const last = parent.items[parent.items.length - 1];
+      if (last) last.content += "<br>" + inlineToHTML(line.trim());
+      continue;
+    }
+    const indent = m[1].length;
+    while (stack.length > 1 && indent <= stack[stack.length - 1].indent) {
+      stack.pop();
+    }
+    const item = {
+      content: inlineToHTML(m[5]),
+      meta: style === "checklist" ? { checked: m[4] === "x" || m[4] === "X" } : {},
+      items: [],
+    };
+    stack[stack.length - 1].items.push(item);
+    stack.push({ indent, items: item.items });
+  }
+  return { type: "list", data: { style, meta: {}, items: root.items } };
+}
+
+function parseTable(lines) {
+  const cells = (line) =>
+    line
+      .trim()
+      .replace(/^\|/, "")
+      .replace(/\|$/, "")
+      .split("|")
+      .map((cell) => inlineToHTML(cell.trim()));
+  const withHeadings = lines.length > 1 && /^\s*\|?\s*:?-+/.test(lines[1]);
+  const rows = lines.filter((_, i) => !(withHeadings && i === 1)).map(cells);
+  return { type: "table", data: { withHeadings, content: rows } };
+}
+
+// toBlocks splits a note into its front matter, kept out of the editor, and
+// Editor.js blocks.
+export function toBlocks(markdown) {
+  let frontMatter = "";
+  let text = markdown.replace(/\r\n/g, "\n");
+  const fm = text.match(/^---\n[\s\S]*?\n---\n/);
+  if (fm) {
+    frontMatter = fm[0];
+    text = text.slice(fm[0].length);
+  }
+
+  const lines = text.split("\n");
+  const blocks = [];
+  let i = 0;
+  const takeWhile = (test) => {
+    const taken = [];
+    while (i < lines.length && test(lines[i])) taken.push(lines[i++]);
+    return taken;
+  };
+
+  while (i < lines.length) {
+    const line = lines[i];
+    if (line.trim() === "") {
+      i++;
+    } else if (fence.test(line)) {
+      const marker = line.match(fence)[1];
+      const code = [lines[i++]];
+      while (i < lines.length) {
+        code.push(lines[i]);
+        if (lines[i++].startsWith(marker)) break;
+      }
+      blocks.push({ type: "code", data: { code: code.join("\n") } });
+    } else if (line.trim() === "$$") {
+      i++;
+      const math = takeWhile((l) => l.trim() !== "$$");
+      i++;
+      blocks.push({ type: "Math", data: { math: math.join("\n") } });
+    } else if (/^#{1,6}\s/.test(line)) {
+      const m = line.match(/^(#{1,6})\s+(.*?)\s*#*$/);
+      blocks.push({ type: "header", data: { level: m[1].length, text: inlineToHTML(m[2]) } });
+      i++;
+    } else if (/^\s*([-*_]\s*){3,}$/.test(line)) {
+      blocks.push({ type: "delimiter", data: {} });
+      i++;
+    } else if (/^>/.test(line)) {
+      const quote = takeWhile((l) => /^>/.test(l)).map((l) => l.replace(/^>\s?/, ""));
+      blocks.push({
+        type: "quote",
+        data: { text: quote.map(inlineToHTML).join("<br>"), caption: "", alignment: "left" },
+      });
+    } else if (listItem.test(line)) {
+      blocks.push(parseList(takeWhile((l) => l.trim() !== "" && (listItem.test(l) || /^\s+/.test(l)))));
+    } else if (/^\s*\|/.test(line)) {
+      blocks.push(parseTable(takeWhile((l) => /^\s*\|/.test(l))));
+    } else if (/^\s*</.test(line)) {
+      // html is kept as it is
+      blocks.push({ type: "code", data: { code: takeWhile((l) => l.trim() !== "").join("\n") } });
+    } else {
+      const para = takeWhile(
+        (l) => l.trim() !== "" && !fence.test(l) && !/^(#{1,6}\s|>|\s*\|)/.test(l) && !listItem.test(l),
+      );
+      blocks.push({ type: "paragraph", data: { text: para.map(inlineToHTML).join("<br>") } });
+    }
+  }
+  return { frontMatter, blocks };
+}
+
+function listToMarkdown(style, items, depth) {
+  return items
+    .map((item, n) => {
+      const indent = "  ".repeat(depth);
+      let marker = style === "ordered" ? `${n + 1}.` : "-";
+      if (style === "checklist") marker = item.meta && item.meta.checked ? "- [x]" : "- [ ]";
+      const text = inlineToMarkdown(item.content || "").replace(/ {2}\n/g, "\n" + indent + "  ");
+      const nested = item.items && item.items.length ? "\n" + listToMarkdown(style, item.items, depth + 1) : "";
+      return `${indent}${marker} ${text}${nested}`;
+    })
+    .join("\n");
+}
+
+function blockToMarkdown(block) {
+  const data = block.data || {};
+  switch (block.type) {
+    case "header":
+      return `${"#".repeat(data.level || 2)} ${inlineToMarkdown(data.text || "")}`;
+    case "paragraph":
+      return inlineToMarkdown(data.text || "").replace(/ {2}\n/g, "\n");
+    case "quote":
+      return inlineToMarkdown(data.text || "")
+        .split(/ {2}\n/)
+        .map((line) => `> ${line}`)
+        .join("\n");
+    case "list":
+      return listToMarkdown(data.style, data.items || [], 0);
+    case "table": {
+      const rows = (data.content || []).map((row) => `| ${row.map(inlineToMarkdown).join(" | ")} |`);
+      if (data.withHeadings && rows.length) {
+        const width = data.content[0].length;
+        rows.splice(1, 0, `|${" --- |".repeat(width)}`);
+      }
+      return rows.join("\n");
+    }
+    case "Math":
+      return `$$\n${data.math || ""}\n$$`;
+    case "code":
+      return data.code || "";
+    case "delimiter":
+      return "---";
+    default:
+      return "";
+  }
+}
+
+// toMarkdown joins the blocks back into a note, front matter first.
+export function toMarkdown(frontMatter, blocks) {
+  const body = blocks.map(blockToMarkdown).filter((text) => text !== "").join("\n\n");
+  return frontMatter + body + "\n";
+}